@@ -0,0 +1,39 @@
+package completion
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	rootCmd "github.com/metal-toolbox/fleet-rest-skeleton/cmd"
+)
+
+// install completion command
+var completionCmd = &cobra.Command{
+	Use:   "completion [bash|zsh|fish]",
+	Short: "Generate a shell completion script",
+	Long: `Generate a shell completion script, e.g. for bash:
+
+  source <(fleet-rest-skeleton completion bash)`,
+	ValidArgs:             []string{"bash", "zsh", "fish"},
+	Args:                  cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+	DisableFlagsInUseLine: true,
+	RunE: func(c *cobra.Command, args []string) error {
+		root := c.Root()
+
+		switch args[0] {
+		case "bash":
+			return root.GenBashCompletionV2(c.OutOrStdout(), true)
+		case "zsh":
+			return root.GenZshCompletion(c.OutOrStdout())
+		case "fish":
+			return root.GenFishCompletion(c.OutOrStdout(), true)
+		default:
+			return fmt.Errorf("unsupported shell %q", args[0])
+		}
+	},
+}
+
+func init() {
+	rootCmd.RootCmd.AddCommand(completionCmd)
+}
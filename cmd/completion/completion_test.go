@@ -0,0 +1,58 @@
+package completion
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	rootCmd "github.com/metal-toolbox/fleet-rest-skeleton/cmd"
+	_ "github.com/metal-toolbox/fleet-rest-skeleton/cmd/server"
+	_ "github.com/metal-toolbox/fleet-rest-skeleton/cmd/version"
+)
+
+// execute runs the root command with args, returning what it wrote
+func execute(t *testing.T, args ...string) string {
+	t.Helper()
+
+	var out bytes.Buffer
+	rootCmd.RootCmd.SetOut(&out)
+	rootCmd.RootCmd.SetArgs(args)
+	t.Cleanup(func() {
+		rootCmd.RootCmd.SetOut(nil)
+		rootCmd.RootCmd.SetArgs(nil)
+	})
+
+	if err := rootCmd.RootCmd.Execute(); err != nil {
+		t.Fatalf("running %v: %v", args, err)
+	}
+
+	return out.String()
+}
+
+func TestCompletionBash(t *testing.T) {
+	out := execute(t, "completion", "bash")
+
+	if !strings.HasPrefix(out, "# bash completion V2 for fleet-rest-skeleton") {
+		t.Errorf("expected the bash completion header, got %q", firstLine(out))
+	}
+
+	if !strings.Contains(out, "__start_fleet-rest-skeleton()") {
+		t.Error("expected the completion entry point to be defined")
+	}
+}
+
+func TestCompletionCoversSubcommands(t *testing.T) {
+	// what the generated scripts call back into to complete a word
+	out := execute(t, "__complete", "")
+
+	for _, sub := range []string{"server", "version", "completion"} {
+		if !strings.Contains(out, sub) {
+			t.Errorf("expected %q to be completed, got %q", sub, out)
+		}
+	}
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
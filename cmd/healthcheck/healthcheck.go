@@ -0,0 +1,78 @@
+package healthcheck
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	rootCmd "github.com/metal-toolbox/fleet-rest-skeleton/cmd"
+)
+
+const (
+	livenessPath  = "/_health/liveness"
+	readinessPath = "/_health/readiness"
+)
+
+var (
+	baseURL   string
+	timeout   time.Duration
+	readiness bool
+)
+
+// install healthcheck command
+var healthcheckCmd = &cobra.Command{
+	Use:   "healthcheck",
+	Short: "Probe the health endpoints of a running server, exiting 1 when unhealthy",
+	Long: "Probe the health endpoints of a running server, exiting 1 when unhealthy. " +
+		"Meant for container health checks in images without curl.",
+	Run: func(c *cobra.Command, args []string) {
+		paths := []string{livenessPath}
+		if readiness {
+			paths = append(paths, readinessPath)
+		}
+
+		for _, p := range paths {
+			if err := probe(c.Context(), strings.TrimSuffix(baseURL, "/")+p); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %s\n", p, err.Error())
+				os.Exit(1)
+			}
+		}
+	},
+}
+
+// probe GETs uri, failing unless it responds 200 within the timeout
+func probe(ctx context.Context, uri string) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, http.NoBody)
+	if err != nil {
+		return errors.Wrap(err, "composing request")
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.New("unexpected status " + resp.Status)
+	}
+
+	return nil
+}
+
+// install command flags
+func init() {
+	rootCmd.RootCmd.AddCommand(healthcheckCmd)
+	healthcheckCmd.Flags().StringVar(&baseURL, "url", "http://127.0.0.1:7500", "base URL of the server to probe")
+	healthcheckCmd.Flags().DurationVar(&timeout, "timeout", 5*time.Second, "timeout for each probe")
+	healthcheckCmd.Flags().BoolVar(&readiness, "readiness", false, "also probe the readiness endpoint")
+}
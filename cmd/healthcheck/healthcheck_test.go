@@ -0,0 +1,34 @@
+package healthcheck
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestProbe(t *testing.T) {
+	timeout = 100 * time.Millisecond
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		wantErr bool
+	}{
+		{"healthy", func(w http.ResponseWriter, _ *http.Request) {}, false},
+		{"unhealthy", func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusServiceUnavailable) }, true},
+		{"hanging", func(w http.ResponseWriter, r *http.Request) { <-r.Context().Done() }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(tt.handler)
+			defer srv.Close()
+
+			if err := probe(context.Background(), srv.URL+livenessPath); (err != nil) != tt.wantErr {
+				t.Errorf("expected an error: %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
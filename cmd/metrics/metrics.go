@@ -0,0 +1,108 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/metal-toolbox/fleet-rest-skeleton/cmd"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+var (
+	metricsURL string
+	timeout    time.Duration
+)
+
+var metricsCmd = &cobra.Command{
+	Use:   "metrics",
+	Short: "inspect the metrics exposed by the service",
+}
+
+var dumpCmd = &cobra.Command{
+	Use:   "dump",
+	Short: "print a snapshot of every metric family exposed by a running server as JSON",
+	RunE: func(c *cobra.Command, args []string) error {
+		families, err := scrape(c.Context(), metricsURL)
+		if err != nil {
+			return err
+		}
+
+		return dump(os.Stdout, families)
+	},
+}
+
+// scrape fetches every metric family exposed at uri, in whichever exposition format
+// the server responds with
+func scrape(ctx context.Context, uri string) ([]*dto.MetricFamily, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", string(expfmt.FmtProtoDelim))
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New("unexpected status " + resp.Status)
+	}
+
+	dec := expfmt.NewDecoder(resp.Body, expfmt.ResponseFormat(resp.Header))
+
+	var families []*dto.MetricFamily
+	for {
+		mf := &dto.MetricFamily{}
+		if err := dec.Decode(mf); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, err
+		}
+		families = append(families, mf)
+	}
+
+	slices.SortFunc(families, func(a, b *dto.MetricFamily) int {
+		return strings.Compare(a.GetName(), b.GetName())
+	})
+
+	return families, nil
+}
+
+// dump writes the metric families to w as a JSON array
+func dump(w io.Writer, families []*dto.MetricFamily) error {
+	out := make([]json.RawMessage, 0, len(families))
+	for _, mf := range families {
+		b, err := protojson.Marshal(mf)
+		if err != nil {
+			return err
+		}
+		out = append(out, b)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+func init() {
+	cmd.RootCmd.AddCommand(metricsCmd)
+	metricsCmd.AddCommand(dumpCmd)
+	dumpCmd.Flags().StringVar(&metricsURL, "url", "http://127.0.0.1:9090/metrics", "URL of the metrics endpoint of the server to scrape")
+	dumpCmd.Flags().DurationVar(&timeout, "timeout", 5*time.Second, "timeout for the scrape")
+}
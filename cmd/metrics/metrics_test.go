@@ -0,0 +1,68 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+func TestDumpScrapesTheServer(t *testing.T) {
+	timeout = time.Second
+
+	reg := prometheus.NewRegistry()
+	counter := prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "custom_ns",
+		Name:      "things_total",
+		Help:      "a count of things",
+	})
+	reg.MustRegister(counter)
+	counter.Add(3)
+
+	srv := httptest.NewServer(promhttp.HandlerFor(reg, promhttp.HandlerOpts{EnableOpenMetrics: true}))
+	defer srv.Close()
+
+	families, err := scrape(context.Background(), srv.URL)
+	if err != nil {
+		t.Fatalf("scraping: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := dump(&out, families); err != nil {
+		t.Fatalf("dumping: %v", err)
+	}
+
+	var dumped []struct {
+		Name   string `json:"name"`
+		Metric []struct {
+			Counter struct {
+				Value float64 `json:"value"`
+			} `json:"counter"`
+		} `json:"metric"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &dumped); err != nil {
+		t.Fatalf("decoding dump %s: %v", out.String(), err)
+	}
+
+	if len(dumped) != 1 || dumped[0].Name != "custom_ns_things_total" ||
+		len(dumped[0].Metric) != 1 || dumped[0].Metric[0].Counter.Value != 3 {
+		t.Errorf("expected the server's counter under its namespace, got %s", out.String())
+	}
+}
+
+func TestDumpFailsOnAnErrorStatus(t *testing.T) {
+	timeout = time.Second
+
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	if _, err := scrape(context.Background(), srv.URL); err == nil {
+		t.Error("expected scraping a missing endpoint to fail")
+	}
+}
@@ -0,0 +1,137 @@
+package ping
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+
+	rootCmd "github.com/metal-toolbox/fleet-rest-skeleton/cmd"
+	"github.com/metal-toolbox/fleet-rest-skeleton/internal/app"
+)
+
+var timeout time.Duration
+
+// dependency is a single connectivity check run by the ping command
+type dependency struct {
+	name  string
+	check func(context.Context) error
+}
+
+// install ping command
+var pingCmd = &cobra.Command{
+	Use:   "ping",
+	Short: "Check connectivity to configured dependencies",
+	Run: func(c *cobra.Command, args []string) {
+		cfg, err := app.LoadTypedConfiguration(rootCmd.CfgType, rootCmd.CfgFiles...)
+		if err != nil {
+			log.Fatalf("loading configuration: %s", err.Error())
+		}
+
+		if !report(c.Context(), os.Stdout, dependencies(c.Context(), cfg, app.DependencyOptions(cfg)...)) {
+			os.Exit(1)
+		}
+	},
+}
+
+// dependencies composes a check for every dependency present in the configuration,
+// the clients handed to the App by opts being checked through their health checks
+func dependencies(ctx context.Context, cfg *app.Configuration, opts ...app.Option) []dependency {
+	deps := []dependency{}
+
+	for _, ac := range cfg.JWTAuth {
+		if !ac.Enabled || ac.JWKSURI == "" {
+			continue
+		}
+
+		uri := ac.JWKSURI
+		deps = append(deps, dependency{
+			name:  "jwks " + uri,
+			check: func(ctx context.Context) error { return httpGet(ctx, uri) },
+		})
+	}
+
+	checks := app.NewApp(ctx, cfg, zap.NewNop(), opts...).HealthChecks()
+	// prewarming isn't a dependency, and never runs here
+	delete(checks, app.PrewarmCheck)
+
+	names := make([]string, 0, len(checks))
+	for name := range checks {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		deps = append(deps, dependency{
+			name:  name,
+			check: checks[name],
+		})
+	}
+
+	return deps
+}
+
+// report runs every check and writes a pass/fail table to w, returning whether all
+// checks passed
+func report(ctx context.Context, w io.Writer, deps []dependency) bool {
+	if len(deps) == 0 {
+		fmt.Fprintln(w, "no dependencies configured")
+		return true
+	}
+
+	ok := true
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "DEPENDENCY\tSTATUS\tDETAIL")
+
+	for _, dep := range deps {
+		checkCtx, cancel := context.WithTimeout(ctx, timeout)
+		err := dep.check(checkCtx)
+		cancel()
+
+		if err != nil {
+			ok = false
+			fmt.Fprintf(tw, "%s\tFAIL\t%s\n", dep.name, err.Error())
+			continue
+		}
+		fmt.Fprintf(tw, "%s\tPASS\t\n", dep.name)
+	}
+
+	//nolint:errcheck
+	tw.Flush()
+
+	return ok
+}
+
+func httpGet(ctx context.Context, uri string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, http.NoBody)
+	if err != nil {
+		return errors.Wrap(err, "composing request")
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.New("unexpected status " + resp.Status)
+	}
+
+	return nil
+}
+
+// install command flags
+func init() {
+	rootCmd.RootCmd.AddCommand(pingCmd)
+	pingCmd.Flags().DurationVar(&timeout, "timeout", 5*time.Second, "timeout for each dependency check")
+}
@@ -0,0 +1,85 @@
+package ping
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/metal-toolbox/fleet-rest-skeleton/internal/app"
+	"go.hollow.sh/toolbox/ginjwt"
+)
+
+// stubFleetDB is a FleetDB client whose ping fails with err when set
+type stubFleetDB struct {
+	err error
+}
+
+func (s stubFleetDB) Ping(context.Context) error {
+	return s.err
+}
+
+func TestReport(t *testing.T) {
+	timeout = time.Second
+
+	jwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"keys":[]}`))
+	}))
+	defer jwks.Close()
+
+	cfg := &app.Configuration{
+		JWTAuth: []ginjwt.AuthConfig{{Enabled: true, Issuer: "issuer", JWKSURI: jwks.URL}},
+	}
+
+	tests := []struct {
+		name    string
+		fleetDB stubFleetDB
+		wantOK  bool
+		want    []string
+	}{
+		{
+			name:   "all pass",
+			wantOK: true,
+			want:   []string{"jwks " + jwks.URL + " PASS", "fleetdb PASS"},
+		},
+		{
+			name:    "fleetdb down",
+			fleetDB: stubFleetDB{err: errors.New("connection refused")},
+			want:    []string{"jwks " + jwks.URL + " PASS", "fleetdb FAIL connection refused"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+			deps := dependencies(context.Background(), cfg, app.WithFleetDBClient(tt.fleetDB))
+
+			if ok := report(context.Background(), &out, deps); ok != tt.wantOK {
+				t.Errorf("report() = %t, want %t", ok, tt.wantOK)
+			}
+
+			// columns are aligned with a varying number of spaces
+			table := strings.Join(strings.Fields(out.String()), " ")
+			for _, line := range tt.want {
+				if !strings.Contains(table, line) {
+					t.Errorf("table lacks %q:\n%s", line, out.String())
+				}
+			}
+		})
+	}
+}
+
+func TestReportWithoutDependencies(t *testing.T) {
+	var out strings.Builder
+
+	if !report(context.Background(), &out, dependencies(context.Background(), &app.Configuration{})) {
+		t.Error("report() failed without any dependency")
+	}
+
+	if !strings.Contains(out.String(), "no dependencies configured") {
+		t.Errorf("unexpected output %q", out.String())
+	}
+}
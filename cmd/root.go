@@ -21,10 +21,13 @@ import (
 
 	"github.com/metal-toolbox/fleet-rest-skeleton/internal/version"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 var (
-	CfgFile string
+	// CfgFiles are merged in order, later files overriding earlier ones
+	CfgFiles []string
+	CfgType  string
 )
 
 // RootCmd represents the base command when called without any subcommands
@@ -32,6 +35,8 @@ var RootCmd = &cobra.Command{
 	Use:     "fleet-rest-skeleton",
 	Short:   "basic rest server template",
 	Version: version.Current().String(),
+	// replaced by the completion command
+	CompletionOptions: cobra.CompletionOptions{DisableDefaultCmd: true},
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
@@ -44,6 +49,13 @@ func Execute() {
 }
 
 func init() {
+	RootCmd.PersistentFlags().StringArrayVar(
+		&CfgFiles, "config", []string{"/etc/skeleton/config.yaml"},
+		"configuration file, - to read from stdin. Repeat to overlay files, later files taking precedence")
 	RootCmd.PersistentFlags().StringVar(
-		&CfgFile, "config", "/etc/skeleton/config.yaml", "configuration file")
+		&CfgType, "config-type", "", "configuration format (yaml, json, ...), inferred from the file extension when unset")
+
+	_ = RootCmd.RegisterFlagCompletionFunc("config-type",
+		cobra.FixedCompletions(viper.SupportedExts, cobra.ShellCompDirectiveNoFileComp))
+	_ = RootCmd.MarkPersistentFlagFilename("config", viper.SupportedExts...)
 }
@@ -7,76 +7,154 @@ import (
 	"net/http"
 	"time"
 
-	"github.com/equinix-labs/otel-init-go/otelinit"
 	"go.uber.org/zap"
 
 	rootCmd "github.com/metal-toolbox/fleet-rest-skeleton/cmd"
 	"github.com/metal-toolbox/fleet-rest-skeleton/internal/app"
 	"github.com/metal-toolbox/fleet-rest-skeleton/internal/metrics"
+	"github.com/metal-toolbox/fleet-rest-skeleton/internal/tracing"
 	"github.com/metal-toolbox/fleet-rest-skeleton/internal/version"
 	"github.com/metal-toolbox/fleet-rest-skeleton/pkg/api/routes"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/spf13/cobra"
 )
 
-var shutdownTimeout = 10 * time.Second
-
 // install server command
 var serverCmd = &cobra.Command{
 	Use:   "server",
 	Short: "Run API service",
 	Run: func(c *cobra.Command, args []string) {
-		cfg, err := app.LoadConfiguration(rootCmd.CfgFile)
+		cfg, err := app.LoadTypedConfiguration(rootCmd.CfgType, rootCmd.CfgFiles...)
 		if err != nil {
 			log.Fatalf("loading configuration: %s", err.Error())
 		}
 
-		logger := app.GetLogger(cfg.DeveloperMode)
+		app.ConfigureRuntime(cfg)
+
+		logger, logLevel := app.GetLogger(cfg)
 		//nolint:errcheck
 		defer logger.Sync()
 
-		// XXX: Read NATS and or FleetDB Config
-
-		// XXX: add NATS client
-		// XXX: add FleetDB client
+		listener, err := app.Listen(c.Context(), cfg)
+		if err != nil {
+			logger.Fatal("opening API listener",
+				zap.Error(err),
+			)
+		}
 
 		ctx, appCancel := context.WithCancel(c.Context())
-		app := app.NewApp(ctx, cfg, logger)
+		app := app.NewApp(ctx, cfg, logger, append(app.DependencyOptions(cfg), app.WithLogLevel(logLevel))...)
 
-		metrics.ListenAndServe()
+		if err := metrics.Init(cfg.MetricsNamespace, prometheus.NewRegistry()); err != nil {
+			logger.Fatal("initializing metrics",
+				zap.Error(err),
+			)
+		}
 
-		// the ignored parameter here is a context annotated with otel-init-go configuration
-		_, otelShutdown := otelinit.InitOpenTelemetry(c.Context(), "skeleton-api-server")
+		if err := metrics.RegisterRuntimeMetrics(); err != nil {
+			logger.Warn("registering runtime metrics", zap.Error(err))
+		}
+		metrics.RegisterBuildInfo(version.Current())
+		metricsShutdown := metrics.ListenAndServe(cfg.MetricsMaxConnections)
+
+		var otelMetricsShutdown func(context.Context) error
+		if cfg.OTELMetrics.Enabled() {
+			otelMetricsShutdown, err = metrics.ExportOTEL(c.Context(), cfg.OTELMetrics)
+			if err != nil {
+				logger.Fatal("exporting metrics over OTLP",
+					zap.Error(err),
+				)
+			}
+		}
+
+		tracingShutdown, err := tracing.Init(c.Context(), cfg.Tracing)
+		if err != nil {
+			logger.Fatal("initializing tracing",
+				zap.Error(err),
+			)
+		}
 
-		logger.Info("app initialized",
+		app.Verbose().Info("app initialized",
 			zap.String("version", version.Current().String()),
 		)
 
 		srv := routes.ComposeHTTPServer(app)
+
+		// readiness fails until dependency connections are prewarmed
 		go func() {
-			if err := srv.ListenAndServe(); err != nil && errors.Is(err, http.ErrServerClosed) {
+			_ = app.Prewarm(app.Context())
+		}()
+
+		// in order: stop taking requests, then release what serving them depends on
+		app.RegisterShutdownHook("api server", srv.Shutdown)
+		app.RegisterShutdownHook("metrics server", metricsShutdown)
+		if otelMetricsShutdown != nil {
+			app.RegisterShutdownHook("otel metrics", otelMetricsShutdown)
+		}
+		app.RegisterShutdownHook("tracer", tracingShutdown)
+		app.LogStarting(listener.Addr())
+
+		go func() {
+			serve := func() error { return srv.Serve(listener) }
+			if cfg.TLS.Enabled() {
+				serve = func() error { return srv.ServeTLS(listener, cfg.TLS.CertFile, cfg.TLS.KeyFile) }
+			}
+
+			if err := serve(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 				logger.Fatal("error serving API",
 					zap.Error(err),
 				)
 			}
 		}()
 
-		app.WaitForSignal()
-		logger.Info("signaled to terminate")
+		sig := app.WaitForSignal()
+		app.Verbose().Info("signaled to terminate",
+			zap.Stringer("signal", sig),
+		)
 		appCancel()
 
-		// call server shutdown with timeout
-		ctx, cancel := context.WithTimeout(c.Context(), shutdownTimeout)
-		defer cancel()
-		if err := srv.Shutdown(ctx); err != nil {
+		shutdownErr := shutdown(c.Context(), app)
+
+		drain := "clean"
+		if errors.Is(shutdownErr, context.DeadlineExceeded) {
+			drain = "timed_out"
+		}
+
+		app.LogStopped(sig.String(),
+			zap.Uint64("requests_served", metrics.RequestsServed()),
+			zap.String("drain", drain),
+		)
+
+		if shutdownErr != nil {
 			logger.Fatal("server shutdown error",
-				zap.Error(err),
+				zap.Error(shutdownErr),
 			)
 		}
-		otelShutdown(ctx)
-		logger.Info("OK, done.")
+		app.Verbose().Info("OK, done.")
 	},
 }
 
+// shutdown runs the App's shutdown hooks, bounded by the configured shutdown timeout,
+// once the configured drain delay has passed. New requests are turned away while
+// waiting, as the App is already shutting down.
+func shutdown(ctx context.Context, a *app.App) error {
+	if a.Cfg.ShutdownDrainDelay > 0 {
+		a.Verbose().Info("draining before shutting down",
+			zap.Duration("delay", a.Cfg.ShutdownDrainDelay),
+		)
+
+		select {
+		case <-time.After(a.Cfg.ShutdownDrainDelay):
+		case <-ctx.Done():
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, a.Cfg.ShutdownTimeout)
+	defer cancel()
+
+	return a.Shutdown(ctx)
+}
+
 // install command flags
 func init() {
 	rootCmd.RootCmd.AddCommand(serverCmd)
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/metal-toolbox/fleet-rest-skeleton/internal/app"
+)
+
+func TestShutdownIsBoundedByTheConfiguredTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		want    time.Duration
+	}{
+		{"unset", 0, app.DefaultShutdownTimeout},
+		{"custom", 3 * time.Second, 3 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := app.NewAppFromConfig(context.Background(), &app.Configuration{
+				ListenAddress:   "127.0.0.1:0",
+				ShutdownTimeout: tt.timeout,
+			})
+			if err != nil {
+				t.Fatalf("composing app: %v", err)
+			}
+
+			var deadline time.Time
+			a.RegisterShutdownHook("capture", func(ctx context.Context) error {
+				deadline, _ = ctx.Deadline()
+				return nil
+			})
+
+			start := time.Now()
+			if err := shutdown(context.Background(), a); err != nil {
+				t.Fatalf("shutting down: %v", err)
+			}
+
+			if got := deadline.Sub(start); got < tt.want || got > tt.want+time.Second {
+				t.Errorf("expected the shutdown deadline %v away, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestShutdownWaitsForTheDrainDelay(t *testing.T) {
+	const delay = 200 * time.Millisecond
+
+	a, err := app.NewAppFromConfig(context.Background(), &app.Configuration{
+		ListenAddress:      "127.0.0.1:0",
+		ShutdownTimeout:    time.Second,
+		ShutdownDrainDelay: delay,
+	})
+	if err != nil {
+		t.Fatalf("composing app: %v", err)
+	}
+
+	var (
+		calledAt time.Time
+		deadline time.Time
+	)
+	a.RegisterShutdownHook("capture", func(ctx context.Context) error {
+		calledAt = time.Now()
+		deadline, _ = ctx.Deadline()
+		return nil
+	})
+
+	start := time.Now()
+	if err := shutdown(context.Background(), a); err != nil {
+		t.Fatalf("shutting down: %v", err)
+	}
+
+	if got := calledAt.Sub(start); got < delay {
+		t.Errorf("expected the hooks to run once the drain delay passed, ran after %v", got)
+	}
+
+	if got := deadline.Sub(calledAt); got < 900*time.Millisecond {
+		t.Errorf("expected the shutdown timeout not to include the drain delay, %v was left", got)
+	}
+}
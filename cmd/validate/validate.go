@@ -0,0 +1,53 @@
+package validate
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"text/tabwriter"
+
+	"github.com/metal-toolbox/fleet-rest-skeleton/cmd"
+	"github.com/metal-toolbox/fleet-rest-skeleton/internal/app"
+	"github.com/spf13/cobra"
+)
+
+// install validate command
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check the configuration loads and is valid, including environment overrides",
+	Run: func(c *cobra.Command, args []string) {
+		cfg, err := app.LoadTypedConfiguration(cmd.CfgType, cmd.CfgFiles...)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid configuration: %s\n", err.Error())
+			os.Exit(1)
+		}
+
+		report(os.Stdout, cfg)
+	},
+}
+
+// report lists the configuration keys that were set along with their source
+func report(w io.Writer, cfg *app.Configuration) {
+	sources := cfg.KeySources()
+
+	keys := make([]string, 0, len(sources))
+	for k := range sources {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "KEY\tSOURCE")
+
+	for _, k := range keys {
+		fmt.Fprintf(tw, "%s\t%s\n", k, sources[k])
+	}
+	tw.Flush()
+
+	fmt.Fprintln(w, "configuration is valid")
+}
+
+func init() {
+	cmd.RootCmd.AddCommand(validateCmd)
+}
@@ -0,0 +1,87 @@
+package validate
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/metal-toolbox/fleet-rest-skeleton/cmd"
+	"github.com/metal-toolbox/fleet-rest-skeleton/internal/app"
+)
+
+// writeConfig writes a config file holding contents, returning its path
+func writeConfig(t *testing.T, contents string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatalf("writing config: %v", err)
+	}
+
+	return path
+}
+
+func TestReportListsKeySources(t *testing.T) {
+	t.Setenv("SKELETON_LOG_LEVEL", "debug")
+
+	cfg, err := app.LoadConfiguration(writeConfig(t, "listen_address: 127.0.0.1:7500\n"))
+	if err != nil {
+		t.Fatalf("loading config: %v", err)
+	}
+
+	var out bytes.Buffer
+	report(&out, cfg)
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	fields := make(map[string]string)
+	for _, l := range lines {
+		if f := strings.Fields(l); len(f) == 2 {
+			fields[f[0]] = f[1]
+		}
+	}
+
+	if fields["listen_address"] != app.ConfigSourceFile || fields["log_level"] != app.ConfigSourceEnv {
+		t.Errorf("expected each key listed with its source, got %q", out.String())
+	}
+
+	if lines[len(lines)-1] != "configuration is valid" {
+		t.Errorf("expected the report to end with the verdict, got %q", out.String())
+	}
+}
+
+func TestInvalidConfigurationFailsToLoad(t *testing.T) {
+	_, err := app.LoadConfiguration(writeConfig(t, "listen_address: 127.0.0.1:7500\nlog_level: loud\n"))
+	if err == nil || !strings.Contains(err.Error(), "log_level") {
+		t.Errorf("expected the invalid key to be reported, got %v", err)
+	}
+}
+
+func TestConfigFromStdinFlags(t *testing.T) {
+	prevFiles, prevType := cmd.CfgFiles, cmd.CfgType
+	t.Cleanup(func() { cmd.CfgFiles, cmd.CfgType = prevFiles, prevType })
+
+	if err := cmd.RootCmd.PersistentFlags().Parse([]string{"--config", "-", "--config-type", "json"}); err != nil {
+		t.Fatalf("parsing flags: %v", err)
+	}
+
+	stdin, err := os.Open(writeConfig(t, `{"listen_address": "127.0.0.1:7500"}`))
+	if err != nil {
+		t.Fatalf("opening stdin: %v", err)
+	}
+	defer stdin.Close()
+
+	prevStdin := os.Stdin
+	os.Stdin = stdin
+	t.Cleanup(func() { os.Stdin = prevStdin })
+
+	cfg, err := app.LoadTypedConfiguration(cmd.CfgType, cmd.CfgFiles...)
+	if err != nil {
+		t.Fatalf("loading config: %v", err)
+	}
+
+	if cfg.ListenAddress != "127.0.0.1:7500" {
+		t.Errorf("expected the configuration to be read from stdin, got listen address %q", cfg.ListenAddress)
+	}
+}
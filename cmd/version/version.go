@@ -1,34 +1,61 @@
 package version
 
 import (
-	"bytes"
 	"encoding/json"
 	"fmt"
-	"os"
+	"io"
 
 	"github.com/metal-toolbox/fleet-rest-skeleton/cmd"
 	"github.com/metal-toolbox/fleet-rest-skeleton/internal/version"
 	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
 )
 
-var extended bool
+const (
+	formatText = "text"
+	formatJSON = "json"
+	formatYAML = "yaml"
+)
+
+var (
+	format   string
+	extended bool
+)
 
 var versionCmd = &cobra.Command{
 	Use:   "version",
 	Short: "get the current version",
-	Run: func(c *cobra.Command, args []string) {
-		//nolint:errcheck
+	RunE: func(c *cobra.Command, args []string) error {
 		if extended {
-			var out bytes.Buffer
-			json.Indent(&out, version.Current().MustJSON(), "", "  ")
-			out.WriteTo(os.Stdout)
-			return
+			format = formatJSON
 		}
-		fmt.Printf("%s\n", version.Current().String())
+		return write(c.OutOrStdout(), format, version.Current())
 	},
 }
 
+// write renders the version to w in the requested format
+func write(w io.Writer, f string, v *version.Version) error {
+	switch f {
+	case formatText:
+		_, err := fmt.Fprintf(w, "%s\n", v.String())
+		return err
+	case formatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	case formatYAML:
+		enc := yaml.NewEncoder(w)
+		defer enc.Close()
+		return enc.Encode(v)
+	default:
+		return fmt.Errorf("unsupported format %q, expected one of %s, %s or %s", f, formatText, formatJSON, formatYAML)
+	}
+}
+
 func init() {
 	cmd.RootCmd.AddCommand(versionCmd)
-	versionCmd.Flags().BoolVarP(&extended, "extended", "e", false, "extended build version info")
+	versionCmd.Flags().StringVarP(&format, "format", "f", formatText, "output format: text, json or yaml")
+	_ = versionCmd.RegisterFlagCompletionFunc("format",
+		cobra.FixedCompletions([]string{formatText, formatJSON, formatYAML}, cobra.ShellCompDirectiveNoFileComp))
+	versionCmd.Flags().BoolVarP(&extended, "extended", "e", false, "extended build version info, alias for --format json")
 }
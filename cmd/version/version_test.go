@@ -0,0 +1,80 @@
+package version
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/metal-toolbox/fleet-rest-skeleton/internal/version"
+	"gopkg.in/yaml.v3"
+)
+
+func TestVersionFormats(t *testing.T) {
+	prev := version.AppVersion
+	version.AppVersion = "v1.2.3"
+	t.Cleanup(func() { version.AppVersion = prev })
+
+	want := version.Current()
+
+	decodeJSON := func(out string) (*version.Version, error) {
+		var v version.Version
+		return &v, json.Unmarshal([]byte(out), &v)
+	}
+
+	decodeYAML := func(out string) (*version.Version, error) {
+		var v version.Version
+		return &v, yaml.Unmarshal([]byte(out), &v)
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		decode  func(string) (*version.Version, error)
+		wantErr bool
+	}{
+		{name: "default", args: nil},
+		{name: "text", args: []string{"--format", "text"}},
+		{name: "json", args: []string{"--format", "json"}, decode: decodeJSON},
+		{name: "yaml", args: []string{"-f", "yaml"}, decode: decodeYAML},
+		{name: "extended alias", args: []string{"-e"}, decode: decodeJSON},
+		{name: "unsupported", args: []string{"--format", "xml"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			format, extended = formatText, false
+
+			if err := versionCmd.ParseFlags(tt.args); err != nil {
+				t.Fatalf("parsing flags: %v", err)
+			}
+
+			var out strings.Builder
+			versionCmd.SetOut(&out)
+
+			err := versionCmd.RunE(versionCmd, nil)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("expected an error %v, got %v", tt.wantErr, err)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if tt.decode == nil {
+				if got := out.String(); got != want.String()+"\n" {
+					t.Errorf("expected %q, got %q", want.String()+"\n", got)
+				}
+				return
+			}
+
+			got, err := tt.decode(out.String())
+			if err != nil {
+				t.Fatalf("parsing %q: %v", out.String(), err)
+			}
+
+			if *got != *want {
+				t.Errorf("expected %+v, got %+v", want, got)
+			}
+		})
+	}
+}
@@ -2,25 +2,58 @@ package app
 
 import (
 	"context"
+	"encoding/json"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/spf13/viper"
+	"go.hollow.sh/toolbox/ginjwt"
+	"go.uber.org/multierr"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
 
 const AppName = "skeleton"
 
+// StdinConfig is the config file name that reads the configuration from standard input
+const StdinConfig = "-"
+
 type App struct {
-	Log  *zap.Logger
-	Cfg  *Configuration
-	ctx  context.Context
-	term <-chan os.Signal
-	opts map[string]any
+	Log     *zap.Logger
+	Cfg     *Configuration
+	verbose *zap.Logger
+	level   zap.AtomicLevel
+	// levelBound is set when level is the level of the logger, so changing it applies
+	levelBound bool
+	ctx        context.Context
+	term       <-chan os.Signal
+	signals    []os.Signal
+	opts       map[string]any
+	started    time.Time
+	// shuttingDown is set once signaled to terminate or Shutdown is called
+	shuttingDown atomic.Bool
+
+	mu         sync.Mutex
+	hooks      []shutdownHook
+	checks     []healthCheck
+	prewarmers []prewarmer
+	prewarm    prewarmState
+}
+
+// shutdownHook releases a resource held by the App when shutting down
+type shutdownHook struct {
+	name string
+	fn   func(context.Context) error
 }
 
 // Option provides a path for adding arbitrary stuff to an App.
@@ -33,27 +66,160 @@ func NewOption(key string, opt any) Option {
 	}
 }
 
+// GetOption returns the option stored under key in the App. The zero value and false
+// are returned when there is no such option or it isn't of type T.
+func GetOption[T any](a *App, key string) (T, bool) {
+	v, ok := a.opts[key].(T)
+	return v, ok
+}
+
 // NewApp composes the provided Configuration and Logger into a new App object
 func NewApp(ctx context.Context, cfg *Configuration, log *zap.Logger, opts ...Option) *App {
-	termChan := make(chan os.Signal, 1)
-	signal.Notify(termChan, syscall.SIGINT, syscall.SIGTERM)
 	app := &App{
-		Log:  log,
-		Cfg:  cfg,
-		ctx:  ctx,
-		term: termChan,
+		Log:     log,
+		Cfg:     cfg,
+		verbose: log,
+		level:   zap.NewAtomicLevelAt(log.Level()),
+		ctx:     ctx,
+		opts:    make(map[string]any),
+		signals: []os.Signal{syscall.SIGINT, syscall.SIGTERM},
+		started: time.Now(),
+	}
+
+	if cfg.QuietStartup {
+		app.verbose = zap.NewNop()
+	}
+
+	if cfg.Prewarm.Connections > 0 {
+		// not ready until Prewarm has run
+		app.prewarm.running = true
+		app.RegisterHealthCheck(PrewarmCheck, app.checkPrewarm)
 	}
 
 	for _, opt := range opts {
 		opt(app)
 	}
 
+	termChan := make(chan os.Signal, 1)
+	signal.Notify(termChan, app.signals...)
+	app.term = termChan
+
 	return app
 }
 
-// WaitForSignal blocks on the Server's internal signal channel until we catch SIGTERM or SIGINT
-func (a *App) WaitForSignal() {
-	<-a.term
+// NewAppFromConfig composes an App from a Configuration built in memory, rather than
+// loaded from files, logging with a logger built from it. Defaults are applied to
+// cfg before it is validated, zero values standing in for those left out of a config
+// file unless their keys are passed to MarkSet, so e.g. a WriteTimeout of 0 takes the
+// default unless "write_timeout" is marked as set. The environment isn't consulted.
+func NewAppFromConfig(ctx context.Context, cfg *Configuration, opts ...Option) (*App, error) {
+	applyDefaults(cfg, cfg.isSet)
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	logger, lvl := GetLogger(cfg)
+
+	return NewApp(ctx, cfg, logger, append([]Option{WithLogLevel(lvl)}, opts...)...), nil
+}
+
+// WithLogLevel binds the level changed through LogLevel, which must be the level of
+// the App's logger as returned by GetLogger
+func WithLogLevel(lvl zap.AtomicLevel) Option {
+	return func(a *App) {
+		a.level = lvl
+		a.levelBound = true
+	}
+}
+
+// WithSignals replaces the signals WaitForSignal returns on, SIGINT and SIGTERM by
+// default. Passing no signals keeps the default, as signal.Notify would otherwise
+// relay every incoming signal.
+func WithSignals(sigs ...os.Signal) Option {
+	return func(a *App) {
+		if len(sigs) > 0 {
+			a.signals = sigs
+		}
+	}
+}
+
+// WaitForSignal blocks on the Server's internal signal channel until we catch one of
+// the App's signals, SIGTERM or SIGINT unless set with WithSignals, and returns it.
+// The App reports it is shutting down from the moment the signal arrives.
+func (a *App) WaitForSignal() os.Signal {
+	sig := <-a.term
+	a.shuttingDown.Store(true)
+
+	return sig
+}
+
+// Verbose returns the logger for informational startup and lifecycle messages, which
+// discards them when the configuration asks for a quiet startup
+func (a *App) Verbose() *zap.Logger {
+	return a.verbose
+}
+
+// LogLevel returns the level of the App's logger, which may be changed while it's in
+// use, and true when bound with WithLogLevel. Otherwise the level the logger had when
+// the App was composed is returned along with false, as changing it would have no
+// effect on the logger.
+func (a *App) LogLevel() (zap.AtomicLevel, bool) {
+	return a.level, a.levelBound
+}
+
+// Uptime returns how long ago the App was created
+func (a *App) Uptime() time.Duration {
+	return time.Since(a.started)
+}
+
+// RegisterShutdownHook adds a function to be called by Shutdown. Hooks are called in
+// the order they were registered, so register anything accepting work (e.g. the API
+// server) ahead of what that work depends on.
+func (a *App) RegisterShutdownHook(name string, fn func(context.Context) error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.hooks = append(a.hooks, shutdownHook{name: name, fn: fn})
+}
+
+// Shutdown calls every registered shutdown hook in order, all sharing the deadline of
+// ctx, and returns their combined errors. Hooks are still called once ctx has expired
+// so that each can release what it can. The App reports it is shutting down from the
+// moment Shutdown is called.
+func (a *App) Shutdown(ctx context.Context) error {
+	a.shuttingDown.Store(true)
+
+	a.mu.Lock()
+	hooks := a.hooks
+	a.mu.Unlock()
+
+	var err error
+	for _, h := range hooks {
+		start := time.Now()
+		hookErr := h.fn(ctx)
+
+		fields := []zap.Field{
+			zap.String("hook", h.name),
+			zap.Duration("duration", time.Since(start)),
+		}
+
+		if hookErr != nil {
+			a.Log.Warn("shutdown hook failed", append(fields, zap.Error(hookErr))...)
+			err = multierr.Append(err, errors.Wrap(hookErr, h.name))
+			continue
+		}
+
+		a.verbose.Info("shutdown hook complete", fields...)
+	}
+
+	return err
+}
+
+// ShuttingDown indicates whether the App has been signaled to terminate or Shutdown
+// has been called, new work should be turned away while in-flight work drains
+func (a *App) ShuttingDown() bool {
+	return a.shuttingDown.Load()
 }
 
 // ContextDone indicates whether an App's internal context has expired or been canceled
@@ -63,38 +229,124 @@ func (a *App) ContextDone() bool {
 	return a.ctx.Err() != nil
 }
 
-// LoadConfiguration opens and parses the configuration file and then applies any
-// environmental overrides
-func LoadConfiguration(cfgFile string) (*Configuration, error) {
+// Context returns the App's internal context, which is canceled on SIGTERM or SIGINT.
+// Handlers and background workers should derive their contexts from it to stop work
+// when the App is told to go.
+func (a *App) Context() context.Context {
+	return a.ctx
+}
+
+// LoadConfiguration opens and parses the configuration files and then applies any
+// environmental overrides. Files are merged in order, values in later files
+// overriding those in earlier ones.
+func LoadConfiguration(cfgFiles ...string) (*Configuration, error) {
+	return LoadTypedConfiguration("", cfgFiles...)
+}
+
+// LoadTypedConfiguration is LoadConfiguration for config files of the given type
+// (e.g. yaml or json). A cfgFile of "-" reads the configuration from standard input.
+// When cfgType is empty it is inferred from each file's extension, defaulting to yaml.
+func LoadTypedConfiguration(cfgType string, cfgFiles ...string) (*Configuration, error) {
+	if len(cfgFiles) == 0 {
+		return nil, errors.New("no config file given")
+	}
+
 	v := viper.New()
-	v.SetConfigType("yaml")
 	v.SetEnvPrefix(AppName)
 	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
 	v.AutomaticEnv()
 
 	cfg := &Configuration{}
 
-	fh, err := os.Open(cfgFile)
-	if err != nil {
-		return nil, errors.Wrap(err, "opening config file "+cfgFile)
-	}
-
-	if err = v.ReadConfig(fh); err != nil {
-		return nil, errors.Wrap(err, "reading config "+cfgFile)
+	for _, cfgFile := range cfgFiles {
+		if err := mergeConfigFile(v, cfgType, cfgFile); err != nil {
+			return nil, err
+		}
 	}
 
 	if err := v.Unmarshal(cfg); err != nil {
 		return nil, errors.Wrap(err, "unmarshaling config")
 	}
 
+	cfg.keySources = keySources(v)
+
 	// for injected overrides like secrets
 	if err := envVarOverrides(v, cfg); err != nil {
 		return nil, errors.Wrap(err, "configuring environment orverrides")
 	}
 
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
 	return cfg, nil
 }
 
+// mergeConfigFile merges the configuration in cfgFile into v, overriding the values
+// read so far
+func mergeConfigFile(v *viper.Viper, cfgType, cfgFile string) error {
+	fh := os.Stdin
+	if cfgFile != StdinConfig {
+		var err error
+		if fh, err = os.Open(cfgFile); err != nil {
+			return errors.Wrap(err, "opening config file "+cfgFile)
+		}
+		defer fh.Close()
+	}
+
+	v.SetConfigType(configType(cfgType, cfgFile))
+	if err := v.MergeConfig(fh); err != nil {
+		return errors.Wrap(err, "reading config "+cfgFile)
+	}
+
+	return nil
+}
+
+// keySources maps every configuration key set to where its value was taken from,
+// either ConfigSourceEnv or ConfigSourceFile
+func keySources(v *viper.Viper) map[string]string {
+	replacer := strings.NewReplacer(".", "_")
+	prefix := strings.ToUpper(AppName) + "_"
+
+	sources := make(map[string]string)
+	// the environment variable overriding each key
+	envKeys := make(map[string]bool)
+
+	for _, key := range v.AllKeys() {
+		env := prefix + strings.ToUpper(replacer.Replace(key))
+		envKeys[env] = true
+
+		sources[key] = ConfigSourceFile
+		if _, ok := os.LookupEnv(env); ok {
+			sources[key] = ConfigSourceEnv
+		}
+	}
+
+	// keys only set in the environment are unknown to viper
+	for _, kv := range os.Environ() {
+		env, _, _ := strings.Cut(kv, "=")
+		if strings.HasPrefix(env, prefix) && !envKeys[env] {
+			sources[strings.ToLower(strings.TrimPrefix(env, prefix))] = ConfigSourceEnv
+		}
+	}
+
+	return sources
+}
+
+// configType returns the explicit config type, or the one implied by the file
+// extension, falling back to yaml
+func configType(cfgType, cfgFile string) string {
+	if cfgType != "" {
+		return cfgType
+	}
+
+	if ext := strings.TrimPrefix(filepath.Ext(cfgFile), "."); slices.Contains(viper.SupportedExts, ext) {
+		return ext
+	}
+
+	return "yaml"
+}
+
 func envVarOverrides(v *viper.Viper, cfg *Configuration) error {
 	if addr := v.GetString("listen.address"); addr != "" {
 		cfg.ListenAddress = addr
@@ -103,25 +355,191 @@ func envVarOverrides(v *viper.Viper, cfg *Configuration) error {
 	if v.GetBool("developer.mode") {
 		cfg.DeveloperMode = true
 	}
+
+	if cfg.Tracing.Endpoint == "" {
+		cfg.Tracing.Endpoint = os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+	}
+
+	if insecure := os.Getenv("OTEL_EXPORTER_OTLP_INSECURE"); !v.IsSet("tracing.insecure") && insecure != "" {
+		var err error
+		if cfg.Tracing.Insecure, err = strconv.ParseBool(insecure); err != nil {
+			return errors.Wrap(err, "OTEL_EXPORTER_OTLP_INSECURE")
+		}
+	}
+
 	// secrets would go here
+	if err := jwtAuthOverrides(v, cfg); err != nil {
+		return err
+	}
+
+	applyDefaults(cfg, v.IsSet)
+
+	return nil
+}
+
+// applyDefaults fills in the defaults of configuration values left unset, isSet
+// telling whether the value of a key was given where zero is a meaningful value
+func applyDefaults(cfg *Configuration, isSet func(key string) bool) {
+	if cfg.ServiceName == "" {
+		cfg.ServiceName = AppName
+	}
+
+	if cfg.BasePath == "" {
+		cfg.BasePath = "/"
+	}
+
+	if cfg.MetricsMaxConnections == 0 {
+		cfg.MetricsMaxConnections = DefaultMetricsMaxConnections
+	}
+
+	if cfg.MetricsNamespace == "" {
+		cfg.MetricsNamespace = AppName
+	}
+
+	if cfg.MaxBatchSize == 0 {
+		cfg.MaxBatchSize = DefaultMaxBatchSize
+	}
+
+	if cfg.MaxRequestBytes == 0 {
+		cfg.MaxRequestBytes = DefaultMaxRequestBytes
+	}
+
+	if cfg.MaxResponseHeaderBytes == 0 {
+		cfg.MaxResponseHeaderBytes = DefaultMaxResponseHeaderBytes
+	}
+
+	if cfg.MaxConcurrentStreams == 0 {
+		cfg.MaxConcurrentStreams = DefaultMaxConcurrentStreams
+	}
+
+	if !isSet("redact_params") {
+		cfg.RedactParams = DefaultRedactParams
+	}
+
+	if cfg.ShutdownTimeout == 0 {
+		cfg.ShutdownTimeout = DefaultShutdownTimeout
+	}
 
-	// sanity checks
-	if cfg.ListenAddress == "" {
-		return errors.New("no listen address set")
+	if cfg.JWKSFetchTimeout == 0 {
+		cfg.JWKSFetchTimeout = DefaultJWKSFetchTimeout
+	}
+
+	if cfg.Audit.BufferSize == 0 {
+		cfg.Audit.BufferSize = DefaultAuditBufferSize
+	}
+
+	if cfg.Tracing.ServiceName == "" {
+		cfg.Tracing.ServiceName = DefaultTracingServiceName
+	}
+
+	if !isSet("tracing.sample_ratio") {
+		cfg.Tracing.SampleRatio = 1
+	}
+
+	if !isSet("write_timeout") {
+		cfg.WriteTimeout = DefaultWriteTimeout
+	}
+
+	// a finite write timeout terminates streaming responses
+	if cfg.StreamingEnabled && cfg.DisableWriteTimeoutForStreaming {
+		cfg.WriteTimeout = 0
+	}
+
+	// a request timing out along with the write timeout gets its connection dropped
+	// rather than a response
+	if cfg.WriteTimeout > 0 && cfg.RequestTimeout > cfg.requestTimeoutLimit() {
+		cfg.configuredRequestTimeout = cfg.RequestTimeout
+		cfg.RequestTimeout = cfg.requestTimeoutLimit()
+	}
+
+	if cfg.WriteTimeout > 0 && cfg.MaxClientRequestTimeout > cfg.requestTimeoutLimit() {
+		cfg.MaxClientRequestTimeout = cfg.requestTimeoutLimit()
+	}
+
+	if cfg.HealthResponse == "" {
+		cfg.HealthResponse = HealthResponseDetailed
+	}
+}
+
+// jwtAuthOverrides combines JWTAuth entries provided as a JSON list in the
+// SKELETON_GINJWT_AUTH_JSON environment variable with those from the config file,
+// according to the configured precedence.
+func jwtAuthOverrides(v *viper.Viper, cfg *Configuration) error {
+	cfg.jwtAuthSources = make(map[string]string)
+
+	raw := v.GetString("ginjwt_auth_json")
+	if raw == "" {
+		for _, ac := range cfg.JWTAuth {
+			cfg.jwtAuthSources[ac.Issuer] = JWTAuthSourceFile
+		}
+		return nil
+	}
+
+	var envAuth []ginjwt.AuthConfig
+	if err := json.Unmarshal([]byte(raw), &envAuth); err != nil {
+		return errors.Wrap(err, "parsing ginjwt auth from the environment")
+	}
+
+	switch cfg.JWTAuthPrecedence {
+	case "", JWTAuthReplace:
+		cfg.JWTAuth = envAuth
+	case JWTAuthMerge:
+		fromEnv := make(map[string]bool, len(envAuth))
+		for _, ac := range envAuth {
+			fromEnv[ac.Issuer] = true
+		}
+
+		merged := envAuth
+		for _, ac := range cfg.JWTAuth {
+			if !fromEnv[ac.Issuer] {
+				merged = append(merged, ac)
+				cfg.jwtAuthSources[ac.Issuer] = JWTAuthSourceFile
+			}
+		}
+		cfg.JWTAuth = merged
+	default:
+		return errors.New("unknown ginjwt auth precedence " + cfg.JWTAuthPrecedence)
+	}
+
+	for _, ac := range envAuth {
+		cfg.jwtAuthSources[ac.Issuer] = JWTAuthSourceEnv
 	}
 
 	return nil
 }
 
-// GetLogger constructs a new logger for composition within an App
-func GetLogger(dev bool) *zap.Logger {
-	if dev {
-		return zap.Must(zap.NewDevelopment(
-			zap.AddCaller(),
-			zap.AddStacktrace(zapcore.ErrorLevel),
-		))
+// ConfigureRuntime applies the Go runtime settings from the configuration
+func ConfigureRuntime(cfg *Configuration) {
+	if cfg.GoMaxProcs > 0 {
+		runtime.GOMAXPROCS(cfg.GoMaxProcs)
+	}
+}
+
+// GetLogger constructs a new logger for composition within an App, along with its
+// level, which may be changed while it's in use. Every line logged carries the
+// configured service name.
+func GetLogger(cfg *Configuration) (*zap.Logger, zap.AtomicLevel) {
+	return buildLogger(cfg)
+}
+
+// buildLogger builds the logger returned by GetLogger. The extra options are applied
+// ahead of the service name, so a core swapped in by them still carries it.
+func buildLogger(cfg *Configuration, extra ...zap.Option) (*zap.Logger, zap.AtomicLevel) {
+	service := zap.Fields(zap.String("service", cfg.ServiceName))
+
+	zc := zap.NewProductionConfig()
+	opts := []zap.Option{zap.AddCaller(), service}
+
+	if cfg.DeveloperMode {
+		zc = zap.NewDevelopmentConfig()
+		opts = append(opts, zap.AddStacktrace(zapcore.ErrorLevel))
+	}
+
+	if cfg.LogLevel != "" {
+		// validated when loading the configuration
+		lvl, _ := zapcore.ParseLevel(cfg.LogLevel)
+		zc.Level = zap.NewAtomicLevelAt(lvl)
 	}
-	return zap.Must(zap.NewProduction(
-		zap.AddCaller(),
-	))
+
+	return zap.Must(zc.Build(append(extra, opts...)...)), zc.Level
 }
@@ -0,0 +1,240 @@
+package app
+
+import (
+	"context"
+	"os"
+	"runtime"
+	"syscall"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// newTestApp returns an App for a valid configuration, discarding its logs
+func newTestApp(t *testing.T, opts ...Option) *App {
+	t.Helper()
+
+	return NewApp(context.Background(), validConfig(), zap.NewNop(), opts...)
+}
+
+func TestNewAppStoresOptions(t *testing.T) {
+	a := newTestApp(t, NewOption("answer", 42))
+
+	if v, ok := GetOption[int](a, "answer"); !ok || v != 42 {
+		t.Errorf("expected the option to be stored, got %v, %v", v, ok)
+	}
+
+	if _, ok := GetOption[string](a, "answer"); ok {
+		t.Error("expected an option of another type not to be returned")
+	}
+
+	if _, ok := GetOption[int](a, "missing"); ok {
+		t.Error("expected a missing option not to be returned")
+	}
+}
+
+func TestContextIsTheAppContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	a := NewApp(ctx, validConfig(), zap.NewNop())
+
+	if a.ContextDone() || a.Context().Err() != nil {
+		t.Fatal("expected the context to be live")
+	}
+
+	cancel()
+
+	if !a.ContextDone() || a.Context().Err() == nil {
+		t.Error("expected canceling the context given to NewApp to cancel the App's")
+	}
+}
+
+func TestWithSignals(t *testing.T) {
+	a := newTestApp(t, WithSignals(syscall.SIGUSR1))
+
+	if a.ShuttingDown() {
+		t.Fatal("expected the App not to be shutting down before the signal")
+	}
+
+	if err := syscall.Kill(os.Getpid(), syscall.SIGUSR1); err != nil {
+		t.Fatalf("signaling: %v", err)
+	}
+
+	got := make(chan os.Signal, 1)
+	go func() { got <- a.WaitForSignal() }()
+
+	select {
+	case sig := <-got:
+		if sig != syscall.SIGUSR1 {
+			t.Errorf("expected SIGUSR1, got %v", sig)
+		}
+
+		if !a.ShuttingDown() {
+			t.Error("expected the App to be shutting down once signaled")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the signal")
+	}
+}
+
+func TestQuietStartup(t *testing.T) {
+	for _, quiet := range []bool{false, true} {
+		core, logs := observer.New(zapcore.InfoLevel)
+
+		cfg := validConfig()
+		cfg.QuietStartup = quiet
+		a := NewApp(context.Background(), cfg, zap.New(core))
+
+		a.Verbose().Info("starting up")
+		a.Log.Warn("something is off")
+
+		if got := logs.FilterMessage("starting up").Len() == 1; got == quiet {
+			t.Errorf("quiet %v: expected informational startup logs kept: %v", quiet, !quiet)
+		}
+
+		if logs.FilterMessage("something is off").Len() != 1 {
+			t.Errorf("quiet %v: expected warnings to be kept", quiet)
+		}
+	}
+}
+
+func TestNewAppFromConfigDefaults(t *testing.T) {
+	tests := []struct {
+		name             string
+		markSet          []string
+		wantWriteTimeout time.Duration
+		wantSampleRatio  float64
+	}{
+		{
+			name:             "zero values take the defaults",
+			wantWriteTimeout: DefaultWriteTimeout,
+			wantSampleRatio:  1,
+		},
+		{
+			name:             "zero values marked as set are kept",
+			markSet:          []string{"write_timeout", "tracing.sample_ratio"},
+			wantWriteTimeout: 0,
+			wantSampleRatio:  0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Configuration{ListenAddress: "127.0.0.1:0"}
+			cfg.MarkSet(tt.markSet...)
+
+			a, err := NewAppFromConfig(context.Background(), cfg)
+			if err != nil {
+				t.Fatalf("composing app: %v", err)
+			}
+
+			if a.Cfg.WriteTimeout != tt.wantWriteTimeout {
+				t.Errorf("expected write timeout %s, got %s", tt.wantWriteTimeout, a.Cfg.WriteTimeout)
+			}
+
+			if a.Cfg.Tracing.SampleRatio != tt.wantSampleRatio {
+				t.Errorf("expected sample ratio %v, got %v", tt.wantSampleRatio, a.Cfg.Tracing.SampleRatio)
+			}
+
+			for _, key := range tt.markSet {
+				if src := a.Cfg.KeySources()[key]; src != ConfigSourceMemory {
+					t.Errorf("expected %s to be sourced from memory, got %q", key, src)
+				}
+			}
+		})
+	}
+}
+
+func TestLogLevelIsPerApp(t *testing.T) {
+	debug, err := NewAppFromConfig(context.Background(), &Configuration{ListenAddress: "127.0.0.1:0", LogLevel: "debug"})
+	if err != nil {
+		t.Fatalf("composing app: %v", err)
+	}
+
+	warn, err := NewAppFromConfig(context.Background(), &Configuration{ListenAddress: "127.0.0.1:0", LogLevel: "warn"})
+	if err != nil {
+		t.Fatalf("composing app: %v", err)
+	}
+
+	debugLevel, bound := debug.LogLevel()
+	if !bound {
+		t.Fatal("expected the level to be bound to the logger")
+	}
+
+	warnLevel, _ := warn.LogLevel()
+
+	if got := debugLevel.Level(); got != zapcore.DebugLevel {
+		t.Errorf("expected the first App's level to stay debug, got %s", got)
+	}
+
+	debugLevel.SetLevel(zapcore.ErrorLevel)
+
+	if debug.Log.Core().Enabled(zapcore.WarnLevel) {
+		t.Error("expected changing the level to apply to the App's logger")
+	}
+
+	if got := warnLevel.Level(); got != zapcore.WarnLevel {
+		t.Errorf("expected the other App's level to be unchanged, got %s", got)
+	}
+
+	if !warn.Log.Core().Enabled(zapcore.WarnLevel) {
+		t.Error("expected the other App's logger to be unchanged")
+	}
+}
+
+func TestLogLevelIsUnboundWithoutWithLogLevel(t *testing.T) {
+	core, _ := observer.New(zapcore.WarnLevel)
+
+	lvl, bound := NewApp(context.Background(), validConfig(), zap.New(core)).LogLevel()
+	if bound {
+		t.Error("expected the level not to be bound without WithLogLevel")
+	}
+
+	if lvl.Level() != zapcore.WarnLevel {
+		t.Errorf("expected the logger's level to be reported, got %s", lvl.Level())
+	}
+}
+
+func TestEveryLogLineCarriesTheServiceName(t *testing.T) {
+	for _, developer := range []bool{false, true} {
+		core, logs := observer.New(zapcore.DebugLevel)
+		swapCore := zap.WrapCore(func(zapcore.Core) zapcore.Core { return core })
+
+		cfg := &Configuration{ServiceName: "fleet-api", DeveloperMode: developer}
+		l, _ := buildLogger(cfg, swapCore)
+
+		l.Info("from the logger")
+		l.With(zap.String("extra", "field")).Warn("from a child")
+		l.Named("component").Error("from a named logger")
+
+		entries := logs.All()
+		if len(entries) != 3 {
+			t.Fatalf("developer %v: expected 3 entries, got %d", developer, len(entries))
+		}
+
+		for _, e := range entries {
+			if got := e.ContextMap()["service"]; got != cfg.ServiceName {
+				t.Errorf("developer %v: %q: expected service %q, got %v", developer, e.Message, cfg.ServiceName, got)
+			}
+		}
+	}
+}
+
+func TestConfigureRuntime(t *testing.T) {
+	prev := runtime.GOMAXPROCS(0)
+	t.Cleanup(func() { runtime.GOMAXPROCS(prev) })
+
+	want := prev + 1
+
+	ConfigureRuntime(&Configuration{GoMaxProcs: want})
+	if got := runtime.GOMAXPROCS(0); got != want {
+		t.Errorf("expected GOMAXPROCS to be overridden to %d, got %d", want, got)
+	}
+
+	ConfigureRuntime(&Configuration{})
+	if got := runtime.GOMAXPROCS(0); got != want {
+		t.Errorf("expected GOMAXPROCS to be left at %d without an override, got %d", want, got)
+	}
+}
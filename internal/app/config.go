@@ -1,9 +1,404 @@
 package app
 
-import "go.hollow.sh/toolbox/ginjwt"
+import (
+	"slices"
+	"time"
+
+	"go.hollow.sh/toolbox/ginjwt"
+)
+
+const (
+	// DefaultMetricsMaxConnections bounds concurrent scrapes of the metrics endpoint
+	// when no explicit limit is configured.
+	DefaultMetricsMaxConnections = 10
+	// DefaultWriteTimeout is the API server write timeout when none is configured
+	DefaultWriteTimeout = 20 * time.Second
+	// DefaultMaxBatchSize is the largest bulk request accepted when none is configured
+	DefaultMaxBatchSize = 100
+	// DefaultMaxRequestBytes is the largest request body accepted when none is configured
+	DefaultMaxRequestBytes = 1 << 20
+	// DefaultMaxConcurrentStreams caps concurrent streams when no limit is configured
+	DefaultMaxConcurrentStreams = 100
+	// DefaultJWKSFetchTimeout bounds JWKS fetches when no timeout is configured
+	DefaultJWKSFetchTimeout = 10 * time.Second
+	// DefaultAuditBufferSize bounds the audit entries waiting to be shipped when no
+	// buffer size is configured
+	DefaultAuditBufferSize = 1024
+	// DefaultMaxResponseHeaderBytes bounds the size of response headers when no limit
+	// is configured
+	DefaultMaxResponseHeaderBytes = 16 << 10
+	// DefaultTracingServiceName identifies the service in traces when no name is
+	// configured
+	DefaultTracingServiceName = "skeleton-api-server"
+	// DefaultShutdownTimeout bounds graceful shutdown when no timeout is configured
+	DefaultShutdownTimeout = 10 * time.Second
+)
+
+// How JWTAuth entries from the environment combine with those from the config file
+const (
+	// JWTAuthReplace discards the config file entries in favor of the environment
+	JWTAuthReplace = "replace"
+	// JWTAuthMerge keeps config file entries unless the environment has one for the same issuer
+	JWTAuthMerge = "merge"
+)
+
+// Sources of a JWTAuth entry
+const (
+	JWTAuthSourceFile = "file"
+	JWTAuthSourceEnv  = "env"
+)
 
 type Configuration struct {
-	ListenAddress string              `mapstructure:"listen_address"`
-	DeveloperMode bool                `mapstructure:"developer_mode"`
-	JWTAuth       []ginjwt.AuthConfig `mapstructure:"ginjwt_auth"`
+	ListenAddress         string              `mapstructure:"listen_address" validate:"required"`
+	DeveloperMode         bool                `mapstructure:"developer_mode"`
+	ServiceName           string              `mapstructure:"service_name"`
+	JWTAuth               []ginjwt.AuthConfig `mapstructure:"ginjwt_auth"`
+	MetricsMaxConnections int                 `mapstructure:"metrics_max_connections"`
+	// BasePath is the path prefix every endpoint is served under, health endpoints
+	// included, e.g. /skeleton behind a gateway routing on it. Defaults to /.
+	BasePath string `mapstructure:"base_path" validate:"startswith=/"`
+	// MetricsNamespace prefixes the name of every metric, AppName when unset
+	MetricsNamespace string `mapstructure:"metrics_namespace" validate:"metric_name"`
+	// QuietStartup leaves out informational startup and lifecycle logs, keeping the
+	// starting and stopped lines along with warnings and errors
+	QuietStartup bool `mapstructure:"quiet_startup"`
+	// LogLevel is the initial log level, debug in developer mode and info otherwise
+	// when unset. It can be changed at runtime through the API.
+	LogLevel string `mapstructure:"log_level"`
+	// RedactParams names the query parameters whose values are left out of request
+	// logs, DefaultRedactParams when unset
+	RedactParams []string `mapstructure:"redact_params"`
+	// RequireAuthInProduction fails startup when protected routes would be served
+	// without any authentication configured, unless in developer mode.
+	RequireAuthInProduction bool `mapstructure:"require_auth_in_production"`
+	// WriteTimeout bounds the time taken to write a response. A value of 0 disables it.
+	WriteTimeout time.Duration `mapstructure:"write_timeout"`
+	// ShutdownTimeout bounds the time taken draining in-flight requests and running
+	// shutdown hooks once signaled to terminate
+	ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout"`
+	// ShutdownDrainDelay is how long new requests keep being turned away with a 503,
+	// once signaled to terminate, before the server stops accepting connections, giving
+	// load balancers time to take it out of rotation. It isn't bounded by
+	// ShutdownTimeout.
+	ShutdownDrainDelay time.Duration `mapstructure:"shutdown_drain_delay" validate:"gte=0"`
+	// StreamingEnabled indicates long-lived streaming responses are served, which a
+	// finite WriteTimeout will cut off.
+	StreamingEnabled bool `mapstructure:"streaming_enabled"`
+	// MaxConcurrentStreams caps the number of streaming responses served at once
+	MaxConcurrentStreams int `mapstructure:"max_concurrent_streams" validate:"gte=0"`
+	// MaxConcurrentRequests caps the number of requests served at once, requests
+	// beyond it are rejected with a 503. A value of 0 leaves concurrency unbounded.
+	MaxConcurrentRequests int `mapstructure:"max_concurrent_requests" validate:"gte=0"`
+	// DisableWriteTimeoutForStreaming sets WriteTimeout to 0 when streaming is enabled.
+	DisableWriteTimeoutForStreaming bool `mapstructure:"disable_write_timeout_for_streaming"`
+	// EnablePprof serves the net/http/pprof endpoints under /debug/pprof/
+	EnablePprof bool `mapstructure:"enable_pprof"`
+	// GoMaxProcs overrides the runtime GOMAXPROCS setting when positive, e.g. to match
+	// the container CPU quota.
+	GoMaxProcs int `mapstructure:"go_max_procs"`
+	// MaxBatchSize is the maximum number of items accepted by bulk endpoints
+	MaxBatchSize int `mapstructure:"max_batch_size" validate:"gte=0"`
+	// EchoReservedKeyPrefixes makes the echo endpoint reject payloads with keys starting
+	// with any of these prefixes, e.g. "_"
+	EchoReservedKeyPrefixes []string `mapstructure:"echo_reserved_key_prefixes"`
+	// RequestTimeout bounds the time a handler may take to serve a request. A value
+	// of 0 disables the per-request deadline. It is shortened to expire ahead of a
+	// finite WriteTimeout, so that timed out requests still get a response.
+	RequestTimeout time.Duration `mapstructure:"request_timeout"`
+	// MaxClientRequestTimeout caps the timeout clients may ask for in the
+	// X-Request-Timeout header, which is ignored when 0. It is shortened like
+	// RequestTimeout to expire ahead of a finite WriteTimeout.
+	MaxClientRequestTimeout time.Duration `mapstructure:"max_client_request_timeout" validate:"gte=0"`
+	// MaxRequestBytes caps the size of request bodies. A negative value removes the cap.
+	MaxRequestBytes int64 `mapstructure:"max_request_bytes"`
+	// BufferRequestBodies reads request bodies into memory up front so that they can
+	// be read by several middlewares. It requires a MaxRequestBytes limit.
+	BufferRequestBodies bool `mapstructure:"buffer_request_bodies"`
+	// ExposeDecodeErrors returns raw request body decoding errors to callers, rather
+	// than a friendly translation, e.g. while debugging clients
+	ExposeDecodeErrors bool `mapstructure:"expose_decode_errors"`
+	// MaxResponseHeaderBytes bounds the size of response headers, non-essential headers
+	// are trimmed from responses exceeding it
+	MaxResponseHeaderBytes int `mapstructure:"max_response_header_bytes" validate:"gte=0"`
+	// RecommendedClientVersion is the lowest X-Client-Version not told to upgrade
+	RecommendedClientVersion string `mapstructure:"recommended_client_version"`
+	// RequiredClientVersion is the lowest X-Client-Version served, older clients are
+	// rejected with a 426
+	RequiredClientVersion string `mapstructure:"required_client_version"`
+	// ReplayWindow is how far request timestamps may be from the current time, and so
+	// how long request nonces are remembered to reject replays. A value of 0 disables
+	// replay protection.
+	ReplayWindow time.Duration `mapstructure:"replay_window"`
+	// TCPKeepAlivePeriod is the keep-alive period of accepted API connections. A value
+	// of 0 uses Go's default, a negative value disables keep-alives.
+	TCPKeepAlivePeriod time.Duration `mapstructure:"tcp_keep_alive_period"`
+	// TLS serves the API over TLS when a certificate and key are configured
+	TLS TLSConfig `mapstructure:"tls"`
+	// APIKeys are static keys accepted in the X-API-Key header, for callers that can't
+	// mint JWTs
+	APIKeys []APIKey `mapstructure:"api_keys"`
+	// Audit configures shipping audit entries to an external sink
+	Audit AuditConfig `mapstructure:"audit"`
+	// Tracing configures exporting traces over OTLP
+	Tracing TracingConfig `mapstructure:"tracing"`
+	// OTELMetrics mirrors key metrics to an OTLP collector, alongside prometheus
+	OTELMetrics OTELMetricsConfig `mapstructure:"otel_metrics"`
+	// DisableBuiltinEndpoints lists the paths of endpoints served out of the box that
+	// aren't wanted, e.g. /_health/liveness when probes are provided otherwise. The
+	// readiness endpoint can't be disabled.
+	DisableBuiltinEndpoints []string `mapstructure:"disable_builtin_endpoints"`
+	// HealthResponse selects the body of health endpoint responses, either
+	// HealthResponseDetailed (the default) or HealthResponsePlain
+	HealthResponse string `mapstructure:"health_response" validate:"oneof=detailed plain"`
+	// Prewarm opens connections to dependencies at startup
+	Prewarm PrewarmConfig `mapstructure:"prewarm"`
+	// RateLimit bounds the rate of requests served
+	RateLimit RateLimitConfig `mapstructure:"rate_limit"`
+	// Degradation maps dependencies, by health check name, to the route classes that
+	// can't be served while the dependency is down. Dependencies not listed don't
+	// hold up any route class, though the service isn't ready while they are down.
+	Degradation map[string][]string `mapstructure:"degradation" validate:"dive,dive,oneof=read write"`
+	// JWKSRefreshInterval is how often the JWKS of every JWTAuth issuer are re-fetched
+	// to pick up rotated keys. A value of 0 only fetches them at startup.
+	JWKSRefreshInterval time.Duration `mapstructure:"jwks_refresh_interval"`
+	// JWKSFetchTimeout bounds the time taken to fetch the JWKS of every JWTAuth issuer
+	JWKSFetchTimeout time.Duration `mapstructure:"jwks_fetch_timeout"`
+	// JWTAuthPrecedence is either JWTAuthReplace (the default) or JWTAuthMerge
+	JWTAuthPrecedence string `mapstructure:"ginjwt_auth_precedence"`
+
+	// keySources records where the value of each configuration key set was taken from
+	keySources map[string]string
+
+	// jwtAuthSources records which source each JWTAuth issuer was taken from
+	jwtAuthSources map[string]string
+
+	// configuredRequestTimeout is the RequestTimeout configured, when it was shortened
+	configuredRequestTimeout time.Duration
+}
+
+// Client certificate verification modes
+const (
+	ClientAuthNone          = "none"
+	ClientAuthVerifyIfGiven = "verify_if_given"
+	ClientAuthRequire       = "require"
+)
+
+// TLSConfig configures serving the API over TLS, optionally authenticating clients
+// by their certificates.
+type TLSConfig struct {
+	CertFile string `mapstructure:"cert_file"`
+	KeyFile  string `mapstructure:"key_file"`
+	// ClientCAFile holds the CA certificates client certificates are verified against
+	ClientCAFile string `mapstructure:"client_ca_file"`
+	// ClientAuth is one of ClientAuthNone (the default), ClientAuthVerifyIfGiven or
+	// ClientAuthRequire
+	ClientAuth string `mapstructure:"client_auth" validate:"omitempty,oneof=none verify_if_given require"`
+	// ClientScopes maps the identity of a verified client certificate, its subject
+	// common name or one of its DNS names, to the scopes it is granted. Identities are
+	// matched case-insensitively.
+	ClientScopes map[string][]string `mapstructure:"client_scopes"`
+}
+
+// Enabled indicates whether the API is served over TLS
+func (t *TLSConfig) Enabled() bool {
+	return t.CertFile != "" && t.KeyFile != ""
+}
+
+// DefaultRedactParams are the query parameters redacted from request logs when none
+// are configured
+var DefaultRedactParams = []string{"access_token", "api_key", "password", "secret", "token"}
+
+// Sources of configuration values
+const (
+	ConfigSourceFile   = "file"
+	ConfigSourceEnv    = "env"
+	ConfigSourceMemory = "memory"
+)
+
+// ReadinessPath is the readiness endpoint, which orchestrators depend on
+const ReadinessPath = "/_health/readiness"
+
+// Health response bodies
+const (
+	// HealthResponseDetailed includes the result of every check, the default
+	HealthResponseDetailed = "detailed"
+	// HealthResponsePlain only includes the overall status
+	HealthResponsePlain = "plain"
+)
+
+// Route classes a degraded dependency may prevent from being served
+const (
+	RouteClassRead  = "read"
+	RouteClassWrite = "write"
+)
+
+// AuditConfig configures the external audit sink
+type AuditConfig struct {
+	// SinkURL receives audit entries as JSON POSTs, shipping is disabled when empty
+	SinkURL string `mapstructure:"sink_url" validate:"omitempty,url"`
+	// BufferSize bounds the number of entries waiting to be shipped
+	BufferSize int `mapstructure:"buffer_size"`
+	// Retries is the number of times a failed post is retried
+	Retries int `mapstructure:"retries"`
+}
+
+// TracingConfig configures exporting traces over OTLP. The endpoint and insecure
+// settings fall back to the standard OTEL_EXPORTER_OTLP_ENDPOINT and
+// OTEL_EXPORTER_OTLP_INSECURE environment variables.
+type TracingConfig struct {
+	// ServiceName identifies the service in traces, DefaultTracingServiceName when unset
+	ServiceName string `mapstructure:"service_name"`
+	// Endpoint is the host:port of the OTLP gRPC collector, tracing is disabled when
+	// empty
+	Endpoint string `mapstructure:"endpoint"`
+	// Insecure exports without TLS
+	Insecure bool `mapstructure:"insecure"`
+	// SampleRatio is the fraction of traces started by this service that are sampled,
+	// 1 when unset. Traces continued from a caller follow the caller's decision.
+	SampleRatio float64 `mapstructure:"sample_ratio" validate:"gte=0,lte=1"`
+}
+
+// Enabled indicates whether traces are exported
+func (t *TracingConfig) Enabled() bool {
+	return t.Endpoint != ""
+}
+
+// OTELMetricsConfig configures exporting metrics over OTLP
+type OTELMetricsConfig struct {
+	// Endpoint is the host:port of the OTLP gRPC collector, exporting is disabled
+	// when empty
+	Endpoint string `mapstructure:"endpoint" validate:"omitempty,hostname_port"`
+	// Insecure exports without TLS
+	Insecure bool `mapstructure:"insecure"`
+	// Interval is how often metrics are exported, the SDK default when 0
+	Interval time.Duration `mapstructure:"interval"`
+}
+
+// Enabled indicates whether metrics are exported over OTLP
+func (o *OTELMetricsConfig) Enabled() bool {
+	return o.Endpoint != ""
+}
+
+// PrewarmConfig configures opening connections to dependencies ahead of the first
+// requests needing them
+type PrewarmConfig struct {
+	// Connections is the number of connections opened to each dependency, prewarming
+	// is disabled when 0
+	Connections int `mapstructure:"connections"`
+	// Timeout bounds the time taken prewarming, a value of 0 disables the timeout
+	Timeout time.Duration `mapstructure:"timeout"`
+	// Required keeps the App from reporting ready when prewarming failed
+	Required bool `mapstructure:"required"`
+}
+
+// RateLimitConfig bounds the rate of requests served by the process, across all
+// clients. Routes may be limited further by their own overrides.
+type RateLimitConfig struct {
+	// RequestsPerSecond across all routes, the global limit is disabled when 0
+	RequestsPerSecond float64 `mapstructure:"requests_per_second" validate:"gte=0"`
+	// Burst is the number of requests admitted at once, a second's worth when 0
+	Burst int `mapstructure:"burst"`
+	// Routes limits individual routes in addition to the global limit
+	Routes []RouteRateLimit `mapstructure:"routes" validate:"dive"`
+}
+
+// RouteRateLimit bounds the rate of requests served by a single route
+type RouteRateLimit struct {
+	// Method and Path identify the route as registered, e.g. POST /api/bulk
+	Method            string  `mapstructure:"method" validate:"required"`
+	Path              string  `mapstructure:"path" validate:"required"`
+	RequestsPerSecond float64 `mapstructure:"requests_per_second" validate:"gt=0"`
+	Burst             int     `mapstructure:"burst"`
+}
+
+// APIKey grants the holder of a static key a set of scopes
+type APIKey struct {
+	// Name identifies the key's holder in logs, the key itself is never logged
+	Name   string   `mapstructure:"name"`
+	Key    string   `mapstructure:"key"`
+	Scopes []string `mapstructure:"scopes"`
+}
+
+// KeySources maps every configuration key set, in viper's dotted form, to the source
+// its value was taken from, either ConfigSourceFile, ConfigSourceEnv or
+// ConfigSourceMemory
+func (c *Configuration) KeySources() map[string]string {
+	return c.keySources
+}
+
+// MarkSet records the given keys, in viper's dotted form, as set in memory, so that
+// NewAppFromConfig keeps their values even when zero, e.g. a WriteTimeout of 0
+func (c *Configuration) MarkSet(keys ...string) {
+	if c.keySources == nil {
+		c.keySources = make(map[string]string, len(keys))
+	}
+
+	for _, key := range keys {
+		c.keySources[key] = ConfigSourceMemory
+	}
+}
+
+// isSet reports whether the value of the given key, among those defaulted only when
+// missing from config files, has been marked as set or is other than its zero value
+func (c *Configuration) isSet(key string) bool {
+	if _, ok := c.keySources[key]; ok {
+		return true
+	}
+
+	return c.nonZero(key)
+}
+
+// JWTAuthSources maps the issuer of every JWTAuth entry to the source it was taken from
+func (c *Configuration) JWTAuthSources() map[string]string {
+	return c.jwtAuthSources
+}
+
+// nonZero reports whether the value of the given key, among those defaulted only when
+// missing from config files, has been set to something other than its zero value
+func (c *Configuration) nonZero(key string) bool {
+	switch key {
+	case "redact_params":
+		return c.RedactParams != nil
+	case "tracing.sample_ratio":
+		return c.Tracing.SampleRatio != 0
+	case "write_timeout":
+		return c.WriteTimeout != 0
+	default:
+		return false
+	}
+}
+
+// WriteTimeoutCutsStreams reports whether the configured WriteTimeout will terminate
+// streaming responses. Deployments serving streams should set WriteTimeout to 0.
+func (c *Configuration) WriteTimeoutCutsStreams() bool {
+	return c.StreamingEnabled && c.WriteTimeout > 0
+}
+
+// AuthEnabled indicates whether any method of authenticating callers is configured
+func (c *Configuration) AuthEnabled() bool {
+	return len(c.JWTAuth) > 0 || len(c.APIKeys) > 0 || len(c.TLS.ClientScopes) > 0
+}
+
+// DegradationAffects reports whether routes of class can't be served while the named
+// dependency is down
+func (c *Configuration) DegradationAffects(dependency, class string) bool {
+	return slices.Contains(c.Degradation[dependency], class)
+}
+
+// DegradationCovers reports whether the degradation policy lists the named dependency
+func (c *Configuration) DegradationCovers(dependency string) bool {
+	_, ok := c.Degradation[dependency]
+	return ok
+}
+
+// requestTimeoutLimit is the longest RequestTimeout that leaves time to write out the
+// timeout response before WriteTimeout drops the connection
+func (c *Configuration) requestTimeoutLimit() time.Duration {
+	return c.WriteTimeout - min(time.Second, c.WriteTimeout/10)
+}
+
+// ConfiguredRequestTimeout returns the RequestTimeout configured and true when it was
+// shortened to expire ahead of WriteTimeout
+func (c *Configuration) ConfiguredRequestTimeout() (time.Duration, bool) {
+	return c.configuredRequestTimeout, c.configuredRequestTimeout != 0
 }
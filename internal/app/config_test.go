@@ -0,0 +1,277 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeConfigFile writes a config file named name holding contents, returning its path
+func writeConfigFile(t *testing.T, name, contents string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatalf("writing config: %v", err)
+	}
+
+	return path
+}
+
+// loadConfig loads a configuration from a file holding contents
+func loadConfig(t *testing.T, contents string) *Configuration {
+	t.Helper()
+
+	cfg, err := LoadConfiguration(writeConfigFile(t, "config.yaml", contents))
+	if err != nil {
+		t.Fatalf("loading config: %v", err)
+	}
+
+	return cfg
+}
+
+func TestTracingConfig(t *testing.T) {
+	tests := []struct {
+		name            string
+		contents        string
+		wantServiceName string
+		wantSampleRatio float64
+	}{
+		{
+			name:            "absent",
+			contents:        "listen_address: 127.0.0.1:7500\n",
+			wantServiceName: DefaultTracingServiceName,
+			wantSampleRatio: 1,
+		},
+		{
+			name:            "set",
+			contents:        "listen_address: 127.0.0.1:7500\ntracing:\n  service_name: fleet-api\n  sample_ratio: 0\n",
+			wantServiceName: "fleet-api",
+			wantSampleRatio: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := loadConfig(t, tt.contents)
+
+			if cfg.Tracing.ServiceName != tt.wantServiceName {
+				t.Errorf("expected service name %q, got %q", tt.wantServiceName, cfg.Tracing.ServiceName)
+			}
+
+			if cfg.Tracing.SampleRatio != tt.wantSampleRatio {
+				t.Errorf("expected sample ratio %v, got %v", tt.wantSampleRatio, cfg.Tracing.SampleRatio)
+			}
+		})
+	}
+}
+
+func TestLoadConfigurationMergesFiles(t *testing.T) {
+	t.Setenv("SKELETON_MAX_BATCH_SIZE", "7")
+
+	base := writeConfigFile(t, "base.yaml",
+		"listen_address: 127.0.0.1:7500\nlog_level: info\nbase_path: /base\nmax_batch_size: 3\n")
+	overlay := writeConfigFile(t, "overlay.json", `{"log_level": "debug", "max_batch_size": 5}`)
+
+	cfg, err := LoadConfiguration(base, overlay)
+	if err != nil {
+		t.Fatalf("loading config: %v", err)
+	}
+
+	if cfg.BasePath != "/base" {
+		t.Errorf("expected keys only in the base to be kept, got base path %q", cfg.BasePath)
+	}
+
+	if cfg.LogLevel != "debug" {
+		t.Errorf("expected the overlay to override the base, got log level %q", cfg.LogLevel)
+	}
+
+	if cfg.MaxBatchSize != 7 {
+		t.Errorf("expected the environment to override every file, got max batch size %d", cfg.MaxBatchSize)
+	}
+}
+
+func TestLoadConfigurationRequiresAFile(t *testing.T) {
+	if _, err := LoadConfiguration(); err == nil {
+		t.Error("expected loading without a config file to fail")
+	}
+}
+
+func TestLoadConfigurationRejectsIncompleteJWTAuth(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml",
+		"listen_address: 127.0.0.1:7500\nginjwt_auth:\n  - enabled: true\n    issuer: https://idp\n")
+
+	_, err := LoadConfiguration(path)
+	if err == nil || !strings.Contains(err.Error(), `ginjwt_auth entry 0 (issuer "https://idp", audience "") lacks a jwks uri`) {
+		t.Errorf("expected the incomplete entry to be reported, got %v", err)
+	}
+}
+
+func TestJWTAuthPrecedence(t *testing.T) {
+	const (
+		file = `listen_address: 127.0.0.1:7500
+ginjwt_auth:
+  - enabled: true
+    issuer: https://shared
+    audience: from-file
+    jwksuri: https://shared/file/jwks
+  - enabled: true
+    issuer: https://file-only
+    jwksuri: https://file-only/jwks
+`
+		env = `[
+  {"enabled": true, "issuer": "https://shared", "audience": "from-env", "jwksuri": "https://shared/env/jwks"},
+  {"enabled": true, "issuer": "https://env-only", "jwksuri": "https://env-only/jwks"}
+]`
+	)
+
+	tests := []struct {
+		name         string
+		precedence   string
+		env          string
+		wantSources  map[string]string
+		wantAudience string
+		wantErr      bool
+	}{
+		{
+			name: "file only",
+			wantSources: map[string]string{
+				"https://shared":    JWTAuthSourceFile,
+				"https://file-only": JWTAuthSourceFile,
+			},
+			wantAudience: "from-file",
+		},
+		{
+			name: "env replaces the file by default",
+			env:  env,
+			wantSources: map[string]string{
+				"https://shared":   JWTAuthSourceEnv,
+				"https://env-only": JWTAuthSourceEnv,
+			},
+			wantAudience: "from-env",
+		},
+		{
+			name:       "env replaces the file",
+			precedence: JWTAuthReplace,
+			env:        env,
+			wantSources: map[string]string{
+				"https://shared":   JWTAuthSourceEnv,
+				"https://env-only": JWTAuthSourceEnv,
+			},
+			wantAudience: "from-env",
+		},
+		{
+			name:       "env wins overlapping issuers when merged",
+			precedence: JWTAuthMerge,
+			env:        env,
+			wantSources: map[string]string{
+				"https://shared":    JWTAuthSourceEnv,
+				"https://env-only":  JWTAuthSourceEnv,
+				"https://file-only": JWTAuthSourceFile,
+			},
+			wantAudience: "from-env",
+		},
+		{
+			name:       "unknown precedence",
+			precedence: "shuffle",
+			env:        env,
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("SKELETON_GINJWT_AUTH_JSON", tt.env)
+
+			contents := file
+			if tt.precedence != "" {
+				contents += "ginjwt_auth_precedence: " + tt.precedence + "\n"
+			}
+
+			cfg, err := LoadConfiguration(writeConfigFile(t, "config.yaml", contents))
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("expected an error %v, got %v", tt.wantErr, err)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			sources := cfg.JWTAuthSources()
+			if len(sources) != len(tt.wantSources) || len(cfg.JWTAuth) != len(tt.wantSources) {
+				t.Fatalf("expected issuers %v, got %v from %v", tt.wantSources, cfg.JWTAuth, sources)
+			}
+
+			for issuer, want := range tt.wantSources {
+				if sources[issuer] != want {
+					t.Errorf("%s: expected source %q, got %q", issuer, want, sources[issuer])
+				}
+			}
+
+			for _, ac := range cfg.JWTAuth {
+				if ac.Issuer == "https://shared" && ac.Audience != tt.wantAudience {
+					t.Errorf("expected the shared issuer's audience %q, got %q", tt.wantAudience, ac.Audience)
+				}
+			}
+		})
+	}
+}
+
+// setStdin replaces standard input with contents for the duration of the test
+func setStdin(t *testing.T, contents string) {
+	t.Helper()
+
+	f, err := os.Open(writeConfigFile(t, "stdin", contents))
+	if err != nil {
+		t.Fatalf("opening stdin: %v", err)
+	}
+
+	prev := os.Stdin
+	os.Stdin = f
+	t.Cleanup(func() {
+		os.Stdin = prev
+		f.Close()
+	})
+}
+
+func TestLoadConfigurationFromStdin(t *testing.T) {
+	const (
+		yamlConfig = "listen_address: 127.0.0.1:7500\nlog_level: debug\n"
+		jsonConfig = `{"listen_address": "127.0.0.1:7500", "log_level": "debug"}`
+	)
+
+	tests := []struct {
+		name     string
+		cfgType  string
+		contents string
+		wantErr  bool
+	}{
+		{name: "yaml", cfgType: "yaml", contents: yamlConfig},
+		{name: "json", cfgType: "json", contents: jsonConfig},
+		{name: "yaml by default", contents: yamlConfig},
+		// JSON is a subset of YAML
+		{name: "json read as yaml", contents: jsonConfig},
+		{name: "yaml read as json", cfgType: "json", contents: yamlConfig, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setStdin(t, tt.contents)
+
+			cfg, err := LoadTypedConfiguration(tt.cfgType, StdinConfig)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("expected an error %v, got %v", tt.wantErr, err)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if cfg.ListenAddress != "127.0.0.1:7500" || cfg.LogLevel != "debug" {
+				t.Errorf("expected the configuration from stdin, got listen address %q and log level %q",
+					cfg.ListenAddress, cfg.LogLevel)
+			}
+		})
+	}
+}
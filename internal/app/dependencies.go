@@ -0,0 +1,71 @@
+package app
+
+import (
+	"context"
+
+	"github.com/metal-toolbox/fleet-rest-skeleton/internal/fleetdb"
+	"go.hollow.sh/toolbox/events"
+)
+
+const (
+	eventStreamOption = "event_stream"
+	fleetDBOption     = "fleetdb_client"
+)
+
+// DependencyOptions returns the Options handing an App the clients of the dependencies
+// present in the configuration, so that every command uses the same clients
+func DependencyOptions(_ *Configuration) []Option {
+	var opts []Option
+
+	// XXX: Read NATS and or FleetDB Config
+	// XXX: add NATS client, handed to the app with WithEventStream
+	// XXX: add FleetDB client, handed to the app with WithFleetDBClient
+
+	return opts
+}
+
+// WithEventStream adds the event stream handlers publish to, along with a health check
+// on its connection.
+func WithEventStream(stream events.Stream) Option {
+	return func(a *App) {
+		a.opts[eventStreamOption] = stream
+		a.RegisterHealthCheck("event stream", func(ctx context.Context) error {
+			return pingStream(ctx, stream)
+		})
+	}
+}
+
+// EventStream returns the App's event stream, and false when it has none
+func (a *App) EventStream() (events.Stream, bool) {
+	return GetOption[events.Stream](a, eventStreamOption)
+}
+
+// pinger is implemented by streams able to check their connection
+type pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// pingStream checks the connection of streams that support it; other streams are
+// assumed healthy, as events.Stream has no means of checking.
+func pingStream(ctx context.Context, stream events.Stream) error {
+	if p, ok := stream.(pinger); ok {
+		return p.Ping(ctx)
+	}
+
+	return nil
+}
+
+// WithFleetDBClient adds the FleetDB client handlers use, along with a health check
+// making a lightweight call to FleetDB. The same call is used to prewarm connections.
+func WithFleetDBClient(c fleetdb.FleetDB) Option {
+	return func(a *App) {
+		a.opts[fleetDBOption] = c
+		a.RegisterHealthCheck("fleetdb", c.Ping)
+		a.RegisterPrewarm("fleetdb", c.Ping)
+	}
+}
+
+// FleetDBClient returns the App's FleetDB client, and false when it has none
+func (a *App) FleetDBClient() (fleetdb.FleetDB, bool) {
+	return GetOption[fleetdb.FleetDB](a, fleetDBOption)
+}
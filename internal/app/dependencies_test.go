@@ -0,0 +1,78 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.hollow.sh/toolbox/events"
+)
+
+var errDown = errors.New("down")
+
+// pingingStream is an event stream able to check its connection, failing with err
+// when set
+type pingingStream struct {
+	events.Stream
+	err error
+}
+
+func (s pingingStream) Ping(context.Context) error {
+	return s.err
+}
+
+func TestWithEventStream(t *testing.T) {
+	if _, ok := newTestApp(t).EventStream(); ok {
+		t.Error("expected no event stream without the option")
+	}
+
+	stream := pingingStream{err: errDown}
+	a := newTestApp(t, WithEventStream(stream))
+
+	if got, ok := a.EventStream(); !ok || got != stream {
+		t.Errorf("expected the event stream, got %v, %v", got, ok)
+	}
+
+	if err := a.CheckHealth(context.Background())["event stream"]; !errors.Is(err, errDown) {
+		t.Errorf("expected the health check to ping the stream, got %v", err)
+	}
+}
+
+// stubFleetDB is a FleetDB client whose ping fails with err when set
+type stubFleetDB struct {
+	err error
+}
+
+func (s stubFleetDB) Ping(context.Context) error {
+	return s.err
+}
+
+func TestWithFleetDBClient(t *testing.T) {
+	if _, ok := newTestApp(t).FleetDBClient(); ok {
+		t.Error("expected no FleetDB client without the option")
+	}
+
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{"fleetdb up", nil},
+		{"fleetdb down", errDown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := stubFleetDB{err: tt.err}
+			a := newTestApp(t, WithFleetDBClient(client))
+
+			if got, ok := a.FleetDBClient(); !ok || got != client {
+				t.Errorf("expected the client, got %v, %v", got, ok)
+			}
+
+			results := a.CheckHealth(context.Background())
+			if err, ok := results["fleetdb"]; !ok || !errors.Is(err, tt.err) {
+				t.Errorf("expected the health check to report %v, got %v", tt.err, results)
+			}
+		})
+	}
+}
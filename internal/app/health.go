@@ -0,0 +1,48 @@
+package app
+
+import "context"
+
+// HealthCheck reports whether a dependency of the App is usable
+type HealthCheck func(context.Context) error
+
+// healthCheck is a named HealthCheck
+type healthCheck struct {
+	name  string
+	check HealthCheck
+}
+
+// RegisterHealthCheck adds a check run by CheckHealth, reported under name
+func (a *App) RegisterHealthCheck(name string, check HealthCheck) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.checks = append(a.checks, healthCheck{name: name, check: check})
+}
+
+// HealthChecks returns every registered health check by name
+func (a *App) HealthChecks() map[string]HealthCheck {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	checks := make(map[string]HealthCheck, len(a.checks))
+	for _, hc := range a.checks {
+		checks[hc.name] = hc.check
+	}
+
+	return checks
+}
+
+// CheckHealth runs every registered health check, returning each one's result by name.
+// A nil result means the check passed.
+func (a *App) CheckHealth(ctx context.Context) map[string]error {
+	a.mu.Lock()
+	checks := a.checks
+	a.mu.Unlock()
+
+	results := make(map[string]error, len(checks))
+	for _, hc := range checks {
+		results[hc.name] = hc.check(ctx)
+	}
+
+	return results
+}
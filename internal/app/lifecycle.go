@@ -0,0 +1,28 @@
+package app
+
+import (
+	"net"
+
+	"github.com/metal-toolbox/fleet-rest-skeleton/internal/version"
+	"go.uber.org/zap"
+)
+
+// LogStarting logs the line announcing the App is about to serve on addr, the address
+// the API listener resolved to, along with how it is served
+func (a *App) LogStarting(addr net.Addr, fields ...zap.Field) {
+	a.Log.Info("starting", append([]zap.Field{
+		zap.String("version", version.Current().String()),
+		zap.String("listen_address", addr.String()),
+		zap.Bool("tls_enabled", a.Cfg.TLS.Enabled()),
+		zap.Bool("auth_enabled", a.Cfg.AuthEnabled()),
+	}, fields...)...)
+}
+
+// LogStopped logs the line reporting the App has stopped, along with how long it ran
+// and why it stopped, e.g. the signal it was sent
+func (a *App) LogStopped(reason string, fields ...zap.Field) {
+	a.Log.Info("stopped", append([]zap.Field{
+		zap.Duration("uptime", a.Uptime()),
+		zap.String("reason", reason),
+	}, fields...)...)
+}
@@ -0,0 +1,81 @@
+package app
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestLogStarting(t *testing.T) {
+	addr := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 8080}
+
+	tests := []struct {
+		name     string
+		apiKeys  []APIKey
+		wantAuth bool
+	}{
+		{name: "without auth"},
+		{name: "with api keys", apiKeys: []APIKey{{Name: "reader", Key: "reader-key"}}, wantAuth: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			core, logs := observer.New(zapcore.InfoLevel)
+
+			cfg := validConfig()
+			cfg.APIKeys = tt.apiKeys
+			a := NewApp(context.Background(), cfg, zap.New(core))
+
+			a.LogStarting(addr, zap.String("extra", "field"))
+
+			starting := logs.FilterMessage("starting").All()
+			if len(starting) != 1 {
+				t.Fatalf("expected a single starting line, got %v", logs.All())
+			}
+
+			fields := starting[0].ContextMap()
+
+			if got := fields["listen_address"]; got != "127.0.0.1:8080" {
+				t.Errorf("expected the listen address, got %v", got)
+			}
+
+			if got := fields["auth_enabled"]; got != tt.wantAuth {
+				t.Errorf("expected auth_enabled %v, got %v", tt.wantAuth, got)
+			}
+
+			if got := fields["tls_enabled"]; got != false {
+				t.Errorf("expected tls_enabled false, got %v", got)
+			}
+
+			if got := fields["extra"]; got != "field" {
+				t.Errorf("expected the extra field to be logged, got %v", got)
+			}
+		})
+	}
+}
+
+func TestLogStopped(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	a := NewApp(context.Background(), validConfig(), zap.New(core))
+
+	a.LogStopped("terminated")
+
+	stopped := logs.FilterMessage("stopped").All()
+	if len(stopped) != 1 {
+		t.Fatalf("expected a single stopped line, got %v", logs.All())
+	}
+
+	fields := stopped[0].ContextMap()
+
+	if got := fields["reason"]; got != "terminated" {
+		t.Errorf("expected the reason, got %v", got)
+	}
+
+	if _, ok := fields["uptime"]; !ok {
+		t.Error("expected the uptime to be logged")
+	}
+}
@@ -0,0 +1,98 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// unixAddressPrefix marks a ListenAddress as the path of a Unix domain socket
+const unixAddressPrefix = "unix:"
+
+// Listen opens the listener the API is served from. Accepted connections use the
+// configured TCP keep-alive period, or Go's default when none is configured.
+//
+// A ListenAddress prefixed with "unix:" listens on a Unix domain socket at the path
+// following it instead. A socket left behind at that path is replaced, and the
+// socket is removed once the listener is closed.
+func Listen(ctx context.Context, cfg *Configuration) (net.Listener, error) {
+	lc := net.ListenConfig{
+		KeepAlive: cfg.TCPKeepAlivePeriod,
+	}
+
+	if path, ok := strings.CutPrefix(cfg.ListenAddress, unixAddressPrefix); ok {
+		if err := removeStaleSocket(path); err != nil {
+			return nil, err
+		}
+
+		return lc.Listen(ctx, "unix", path)
+	}
+
+	if cfg.TCPKeepAlivePeriod <= 0 {
+		return lc.Listen(ctx, "tcp", cfg.ListenAddress)
+	}
+
+	// the configured period is applied as connections are accepted
+	lc.KeepAlive = -1
+
+	l, err := lc.Listen(ctx, "tcp", cfg.ListenAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	return &keepAliveListener{Listener: l, period: cfg.TCPKeepAlivePeriod}, nil
+}
+
+// setKeepAlive enables keep-alives with the given period on an accepted connection
+var setKeepAlive = func(conn *net.TCPConn, period time.Duration) error {
+	if err := conn.SetKeepAlive(true); err != nil {
+		return err
+	}
+
+	return conn.SetKeepAlivePeriod(period)
+}
+
+// keepAliveListener sets the keep-alive period of the TCP connections it accepts
+type keepAliveListener struct {
+	net.Listener
+	period time.Duration
+}
+
+func (l *keepAliveListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	if tc, ok := conn.(*net.TCPConn); ok {
+		if err := setKeepAlive(tc, l.period); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	return conn, nil
+}
+
+// removeStaleSocket removes the socket at path, left behind by a process that didn't
+// shut down cleanly. Anything else at path is left for listening to fail on.
+func removeStaleSocket(path string) error {
+	fi, err := os.Lstat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if fi.Mode().Type() != fs.ModeSocket {
+		return nil
+	}
+
+	return os.Remove(path)
+}
@@ -0,0 +1,119 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// socketPath returns the path of a socket in a directory removed once the test is
+// done, short enough for socket path limits
+func socketPath(t *testing.T) string {
+	t.Helper()
+
+	dir, err := os.MkdirTemp("", "skeleton")
+	if err != nil {
+		t.Fatalf("creating a directory: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	return filepath.Join(dir, "api.sock")
+}
+
+func TestListenOnUnixSocket(t *testing.T) {
+	path := socketPath(t)
+
+	// left behind by a process that didn't shut down cleanly
+	stale, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("listening: %v", err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	l, err := Listen(context.Background(), &Configuration{ListenAddress: unixAddressPrefix + path})
+	if err != nil {
+		t.Fatalf("expected the stale socket to be replaced, got %v", err)
+	}
+
+	if l.Addr().Network() != "unix" {
+		t.Errorf("expected a unix listener, got %s", l.Addr().Network())
+	}
+
+	l.Close()
+
+	if _, err := os.Lstat(path); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected the socket to be removed once closed, got %v", err)
+	}
+}
+
+func TestListenLeavesOtherFilesAlone(t *testing.T) {
+	path := socketPath(t)
+	if err := os.WriteFile(path, []byte("keep"), 0o600); err != nil {
+		t.Fatalf("writing file: %v", err)
+	}
+
+	if _, err := Listen(context.Background(), &Configuration{ListenAddress: unixAddressPrefix + path}); err == nil {
+		t.Fatal("expected listening over a regular file to fail")
+	}
+
+	if b, err := os.ReadFile(path); err != nil || string(b) != "keep" {
+		t.Errorf("expected the file to be left alone, got %q, %v", b, err)
+	}
+}
+
+func TestListenSetsTheKeepAlivePeriod(t *testing.T) {
+	tests := []struct {
+		name   string
+		period time.Duration
+		// wantPeriod is 0 when the period is left to the listen config
+		wantPeriod time.Duration
+	}{
+		{"configured", 42 * time.Second, 42 * time.Second},
+		{"default", 0, 0},
+		{"disabled", -1, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got time.Duration
+
+			prev := setKeepAlive
+			t.Cleanup(func() { setKeepAlive = prev })
+			setKeepAlive = func(conn *net.TCPConn, period time.Duration) error {
+				got = period
+				return prev(conn, period)
+			}
+
+			l, err := Listen(context.Background(), &Configuration{
+				ListenAddress:      "127.0.0.1:0",
+				TCPKeepAlivePeriod: tt.period,
+			})
+			if err != nil {
+				t.Fatalf("listening: %v", err)
+			}
+			defer l.Close()
+
+			client, err := net.Dial("tcp", l.Addr().String())
+			if err != nil {
+				t.Fatalf("dialing: %v", err)
+			}
+			defer client.Close()
+
+			conn, err := l.Accept()
+			if err != nil {
+				t.Fatalf("accepting: %v", err)
+			}
+			defer conn.Close()
+
+			if got != tt.wantPeriod {
+				t.Errorf("expected a keep-alive period of %v on the accepted connection, got %v", tt.wantPeriod, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package app
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+)
+
+// PrewarmCheck is the name of the health check failing until connections are prewarmed
+const PrewarmCheck = "prewarm"
+
+var (
+	errPrewarming    = errors.New("prewarming dependency connections")
+	errPrewarmFailed = errors.New("prewarming dependency connections failed")
+)
+
+// PrewarmFunc opens and validates a connection to a dependency
+type PrewarmFunc func(context.Context) error
+
+// prewarmer is a named PrewarmFunc
+type prewarmer struct {
+	name string
+	fn   PrewarmFunc
+}
+
+// prewarmState tracks the outcome of Prewarm for the readiness check
+type prewarmState struct {
+	mu      sync.Mutex
+	running bool
+	err     error
+}
+
+// RegisterPrewarm adds a function called by Prewarm to open connections to the named
+// dependency ahead of the first requests needing them
+func (a *App) RegisterPrewarm(name string, fn PrewarmFunc) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.prewarmers = append(a.prewarmers, prewarmer{name: name, fn: fn})
+}
+
+// Prewarm calls every registered prewarm function the configured number of times
+// concurrently, so that as many connections are open to each dependency. Failures
+// are logged and returned. When prewarming is configured the App reports itself not
+// ready until Prewarm has run, and after it failed when configured as required.
+func (a *App) Prewarm(ctx context.Context) error {
+	cfg := a.Cfg.Prewarm
+	if cfg.Connections <= 0 {
+		return nil
+	}
+
+	a.mu.Lock()
+	prewarmers := a.prewarmers
+	a.mu.Unlock()
+
+	if cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.Timeout)
+		defer cancel()
+	}
+
+	var (
+		wg  sync.WaitGroup
+		mu  sync.Mutex
+		err error
+	)
+
+	for _, p := range prewarmers {
+		start := time.Now()
+		failed := 0
+
+		for i := 0; i < cfg.Connections; i++ {
+			wg.Add(1)
+			go func(p prewarmer) {
+				defer wg.Done()
+
+				if connErr := p.fn(ctx); connErr != nil {
+					mu.Lock()
+					failed++
+					err = multierr.Append(err, errors.Wrap(connErr, p.name))
+					mu.Unlock()
+				}
+			}(p)
+		}
+		wg.Wait()
+
+		fields := []zap.Field{
+			zap.String("dependency", p.name),
+			zap.Int("connections", cfg.Connections),
+			zap.Int("failed", failed),
+			zap.Duration("duration", time.Since(start)),
+		}
+
+		if failed > 0 {
+			a.Log.Warn("prewarming dependency connections failed", fields...)
+			continue
+		}
+		a.verbose.Info("prewarmed dependency connections", fields...)
+	}
+
+	a.prewarm.mu.Lock()
+	a.prewarm.running = false
+	a.prewarm.err = err
+	a.prewarm.mu.Unlock()
+
+	return err
+}
+
+// checkPrewarm is the health check reporting the App not ready while prewarming, and
+// after prewarming failed when it is required
+func (a *App) checkPrewarm(_ context.Context) error {
+	a.prewarm.mu.Lock()
+	defer a.prewarm.mu.Unlock()
+
+	if a.prewarm.running {
+		return errPrewarming
+	}
+
+	if a.prewarm.err != nil && a.Cfg.Prewarm.Required {
+		return errPrewarmFailed
+	}
+
+	return nil
+}
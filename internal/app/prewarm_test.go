@@ -0,0 +1,62 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestPrewarm(t *testing.T) {
+	tests := []struct {
+		name      string
+		required  bool
+		err       error
+		wantReady bool
+	}{
+		{"prewarmed", false, nil, true},
+		{"failed, optional", false, errDown, true},
+		{"failed, required", true, errDown, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			cfg.Prewarm = PrewarmConfig{Connections: 3, Required: tt.required}
+			a := NewApp(context.Background(), cfg, zap.NewNop())
+
+			var calls atomic.Int32
+			a.RegisterPrewarm("fleetdb", func(context.Context) error {
+				calls.Add(1)
+				return tt.err
+			})
+
+			if err := a.CheckHealth(context.Background())[PrewarmCheck]; !errors.Is(err, errPrewarming) {
+				t.Fatalf("expected the App not to be ready until prewarmed, got %v", err)
+			}
+
+			if err := a.Prewarm(context.Background()); !errors.Is(err, tt.err) {
+				t.Errorf("expected Prewarm to return %v, got %v", tt.err, err)
+			}
+
+			if n := calls.Load(); n != 3 {
+				t.Errorf("expected a connection per configured connection, got %d", n)
+			}
+
+			err := a.CheckHealth(context.Background())[PrewarmCheck]
+			if ready := err == nil; ready != tt.wantReady {
+				t.Errorf("expected ready %v once prewarmed, got %v", tt.wantReady, err)
+			}
+		})
+	}
+}
+
+func TestPrewarmWithoutConnections(t *testing.T) {
+	a := newTestApp(t)
+
+	if _, ok := a.CheckHealth(context.Background())[PrewarmCheck]; ok {
+		t.Error("expected no prewarm check without prewarming configured")
+	}
+}
@@ -0,0 +1,135 @@
+package app
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/pkg/errors"
+	"go.hollow.sh/toolbox/ginjwt"
+	"go.uber.org/multierr"
+	"go.uber.org/zap/zapcore"
+)
+
+// metricNamePattern matches the metric names prometheus accepts
+var metricNamePattern = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+
+var validate = newValidator()
+
+// newValidator returns a validator naming fields by their configuration keys
+func newValidator() *validator.Validate {
+	v := validator.New()
+
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name, _, _ := strings.Cut(f.Tag.Get("mapstructure"), ",")
+		if name == "" || name == "-" {
+			return f.Name
+		}
+		return name
+	})
+
+	_ = v.RegisterValidation("metric_name", func(fl validator.FieldLevel) bool {
+		return metricNamePattern.MatchString(fl.Field().String())
+	})
+
+	return v
+}
+
+// Validate checks the configuration against the constraints in its validate tags,
+// along with those spanning several fields, and reports every failure at once.
+func (c *Configuration) Validate() error {
+	var err error
+
+	if verr := validate.Struct(c); verr != nil {
+		var fieldErrs validator.ValidationErrors
+		if !errors.As(verr, &fieldErrs) {
+			return verr
+		}
+
+		for _, fe := range fieldErrs {
+			err = multierr.Append(err, errors.New(fieldErrorMessage(fe)))
+		}
+	}
+
+	if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		err = multierr.Append(err, errors.New("serving over TLS requires both tls.cert_file and tls.key_file"))
+	}
+
+	if slices.Contains(c.DisableBuiltinEndpoints, ReadinessPath) {
+		err = multierr.Append(err, errors.New("the readiness endpoint can't be disabled"))
+	}
+
+	err = multierr.Append(err, checkJWTAuth(c.JWTAuth))
+
+	if c.BufferRequestBodies && c.MaxRequestBytes < 0 {
+		err = multierr.Append(err, errors.New("buffer_request_bodies requires a max_request_bytes limit"))
+	}
+
+	if c.LogLevel != "" {
+		if _, lerr := zapcore.ParseLevel(c.LogLevel); lerr != nil {
+			err = multierr.Append(err, errors.Wrap(lerr, "log_level"))
+		}
+	}
+
+	return errors.Wrap(err, "invalid configuration")
+}
+
+// checkJWTAuth reports every enabled JWT auth config lacking the issuer or JWKS URI
+// needed to verify tokens, which would otherwise only surface once requests fail to
+// authenticate
+func checkJWTAuth(configs []ginjwt.AuthConfig) error {
+	var err error
+
+	for i, ac := range configs {
+		if !ac.Enabled {
+			continue
+		}
+
+		var missing []string
+		if ac.Issuer == "" {
+			missing = append(missing, "an issuer")
+		}
+		if ac.JWKSURI == "" {
+			missing = append(missing, "a jwks uri")
+		}
+
+		if len(missing) > 0 {
+			err = multierr.Append(err, fmt.Errorf("ginjwt_auth entry %d (issuer %q, audience %q) lacks %s",
+				i, ac.Issuer, ac.Audience, strings.Join(missing, " and ")))
+		}
+	}
+
+	return err
+}
+
+// fieldErrorMessage describes a failed constraint in terms of the configuration key
+func fieldErrorMessage(fe validator.FieldError) string {
+	// the namespace starts with the name of the Configuration type
+	_, key, _ := strings.Cut(fe.Namespace(), ".")
+
+	switch fe.Tag() {
+	case "required":
+		return key + " is required"
+	case "oneof":
+		return fmt.Sprintf("%s must be one of %s, not %q", key, strings.Join(strings.Fields(fe.Param()), ", "), fe.Value())
+	case "url":
+		return fmt.Sprintf("%s must be a URL, not %q", key, fe.Value())
+	case "hostname_port":
+		return fmt.Sprintf("%s must be a host:port, not %q", key, fe.Value())
+	case "metric_name":
+		return fmt.Sprintf("%s must be a valid prometheus metric name, not %q", key, fe.Value())
+	case "startswith":
+		return fmt.Sprintf("%s must start with %s, not %q", key, fe.Param(), fe.Value())
+	case "gt":
+		return fmt.Sprintf("%s must be greater than %s", key, fe.Param())
+	case "gte":
+		return fmt.Sprintf("%s must be at least %s", key, fe.Param())
+	case "lte":
+		return fmt.Sprintf("%s must be at most %s", key, fe.Param())
+	default:
+		return fmt.Sprintf("%s fails the %s constraint", key, fe.Tag())
+	}
+}
@@ -0,0 +1,144 @@
+package app
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"go.hollow.sh/toolbox/ginjwt"
+)
+
+// validConfig returns a Configuration that passes validation once defaults apply
+func validConfig() *Configuration {
+	cfg := &Configuration{ListenAddress: "127.0.0.1:0"}
+	applyDefaults(cfg, cfg.isSet)
+
+	return cfg
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(*Configuration)
+		wantErr string
+	}{
+		{
+			name:   "defaults",
+			modify: func(*Configuration) {},
+		},
+		{
+			name:    "missing listen address",
+			modify:  func(c *Configuration) { c.ListenAddress = "" },
+			wantErr: "listen_address is required",
+		},
+		{
+			name:    "malformed audit sink url",
+			modify:  func(c *Configuration) { c.Audit.SinkURL = "not a url" },
+			wantErr: `audit.sink_url must be a URL, not "not a url"`,
+		},
+		{
+			name:    "unknown health response",
+			modify:  func(c *Configuration) { c.HealthResponse = "verbose" },
+			wantErr: `health_response must be one of detailed, plain, not "verbose"`,
+		},
+		{
+			name:    "unknown log level",
+			modify:  func(c *Configuration) { c.LogLevel = "loud" },
+			wantErr: "log_level",
+		},
+		{
+			name:    "negative response header limit",
+			modify:  func(c *Configuration) { c.MaxResponseHeaderBytes = -1 },
+			wantErr: "max_response_header_bytes must be at least 0",
+		},
+		{
+			name:    "negative stream limit",
+			modify:  func(c *Configuration) { c.MaxConcurrentStreams = -1 },
+			wantErr: "max_concurrent_streams must be at least 0",
+		},
+		{
+			name:    "invalid metrics namespace",
+			modify:  func(c *Configuration) { c.MetricsNamespace = "fleet-api" },
+			wantErr: `metrics_namespace must be a valid prometheus metric name, not "fleet-api"`,
+		},
+		{
+			name:    "tls cert without key",
+			modify:  func(c *Configuration) { c.TLS.CertFile = "cert.pem" },
+			wantErr: "serving over TLS requires both tls.cert_file and tls.key_file",
+		},
+		{
+			name: "jwt auth lacking an issuer",
+			modify: func(c *Configuration) {
+				c.JWTAuth = []ginjwt.AuthConfig{
+					{Enabled: true, Issuer: "https://idp", JWKSURI: "https://idp/jwks"},
+					{Enabled: true, Audience: "fleet", JWKSURI: "https://other/jwks"},
+				}
+			},
+			wantErr: `ginjwt_auth entry 1 (issuer "", audience "fleet") lacks an issuer`,
+		},
+		{
+			name: "jwt auth lacking an issuer and jwks uri",
+			modify: func(c *Configuration) {
+				c.JWTAuth = []ginjwt.AuthConfig{{Enabled: true, Audience: "fleet"}}
+			},
+			wantErr: "lacks an issuer and a jwks uri",
+		},
+		{
+			name: "disabled incomplete jwt auth",
+			modify: func(c *Configuration) {
+				c.JWTAuth = []ginjwt.AuthConfig{{Audience: "fleet"}}
+			},
+		},
+		{
+			name:    "disabled readiness endpoint",
+			modify:  func(c *Configuration) { c.DisableBuiltinEndpoints = []string{"/api/version", ReadinessPath} },
+			wantErr: "the readiness endpoint can't be disabled",
+		},
+		{
+			name:    "negative shutdown drain delay",
+			modify:  func(c *Configuration) { c.ShutdownDrainDelay = -time.Second },
+			wantErr: "shutdown_drain_delay must be at least 0",
+		},
+		{
+			name:    "negative max batch size",
+			modify:  func(c *Configuration) { c.MaxBatchSize = -1 },
+			wantErr: "max_batch_size must be at least 0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			tt.modify(cfg)
+
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected an error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestValidateAggregatesFailures(t *testing.T) {
+	cfg := validConfig()
+	cfg.ListenAddress = ""
+	cfg.BasePath = "api"
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+
+	for _, want := range []string{"listen_address is required", `base_path must start with /, not "api"`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected the error to contain %q, got %v", want, err)
+		}
+	}
+}
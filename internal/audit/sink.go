@@ -0,0 +1,127 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/metal-toolbox/fleet-rest-skeleton/internal/metrics"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	shipTimeout = 5 * time.Second
+	retryDelay  = 500 * time.Millisecond
+)
+
+var errUnexpectedStatus = errors.New("unexpected response status")
+
+// Entry records the outcome of authenticating and authorizing an API request
+type Entry struct {
+	Time      time.Time `json:"time"`
+	RequestID string    `json:"request_id"`
+	Subject   string    `json:"subject"`
+	Scopes    []string  `json:"scopes"`
+	Method    string    `json:"method"`
+	Route     string    `json:"route"`
+	Status    int       `json:"status"`
+	Allowed   bool      `json:"allowed"`
+}
+
+// HTTPSink ships audit entries to an external HTTP endpoint in the background.
+// Entries are buffered up to a bound; once the buffer is full new entries are
+// dropped with a warning rather than holding up requests. The API's audit middleware
+// ships an entry for every request to a protected route.
+type HTTPSink struct {
+	url     string
+	retries int
+	client  *http.Client
+	log     *zap.Logger
+	entries chan Entry
+}
+
+// NewHTTPSink returns a sink posting entries as JSON to url, buffering up to
+// bufferSize entries and retrying each failed post up to retries times.
+func NewHTTPSink(url string, bufferSize, retries int, log *zap.Logger) *HTTPSink {
+	return &HTTPSink{
+		url:     url,
+		retries: retries,
+		client:  &http.Client{Timeout: shipTimeout},
+		log:     log,
+		entries: make(chan Entry, bufferSize),
+	}
+}
+
+// Ship queues the entry for delivery without blocking
+func (s *HTTPSink) Ship(e Entry) {
+	select {
+	case s.entries <- e:
+	default:
+		metrics.AuditShipFailure("buffer_full")
+		s.log.Warn("audit sink buffer full, dropping entry",
+			zap.String("request_id", e.RequestID),
+		)
+	}
+}
+
+// Run delivers queued entries until ctx is canceled
+func (s *HTTPSink) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e := <-s.entries:
+			if err := s.post(ctx, e); err != nil {
+				metrics.AuditShipFailure("send_failed")
+				s.log.Warn("shipping audit entry",
+					zap.String("request_id", e.RequestID),
+					zap.Error(err),
+				)
+			}
+		}
+	}
+}
+
+func (s *HTTPSink) post(ctx context.Context, e Entry) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return errors.Wrap(err, "marshaling audit entry")
+	}
+
+	for attempt := 0; ; attempt++ {
+		err = s.send(ctx, body)
+		if err == nil || attempt >= s.retries {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(retryDelay << attempt):
+		}
+	}
+}
+
+func (s *HTTPSink) send(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "composing request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("%w: %s", errUnexpectedStatus, resp.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,81 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestHTTPSinkShipsEntries(t *testing.T) {
+	var attempts atomic.Int32
+	received := make(chan Entry, 1)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// fail the first attempt to exercise the retry
+		if attempts.Add(1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+
+		var e Entry
+		if err := json.NewDecoder(r.Body).Decode(&e); err != nil {
+			t.Errorf("decoding entry: %v", err)
+		}
+		received <- e
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sink := NewHTTPSink(srv.URL, 1, 1, zap.NewNop())
+	go sink.Run(ctx)
+
+	sink.Ship(Entry{RequestID: "req-1", Subject: "api-key:reader", Allowed: true})
+
+	select {
+	case e := <-received:
+		if e.RequestID != "req-1" || e.Subject != "api-key:reader" || !e.Allowed {
+			t.Errorf("expected the shipped entry, got %+v", e)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the entry")
+	}
+
+	if n := attempts.Load(); n != 2 {
+		t.Errorf("expected 2 attempts, got %d", n)
+	}
+}
+
+func TestHTTPSinkDropsEntriesOnceFull(t *testing.T) {
+	core, logs := observer.New(zapcore.WarnLevel)
+
+	// not running, so nothing drains the buffer
+	sink := NewHTTPSink("http://127.0.0.1:0", 1, 0, zap.New(core))
+
+	done := make(chan struct{})
+	go func() {
+		sink.Ship(Entry{RequestID: "req-1"})
+		sink.Ship(Entry{RequestID: "req-2"})
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected shipping to a full buffer not to block")
+	}
+
+	dropped := logs.FilterMessage("audit sink buffer full, dropping entry").All()
+	if len(dropped) != 1 || dropped[0].ContextMap()["request_id"] != "req-2" {
+		t.Errorf("expected a warning for the dropped entry, got %v", logs.All())
+	}
+}
@@ -0,0 +1,11 @@
+// Package fleetdb defines the FleetDB client used by this service, so that handlers
+// depend on an interface which tests can replace with a mock.
+package fleetdb
+
+import "context"
+
+// FleetDB is the subset of the FleetDB API used by this service
+type FleetDB interface {
+	// Ping makes a lightweight call to FleetDB, returning an error when it is unusable
+	Ping(ctx context.Context) error
+}
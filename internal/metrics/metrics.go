@@ -1,37 +1,146 @@
 package metrics
 
 import (
+	"context"
+	"errors"
 	"log"
+	"net"
 	"net/http"
+	"runtime"
+	"runtime/debug"
 	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/metal-toolbox/fleet-rest-skeleton/internal/app"
+	"github.com/metal-toolbox/fleet-rest-skeleton/internal/version"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/net/netutil"
+)
+
+// Reasons a rollback is run
+const (
+	RollbackPublishFailure = "publish_failure"
+	RollbackStoreFailure   = "store_failure"
+)
+
+// Outcomes of an operation against a dependency
+const (
+	DependencySuccess = "success"
+	DependencyFailure = "failure"
+)
+
+// Outcomes of reloading the configuration
+const (
+	ConfigReloadSuccess = "success"
+	ConfigReloadFailure = "failure"
+)
+
+// Results of a JWKS fetch
+const (
+	JWKSFetchSuccess = "success"
+	JWKSFetchFailure = "failure"
+	JWKSFetchTimeout = "timeout"
+)
+
+const (
+	endpoint          = "0.0.0.0:9090"
+	readHeaderTimeout = 2 * time.Second
+	writeTimeout      = 10 * time.Second
 )
 
 var (
+	// registry holds every metric exposed by this service, in place of the global
+	// default registry
+	registry *prometheus.Registry
+
+	// namespace prefixes the name of every metric
+	namespace = app.AppName
+
+	// requestsServed counts every API call observed since the last Reset
+	requestsServed atomic.Uint64
+
 	apiLatencySeconds    *prometheus.HistogramVec
+	apiRequestBytes      *prometheus.HistogramVec
+	apiResponseBytes     *prometheus.HistogramVec
+	responseTTFBSeconds  *prometheus.HistogramVec
+	handlerOpSeconds     *prometheus.HistogramVec
+	dependencySeconds    *prometheus.HistogramVec
+	jwksFetchSeconds     *prometheus.HistogramVec
 	dependencyErrorCount *prometheus.CounterVec
+	responseCacheLookups *prometheus.CounterVec
+	clientDisconnects    *prometheus.CounterVec
+	apiPanics            *prometheus.CounterVec
+	rollbackCount        *prometheus.CounterVec
+	auditShipFailures    *prometheus.CounterVec
+	configReloads        *prometheus.CounterVec
+	buildInfo            *prometheus.GaugeVec
+	activeStreams        *prometheus.GaugeVec
 )
 
 func init() {
-	dependencyErrorCount = promauto.NewCounterVec(
+	Reset()
+}
+
+// Init re-creates every metric under the namespace against reg, which becomes the
+// registry exposed by metrics servers started afterwards. Until it is called metrics
+// are registered under app.AppName with a registry of their own.
+func Init(ns string, reg *prometheus.Registry) error {
+	namespace = ns
+	registry = reg
+	registerMetrics(promauto.With(registry))
+	requestsServed.Store(0)
+
+	return mirrorTo(otelProvider)
+}
+
+// Reset replaces the registry with a new one and re-creates every metric against
+// it under the current namespace, discarding all recorded values. Metrics servers
+// already listening continue to expose the previous registry.
+func Reset() {
+	if err := Init(namespace, prometheus.NewRegistry()); err != nil {
+		panic(err)
+	}
+}
+
+// Registry returns the registry holding this service's metrics
+func Registry() *prometheus.Registry {
+	return registry
+}
+
+func registerMetrics(factory promauto.Factory) {
+	dependencyErrorCount = factory.NewCounterVec(
 		prometheus.CounterOpts{
-			Namespace: app.AppName,
+			Namespace: namespace,
 			Subsystem: "dependencies",
 			Name:      "errors_total",
-			Help:      "a count of all errors attempting to reach " + app.AppName + " dependencies",
+			Help:      "a count of all errors attempting to reach " + namespace + " dependencies",
+		}, []string{
+			"dependency_name",
+			"operation",
+		},
+	)
+	dependencySeconds = factory.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "dependencies",
+			Name:      "operation_seconds",
+			Help:      "latency of operations against " + namespace + " dependencies in seconds, by outcome",
+			// buckets between 1ms to 10 s
+			Buckets: []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1.0, 2.5, 5.0, 10.0},
 		}, []string{
 			"dependency_name",
 			"operation",
+			"outcome",
 		},
 	)
-	apiLatencySeconds = promauto.NewHistogramVec(
+	apiLatencySeconds = factory.NewHistogramVec(
 		prometheus.HistogramOpts{
-			Namespace: app.AppName,
+			Namespace: namespace,
 			Subsystem: "api",
 			Name:      "latency_seconds",
 			Help:      "api latency measurements in seconds",
@@ -40,38 +149,384 @@ func init() {
 			Buckets: []float64{0.025, 0.05, 0.1, 0.25, 0.5, 0.75, 1.0, 2.5, 5.0, 7.5, 10.0},
 		}, []string{
 			"endpoint",
+			"handler",
 			"response_code",
 		},
 	)
+	handlerOpSeconds = factory.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "handler",
+			Name:      "operation_seconds",
+			Help:      "latency of named operations performed by api handlers in seconds",
+			// buckets between 1ms to 10 s
+			Buckets: []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1.0, 2.5, 5.0, 10.0},
+		}, []string{
+			"name",
+		},
+	)
+	// buckets between 64 bytes and 4 MiB
+	sizeBuckets := prometheus.ExponentialBuckets(64, 4, 9)
+	apiRequestBytes = factory.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "api",
+			Name:      "request_bytes",
+			Help:      "api request body sizes in bytes",
+			Buckets:   sizeBuckets,
+		}, []string{
+			"endpoint",
+		},
+	)
+	apiResponseBytes = factory.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "api",
+			Name:      "response_bytes",
+			Help:      "api response body sizes in bytes",
+			Buckets:   sizeBuckets,
+		}, []string{
+			"endpoint",
+		},
+	)
+	jwksFetchSeconds = factory.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "auth",
+			Name:      "jwks_fetch_seconds",
+			Help:      "latency of fetching the JWKS of every JWT issuer in seconds, by result",
+			// buckets between 10ms to 30 s
+			Buckets: []float64{0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1.0, 2.5, 5.0, 10.0, 30.0},
+		}, []string{
+			"result",
+		},
+	)
+	responseTTFBSeconds = factory.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "api",
+			Name:      "response_ttfb_seconds",
+			Help:      "time from receiving a streaming request to flushing the first response byte in seconds",
+			// buckets between 1ms to 10 s
+			Buckets: []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1.0, 2.5, 5.0, 10.0},
+		}, []string{
+			"endpoint",
+		},
+	)
+	activeStreams = factory.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "api",
+			Name:      "active_streams",
+			Help:      "the number of streaming responses being served",
+		}, []string{
+			"endpoint",
+		},
+	)
+	apiPanics = factory.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "api",
+			Name:      "panics_total",
+			Help:      "a count of panics recovered from while handling api requests",
+		}, []string{
+			"endpoint",
+		},
+	)
+	clientDisconnects = factory.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "api",
+			Name:      "client_disconnects_total",
+			Help:      "a count of clients disconnecting before their response was written",
+		}, []string{
+			"endpoint",
+		},
+	)
+	factory.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "runtime",
+			Name:      "gomaxprocs",
+			Help:      "the effective GOMAXPROCS setting",
+		}, func() float64 { return float64(runtime.GOMAXPROCS(0)) },
+	)
+	factory.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "runtime",
+			Name:      "memory_limit_bytes",
+			Help:      "the effective Go runtime soft memory limit (GOMEMLIMIT)",
+		}, func() float64 { return float64(debug.SetMemoryLimit(-1)) },
+	)
+	rollbackCount = factory.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "rollback_total",
+			Help:      "a count of rollbacks run, by what triggered them and whether they succeeded",
+		}, []string{
+			"reason",
+			"result",
+		},
+	)
+	auditShipFailures = factory.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "audit",
+			Name:      "ship_failures_total",
+			Help:      "a count of audit entries that could not be shipped to the audit sink",
+		}, []string{
+			"reason",
+		},
+	)
+	configReloads = factory.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "config",
+			Name:      "reloads_total",
+			Help:      "a count of attempts to reload the configuration, by outcome",
+		}, []string{
+			"outcome",
+		},
+	)
+	buildInfo = factory.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "build_info",
+			Help:      "a constant 1 labeled with the version of the running build",
+		}, []string{
+			"version",
+			"commit",
+			"branch",
+			"go_version",
+		},
+	)
+	responseCacheLookups = factory.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "api",
+			Name:      "response_cache_lookups_total",
+			Help:      "a count of response cache lookups by handler and result",
+		}, []string{
+			"handler",
+			"result",
+		},
+	)
 }
 
-// ListenAndServeMetrics exposes prometheus metrics as /metrics on port 9090
-func ListenAndServe() {
-	endpoint := "0.0.0.0:9090"
+// ListenAndServe exposes prometheus metrics as /metrics on port 9090. At most
+// maxConns scrapes are served concurrently; a value <= 0 leaves the listener unbounded.
+// The returned function gracefully shuts the metrics server down.
+func ListenAndServe(maxConns int) func(context.Context) error {
+	server := newServer()
 
 	go func() {
-		http.Handle("/metrics", promhttp.Handler())
-
-		server := &http.Server{
-			Addr:              endpoint,
-			ReadHeaderTimeout: 2 * time.Second,
+		l, err := net.Listen("tcp", endpoint)
+		if err != nil {
+			log.Println(err)
+			return
 		}
 
-		if err := server.ListenAndServe(); err != nil {
+		if err := server.Serve(limitListener(l, maxConns)); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			log.Println(err)
 		}
 	}()
+
+	return server.Shutdown
+}
+
+// limitListener bounds l to maxConns concurrently open connections, leaving it
+// unbounded when maxConns <= 0
+func limitListener(l net.Listener, maxConns int) net.Listener {
+	if maxConns <= 0 {
+		return l
+	}
+
+	return netutil.LimitListener(l, maxConns)
+}
+
+// newServer composes the http.Server used to expose metrics
+func newServer() *http.Server {
+	mux := http.NewServeMux()
+	// OpenMetrics is required to expose exemplars
+	mux.Handle("/metrics", promhttp.InstrumentMetricHandler(
+		registry,
+		promhttp.HandlerFor(registry, promhttp.HandlerOpts{
+			Registry:          registry,
+			EnableOpenMetrics: true,
+		}),
+	))
+
+	return &http.Server{
+		Addr:              endpoint,
+		Handler:           mux,
+		ReadHeaderTimeout: readHeaderTimeout,
+		WriteTimeout:      writeTimeout,
+	}
+}
+
+// RegisterRuntimeMetrics adds the Go runtime and process collectors to the registry.
+// Collectors that are already registered are left in place, so calling this more
+// than once is harmless.
+func RegisterRuntimeMetrics() error {
+	runtimeCollectors := []prometheus.Collector{
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	}
+
+	for _, c := range runtimeCollectors {
+		if err := registry.Register(c); err != nil {
+			var are prometheus.AlreadyRegisteredError
+			if !errors.As(err, &are) {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// RegisterBuildInfo publishes the build version through the build_info metric
+func RegisterBuildInfo(v *version.Version) {
+	buildInfo.Reset()
+	buildInfo.WithLabelValues(v.AppVersion, v.GitCommit, v.GitBranch, v.GoVersion).Set(1)
+}
+
+// ConfigReload counts an attempt to reload the configuration, by whether it succeeded
+func ConfigReload(success bool) {
+	outcome := ConfigReloadSuccess
+	if !success {
+		outcome = ConfigReloadFailure
+	}
+
+	configReloads.WithLabelValues(outcome).Inc()
 }
 
 // DependencyError provides a convenience method to hide some prometheus implementation
 // details.
 func DependencyError(name, operation string) {
 	dependencyErrorCount.WithLabelValues(name, operation).Inc()
+	otelDependencyError(name, operation)
+}
+
+// ObserveDependency observes the latency and outcome of an operation against a
+// dependency started at start, counting it as a dependency error when err is set.
+func ObserveDependency(name, operation string, start time.Time, err error) {
+	outcome := DependencySuccess
+	if err != nil {
+		outcome = DependencyFailure
+		DependencyError(name, operation)
+	}
+
+	dependencySeconds.WithLabelValues(name, operation, outcome).Observe(time.Since(start).Seconds())
 }
 
-// APICallEpilog observes the results and latency of an API call
-func APICallEpilog(start time.Time, endpoint string, responseCode int) {
+// APICallEpilog observes the results and latency of an API call. The handler is the
+// name the serving route was registered under. When ctx carries an active span, its
+// trace ID is attached to the observation as an exemplar.
+func APICallEpilog(ctx context.Context, start time.Time, endpoint, handler string, responseCode int) {
 	code := strconv.Itoa(responseCode)
 	elapsed := time.Since(start).Seconds()
-	apiLatencySeconds.WithLabelValues(endpoint, code).Observe(elapsed)
+	requestsServed.Add(1)
+	observeWithTraceExemplar(ctx, apiLatencySeconds.WithLabelValues(endpoint, handler, code), elapsed)
+	otelAPICall(ctx, elapsed, endpoint, handler, responseCode)
+}
+
+func observeWithTraceExemplar(ctx context.Context, obs prometheus.Observer, value float64) {
+	if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+		if eo, ok := obs.(prometheus.ExemplarObserver); ok {
+			eo.ObserveWithExemplar(value, prometheus.Labels{"trace_id": sc.TraceID().String()})
+			return
+		}
+	}
+
+	obs.Observe(value)
+}
+
+// HandlerOperation observes the latency of an operation performed while handling a
+// request, using the request ID as an exemplar when one is given.
+func HandlerOperation(name, requestID string, start time.Time) {
+	obs := handlerOpSeconds.WithLabelValues(name)
+	elapsed := time.Since(start).Seconds()
+
+	if eo, ok := obs.(prometheus.ExemplarObserver); ok && requestID != "" {
+		eo.ObserveWithExemplar(elapsed, prometheus.Labels{"request_id": requestID})
+		return
+	}
+
+	obs.Observe(elapsed)
+}
+
+// RequestsServed returns the total number of API calls observed
+func RequestsServed() uint64 {
+	return requestsServed.Load()
+}
+
+// APICallSizes observes the request and response body sizes of an API call. Negative
+// sizes mean the size is unknown, and are not observed.
+func APICallSizes(endpoint string, requestBytes int64, responseBytes int) {
+	if requestBytes >= 0 {
+		apiRequestBytes.WithLabelValues(endpoint).Observe(float64(requestBytes))
+	}
+
+	if responseBytes >= 0 {
+		apiResponseBytes.WithLabelValues(endpoint).Observe(float64(responseBytes))
+	}
+}
+
+// ResponseTTFB observes the time from start to the first byte of a streaming response
+// reaching the client. For streams this is a better measure of responsiveness than
+// the API latency, which spans the whole stream.
+func ResponseTTFB(endpoint string, start time.Time) {
+	responseTTFBSeconds.WithLabelValues(endpoint).Observe(time.Since(start).Seconds())
+}
+
+// JWKSFetch observes the latency of a JWKS fetch started at start, by its result
+func JWKSFetch(result string, start time.Time) {
+	jwksFetchSeconds.WithLabelValues(result).Observe(time.Since(start).Seconds())
+}
+
+// StreamStarted records a streaming response starting, to be paired with StreamEnded
+func StreamStarted(endpoint string) {
+	activeStreams.WithLabelValues(endpoint).Inc()
+}
+
+// StreamEnded records a streaming response ending
+func StreamEnded(endpoint string) {
+	activeStreams.WithLabelValues(endpoint).Dec()
+}
+
+// Rollback records a rollback run for the given reason, where err is the outcome of
+// the rollback itself. A failed rollback usually leaves state behind that needs
+// cleaning up by hand, so these are worth alerting on.
+func Rollback(reason string, err error) {
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	rollbackCount.WithLabelValues(reason, result).Inc()
+}
+
+// AuditShipFailure records an audit entry that was lost on its way to the audit sink
+func AuditShipFailure(reason string) {
+	auditShipFailures.WithLabelValues(reason).Inc()
+}
+
+// ClientDisconnect records a client going away before its response was written
+func ClientDisconnect(endpoint string) {
+	clientDisconnects.WithLabelValues(endpoint).Inc()
+}
+
+// APIPanic records a panic recovered from while handling a request to endpoint
+func APIPanic(endpoint string) {
+	apiPanics.WithLabelValues(endpoint).Inc()
+}
+
+// ResponseCacheLookup records a hit or miss in a handler's response cache
+func ResponseCacheLookup(handler string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	responseCacheLookups.WithLabelValues(handler, result).Inc()
 }
@@ -0,0 +1,451 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/metal-toolbox/fleet-rest-skeleton/internal/app"
+	"github.com/metal-toolbox/fleet-rest-skeleton/internal/version"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// familyNames gathers the names of the metric families registered with reg
+func familyNames(t *testing.T, reg *prometheus.Registry) []string {
+	t.Helper()
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("gathering: %v", err)
+	}
+
+	names := make([]string, 0, len(families))
+	for _, mf := range families {
+		names = append(names, mf.GetName())
+	}
+
+	return names
+}
+
+func TestInitRegistersUnderNamespace(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	if err := Init("fleet", reg); err != nil {
+		t.Fatalf("initializing: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := Init(app.AppName, prometheus.NewRegistry()); err != nil {
+			t.Errorf("restoring the default namespace: %v", err)
+		}
+	})
+
+	if Registry() != reg {
+		t.Error("expected the registry given to be the one exposed")
+	}
+
+	DependencyError("fleetdb", "ping")
+
+	names := familyNames(t, reg)
+	if len(names) == 0 {
+		t.Fatal("expected metrics to be registered")
+	}
+
+	for _, name := range names {
+		if !strings.HasPrefix(name, "fleet_") {
+			t.Errorf("expected every metric under the fleet namespace, got %s", name)
+		}
+	}
+}
+
+func TestDefaultNamespace(t *testing.T) {
+	Reset()
+
+	DependencyError("fleetdb", "ping")
+
+	for _, name := range familyNames(t, Registry()) {
+		if !strings.HasPrefix(name, app.AppName+"_") {
+			t.Errorf("expected every metric under the %s namespace by default, got %s", app.AppName, name)
+		}
+	}
+}
+
+// sampleCount returns the number of observations of the histogram family name with
+// the given labels
+func sampleCount(t *testing.T, name string, labels map[string]string) uint64 {
+	t.Helper()
+
+	families, err := Registry().Gather()
+	if err != nil {
+		t.Fatalf("gathering: %v", err)
+	}
+
+	for _, mf := range families {
+		if mf.GetName() != name {
+			continue
+		}
+
+	metrics:
+		for _, m := range mf.GetMetric() {
+			for _, lp := range m.GetLabel() {
+				if labels[lp.GetName()] != lp.GetValue() {
+					continue metrics
+				}
+			}
+
+			return m.GetHistogram().GetSampleCount()
+		}
+	}
+
+	return 0
+}
+
+func TestObserveDependency(t *testing.T) {
+	Reset()
+
+	start := time.Now()
+	ObserveDependency("fleetdb", "ping", start, errors.New("down"))
+	ObserveDependency("fleetdb", "ping", start, nil)
+
+	if got := testutil.ToFloat64(dependencyErrorCount.WithLabelValues("fleetdb", "ping")); got != 1 {
+		t.Errorf("expected the failure to be counted as an error, got %v", got)
+	}
+
+	for _, outcome := range []string{DependencyFailure, DependencySuccess} {
+		labels := map[string]string{"dependency_name": "fleetdb", "operation": "ping", "outcome": outcome}
+		if got := sampleCount(t, namespace+"_dependencies_operation_seconds", labels); got != 1 {
+			t.Errorf("expected the %s to be observed once, got %d", outcome, got)
+		}
+	}
+}
+
+func TestConfigReload(t *testing.T) {
+	Reset()
+
+	ConfigReload(true)
+	ConfigReload(true)
+	ConfigReload(false)
+
+	for outcome, want := range map[string]float64{ConfigReloadSuccess: 2, ConfigReloadFailure: 1} {
+		if got := testutil.ToFloat64(configReloads.WithLabelValues(outcome)); got != want {
+			t.Errorf("expected %v reloads counted as %s, got %v", want, outcome, got)
+		}
+	}
+
+	if n := testutil.CollectAndCount(configReloads); n != 2 {
+		t.Errorf("expected a series per outcome, got %d", n)
+	}
+}
+
+func TestRollback(t *testing.T) {
+	Reset()
+
+	Rollback(RollbackPublishFailure, nil)
+	Rollback(RollbackStoreFailure, errors.New("stream delete error"))
+	Rollback(RollbackStoreFailure, errors.New("stream delete error"))
+
+	tests := []struct {
+		reason string
+		result string
+		want   float64
+	}{
+		{RollbackPublishFailure, "success", 1},
+		{RollbackPublishFailure, "failure", 0},
+		{RollbackStoreFailure, "success", 0},
+		{RollbackStoreFailure, "failure", 2},
+	}
+
+	for _, tt := range tests {
+		if got := testutil.ToFloat64(rollbackCount.WithLabelValues(tt.reason, tt.result)); got != tt.want {
+			t.Errorf("%s/%s: expected %v, got %v", tt.reason, tt.result, tt.want, got)
+		}
+	}
+}
+
+func TestServerTimeouts(t *testing.T) {
+	server := newServer()
+
+	if server.ReadHeaderTimeout != readHeaderTimeout {
+		t.Errorf("expected a read header timeout of %s, got %s", readHeaderTimeout, server.ReadHeaderTimeout)
+	}
+
+	if server.WriteTimeout != writeTimeout {
+		t.Errorf("expected a write timeout of %s, got %s", writeTimeout, server.WriteTimeout)
+	}
+}
+
+// acceptOne accepts a connection from l in the background, delivering it on the
+// returned channel
+func acceptOne(t *testing.T, l net.Listener) <-chan net.Conn {
+	t.Helper()
+
+	accepted := make(chan net.Conn, 1)
+
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			close(accepted)
+			return
+		}
+		accepted <- conn
+	}()
+
+	return accepted
+}
+
+func TestLimitListener(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxConns    int
+		wantBlocked bool
+	}{
+		{"bounded", 1, true},
+		{"unbounded", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inner, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("listening: %v", err)
+			}
+
+			l := limitListener(inner, tt.maxConns)
+			t.Cleanup(func() { l.Close() })
+
+			for i := 0; i < 2; i++ {
+				conn, err := net.Dial("tcp", l.Addr().String())
+				if err != nil {
+					t.Fatalf("dialing: %v", err)
+				}
+				t.Cleanup(func() { conn.Close() })
+			}
+
+			first := <-acceptOne(t, l)
+			if first == nil {
+				t.Fatal("expected the first connection to be accepted")
+			}
+
+			second := acceptOne(t, l)
+
+			select {
+			case conn := <-second:
+				if tt.wantBlocked {
+					t.Fatal("expected the second connection to wait for the first to close")
+				}
+				conn.Close()
+				first.Close()
+
+				return
+			case <-time.After(100 * time.Millisecond):
+				if !tt.wantBlocked {
+					t.Fatal("expected the second connection to be accepted")
+				}
+			}
+
+			first.Close()
+
+			select {
+			case conn := <-second:
+				if conn == nil {
+					t.Fatal("expected the second connection to be accepted")
+				}
+				conn.Close()
+			case <-time.After(time.Second):
+				t.Fatal("expected the second connection to be accepted once the first closed")
+			}
+		})
+	}
+}
+
+func TestRegisterBuildInfo(t *testing.T) {
+	Reset()
+
+	old := &version.Version{AppVersion: "v1.1.0", GitCommit: "abc123", GitBranch: "main", GoVersion: "go1.21.6"}
+	current := &version.Version{AppVersion: "v1.2.0", GitCommit: "def456", GitBranch: "release", GoVersion: "go1.21.6"}
+
+	RegisterBuildInfo(old)
+	RegisterBuildInfo(current)
+
+	if n := testutil.CollectAndCount(buildInfo); n != 1 {
+		t.Fatalf("expected a single build_info series, got %d", n)
+	}
+
+	got := testutil.ToFloat64(buildInfo.WithLabelValues(current.AppVersion, current.GitCommit, current.GitBranch, current.GoVersion))
+	if got != 1 {
+		t.Errorf("expected build_info labeled with %s to be 1, got %v", current, got)
+	}
+}
+
+func TestRegisterRuntimeMetrics(t *testing.T) {
+	Reset()
+
+	for i := 0; i < 2; i++ {
+		if err := RegisterRuntimeMetrics(); err != nil {
+			t.Fatalf("registering runtime metrics, attempt %d: %v", i+1, err)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	newServer().Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected %d, got %d", http.StatusOK, w.Code)
+	}
+
+	body, err := io.ReadAll(w.Body)
+	if err != nil {
+		t.Fatalf("reading the scrape: %v", err)
+	}
+
+	if !strings.Contains(string(body), "\ngo_goroutines ") {
+		t.Error("expected the scrape to expose go_goroutines")
+	}
+}
+
+// latencyExemplars returns the trace IDs of the exemplars attached to the API latency
+// observations of endpoint
+func latencyExemplars(t *testing.T, endpoint string) []string {
+	t.Helper()
+
+	families, err := Registry().Gather()
+	if err != nil {
+		t.Fatalf("gathering: %v", err)
+	}
+
+	var ids []string
+	for _, mf := range families {
+		if mf.GetName() != namespace+"_api_latency_seconds" {
+			continue
+		}
+
+		for _, m := range mf.GetMetric() {
+			for _, lp := range m.GetLabel() {
+				if lp.GetName() != "endpoint" || lp.GetValue() != endpoint {
+					continue
+				}
+
+				for _, b := range m.GetHistogram().GetBucket() {
+					for _, el := range b.GetExemplar().GetLabel() {
+						if el.GetName() == "trace_id" {
+							ids = append(ids, el.GetValue())
+						}
+					}
+				}
+			}
+		}
+	}
+
+	return ids
+}
+
+func TestAPICallEpilogAttachesTheTraceID(t *testing.T) {
+	Reset()
+
+	traceID := trace.TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36}
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     trace.SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7},
+		TraceFlags: trace.FlagsSampled,
+	})
+	traced := trace.ContextWithSpanContext(context.Background(), sc)
+
+	APICallEpilog(traced, time.Now(), "/traced", "traced", 200)
+	APICallEpilog(context.Background(), time.Now(), "/untraced", "untraced", 200)
+
+	if got := latencyExemplars(t, "/traced"); len(got) != 1 || got[0] != traceID.String() {
+		t.Errorf("expected an exemplar carrying trace ID %s, got %v", traceID, got)
+	}
+
+	if got := latencyExemplars(t, "/untraced"); len(got) != 0 {
+		t.Errorf("expected no exemplar without an active span, got %v", got)
+	}
+}
+
+// counterValues returns the values of the counter family name in reg, keyed by the
+// value of label
+func counterValues(t *testing.T, reg *prometheus.Registry, name, label string) map[string]float64 {
+	t.Helper()
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("gathering: %v", err)
+	}
+
+	values := make(map[string]float64)
+	for _, mf := range families {
+		if mf.GetName() != name {
+			continue
+		}
+
+		for _, m := range mf.GetMetric() {
+			for _, lp := range m.GetLabel() {
+				if lp.GetName() == label {
+					values[lp.GetValue()] = m.GetCounter().GetValue()
+				}
+			}
+		}
+	}
+
+	return values
+}
+
+func TestRegistriesDontShareState(t *testing.T) {
+	t.Cleanup(Reset)
+
+	first, second := prometheus.NewRegistry(), prometheus.NewRegistry()
+
+	if err := Init(app.AppName, first); err != nil {
+		t.Fatalf("initializing the first registry: %v", err)
+	}
+
+	ConfigReload(true)
+	ConfigReload(true)
+
+	if err := Init(app.AppName, second); err != nil {
+		t.Fatalf("initializing the second registry: %v", err)
+	}
+
+	ConfigReload(false)
+
+	const name = app.AppName + "_config_reloads_total"
+
+	tests := []struct {
+		registry string
+		reg      *prometheus.Registry
+		want     map[string]float64
+	}{
+		{"first", first, map[string]float64{ConfigReloadSuccess: 2}},
+		{"second", second, map[string]float64{ConfigReloadFailure: 1}},
+	}
+
+	for _, tt := range tests {
+		got := counterValues(t, tt.reg, name, "outcome")
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.registry, tt.want, got)
+			continue
+		}
+
+		for outcome, want := range tt.want {
+			if got[outcome] != want {
+				t.Errorf("%s: expected %v reloads counted as %s, got %v", tt.registry, want, outcome, got[outcome])
+			}
+		}
+	}
+
+	Reset()
+
+	if Registry() == second {
+		t.Fatal("expected Reset to replace the registry")
+	}
+
+	if got := counterValues(t, Registry(), name, "outcome"); len(got) != 0 {
+		t.Errorf("expected Reset to discard the recorded values, got %v", got)
+	}
+}
@@ -0,0 +1,98 @@
+package metrics
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/metal-toolbox/fleet-rest-skeleton/internal/app"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+)
+
+const meterName = "github.com/metal-toolbox/fleet-rest-skeleton/internal/metrics"
+
+var (
+	// otelProvider provides the instruments mirroring key prometheus metrics over
+	// OTLP, a no-op unless ExportOTEL was called
+	otelProvider metric.MeterProvider = noop.NewMeterProvider()
+
+	otelAPILatency       metric.Float64Histogram
+	otelDependencyErrors metric.Int64Counter
+)
+
+// ExportOTEL mirrors the API latency and dependency error metrics to the OTLP
+// collector configured, in addition to exposing them to prometheus. The returned
+// function flushes pending metrics and stops exporting.
+func ExportOTEL(ctx context.Context, cfg app.OTELMetricsConfig) (func(context.Context) error, error) {
+	opts := []otlpmetricgrpc.Option{otlpmetricgrpc.WithEndpoint(cfg.Endpoint)}
+	if cfg.Insecure {
+		opts = append(opts, otlpmetricgrpc.WithInsecure())
+	}
+
+	exporter, err := otlpmetricgrpc.New(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	var readerOpts []sdkmetric.PeriodicReaderOption
+	if cfg.Interval > 0 {
+		readerOpts = append(readerOpts, sdkmetric.WithInterval(cfg.Interval))
+	}
+
+	provider := sdkmetric.NewMeterProvider(
+		sdkmetric.WithReader(sdkmetric.NewPeriodicReader(exporter, readerOpts...)),
+	)
+
+	if err := mirrorTo(provider); err != nil {
+		_ = provider.Shutdown(ctx)
+		return nil, err
+	}
+
+	return provider.Shutdown, nil
+}
+
+// mirrorTo creates the mirrored instruments under the namespace with the provider
+func mirrorTo(provider metric.MeterProvider) error {
+	meter := provider.Meter(meterName)
+
+	latency, err := meter.Float64Histogram(
+		namespace+".api.latency",
+		metric.WithUnit("s"),
+		metric.WithDescription("api latency measurements in seconds"),
+	)
+	if err != nil {
+		return err
+	}
+
+	dependencyErrors, err := meter.Int64Counter(
+		namespace+".dependencies.errors",
+		metric.WithDescription("a count of all errors attempting to reach "+namespace+" dependencies"),
+	)
+	if err != nil {
+		return err
+	}
+
+	otelProvider = provider
+	otelAPILatency = latency
+	otelDependencyErrors = dependencyErrors
+
+	return nil
+}
+
+func otelAPICall(ctx context.Context, elapsed float64, endpoint, handler string, responseCode int) {
+	otelAPILatency.Record(ctx, elapsed, metric.WithAttributes(
+		attribute.String("endpoint", endpoint),
+		attribute.String("handler", handler),
+		attribute.String("response_code", strconv.Itoa(responseCode)),
+	))
+}
+
+func otelDependencyError(name, operation string) {
+	otelDependencyErrors.Add(context.Background(), 1, metric.WithAttributes(
+		attribute.String("dependency_name", name),
+		attribute.String("operation", operation),
+	))
+}
@@ -0,0 +1,86 @@
+package metrics
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/metal-toolbox/fleet-rest-skeleton/internal/app"
+	"go.opentelemetry.io/otel/metric/noop"
+	collectormetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	"google.golang.org/grpc"
+)
+
+// collector is an in-memory OTLP metrics collector recording the data points
+// exported for each metric
+type collector struct {
+	collectormetricspb.UnimplementedMetricsServiceServer
+
+	mu     sync.Mutex
+	points map[string]int
+}
+
+func (c *collector) Export(_ context.Context, req *collectormetricspb.ExportMetricsServiceRequest) (*collectormetricspb.ExportMetricsServiceResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, rm := range req.GetResourceMetrics() {
+		for _, sm := range rm.GetScopeMetrics() {
+			for _, m := range sm.GetMetrics() {
+				c.points[m.GetName()] += len(m.GetHistogram().GetDataPoints()) + len(m.GetSum().GetDataPoints())
+			}
+		}
+	}
+
+	return &collectormetricspb.ExportMetricsServiceResponse{}, nil
+}
+
+// startCollector serves an in-memory collector until the test is done, returning it
+// along with its address
+func startCollector(t *testing.T) (*collector, string) {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listening: %v", err)
+	}
+
+	c := &collector{points: make(map[string]int)}
+	srv := grpc.NewServer()
+	collectormetricspb.RegisterMetricsServiceServer(srv, c)
+
+	go func() { _ = srv.Serve(l) }()
+	t.Cleanup(srv.Stop)
+
+	return c, l.Addr().String()
+}
+
+func TestExportOTELMirrorsKeyMetrics(t *testing.T) {
+	c, addr := startCollector(t)
+
+	ctx := context.Background()
+	stop, err := ExportOTEL(ctx, app.OTELMetricsConfig{Endpoint: addr, Insecure: true, Interval: time.Hour})
+	if err != nil {
+		t.Fatalf("exporting: %v", err)
+	}
+	t.Cleanup(func() { _ = mirrorTo(noop.NewMeterProvider()) })
+
+	APICallEpilog(ctx, time.Now(), "/api/echo", "echo", 200)
+	DependencyError("fleetdb", "ping")
+
+	// flushes what was recorded
+	if err := stop(ctx); err != nil {
+		t.Fatalf("stopping the export: %v", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, name := range []string{namespace + ".api.latency", namespace + ".dependencies.errors"} {
+		if c.points[name] != 1 {
+			t.Errorf("expected a data point for %s, got %d in %v", name, c.points[name], c.points)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+// Package tracing sets up exporting OpenTelemetry traces over OTLP
+package tracing
+
+import (
+	"context"
+
+	"github.com/metal-toolbox/fleet-rest-skeleton/internal/app"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"google.golang.org/grpc/credentials"
+)
+
+// Init installs a global tracer provider exporting traces to the configured OTLP
+// collector, along with the W3C trace context and baggage propagators. Nothing is
+// installed when no endpoint is configured. The returned function flushes pending
+// spans and stops exporting.
+func Init(ctx context.Context, cfg app.TracingConfig) (func(context.Context) error, error) {
+	if !cfg.Enabled() {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	opts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(cfg.Endpoint)}
+	if cfg.Insecure {
+		opts = append(opts, otlptracegrpc.WithInsecure())
+	} else {
+		opts = append(opts, otlptracegrpc.WithTLSCredentials(credentials.NewClientTLSFromCert(nil, "")))
+	}
+
+	exporter, err := otlptracegrpc.New(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	provider, err := newTracerProvider(ctx, cfg, sdktrace.WithBatcher(exporter))
+	if err != nil {
+		return nil, err
+	}
+
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+// newTracerProvider composes a tracer provider identifying the service by its
+// configured name and sampling at the configured ratio
+func newTracerProvider(ctx context.Context, cfg app.TracingConfig, opts ...sdktrace.TracerProviderOption) (*sdktrace.TracerProvider, error) {
+	res, err := resource.New(ctx, resource.WithAttributes(semconv.ServiceName(cfg.ServiceName)))
+	if err != nil {
+		return nil, err
+	}
+
+	opts = append(opts,
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+
+	return sdktrace.NewTracerProvider(opts...), nil
+}
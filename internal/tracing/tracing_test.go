@@ -0,0 +1,64 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/metal-toolbox/fleet-rest-skeleton/internal/app"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+)
+
+func TestTracerProviderAppliesConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		sampleRatio float64
+		wantSpans   int
+	}{
+		{"sampling everything", 1, 1},
+		{"sampling nothing", 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			rec := tracetest.NewSpanRecorder()
+
+			provider, err := newTracerProvider(ctx,
+				app.TracingConfig{ServiceName: "fleet-api", SampleRatio: tt.sampleRatio},
+				sdktrace.WithSpanProcessor(rec))
+			if err != nil {
+				t.Fatalf("composing the provider: %v", err)
+			}
+			t.Cleanup(func() { _ = provider.Shutdown(ctx) })
+
+			_, span := provider.Tracer("test").Start(ctx, "op")
+			span.End()
+
+			spans := rec.Ended()
+			if len(spans) != tt.wantSpans {
+				t.Fatalf("expected %d sampled spans, got %d", tt.wantSpans, len(spans))
+			}
+
+			if len(spans) == 0 {
+				return
+			}
+
+			if v, _ := spans[0].Resource().Set().Value(semconv.ServiceNameKey); v.AsString() != "fleet-api" {
+				t.Errorf("expected the configured service name, got %q", v.AsString())
+			}
+		})
+	}
+}
+
+func TestInitWithoutEndpointInstallsNothing(t *testing.T) {
+	shutdown, err := Init(context.Background(), app.TracingConfig{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if err := shutdown(context.Background()); err != nil {
+		t.Errorf("expected shutting down to be a no-op, got %v", err)
+	}
+}
@@ -2,10 +2,17 @@ package version
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"runtime"
+	"strings"
+
+	"golang.org/x/mod/semver"
 )
 
+// ErrInvalidVersion is returned when a version string can't be parsed as semver
+var ErrInvalidVersion = errors.New("invalid semantic version")
+
 var (
 	GitCommit  string
 	GitBranch  string
@@ -16,12 +23,12 @@ var (
 )
 
 type Version struct {
-	GitCommit  string `json:"git_commit"`
-	GitBranch  string `json:"git_branch"`
-	GitSummary string `json:"git_summary"`
-	BuildDate  string `json:"build_date"`
-	AppVersion string `json:"app_version"`
-	GoVersion  string `json:"go_version"`
+	GitCommit  string `json:"git_commit" yaml:"git_commit"`
+	GitBranch  string `json:"git_branch" yaml:"git_branch"`
+	GitSummary string `json:"git_summary" yaml:"git_summary"`
+	BuildDate  string `json:"build_date" yaml:"build_date"`
+	AppVersion string `json:"app_version" yaml:"app_version"`
+	GoVersion  string `json:"go_version" yaml:"go_version"`
 }
 
 func Current() *Version {
@@ -46,3 +53,47 @@ func (v *Version) MustJSON() json.RawMessage {
 	}
 	return byt
 }
+
+// Compare parses AppVersion and other as semantic versions and returns -1, 0 or 1
+// when AppVersion is lower than, equal to or greater than other. The leading "v" is
+// optional, and pre-release versions order before their release.
+func (v *Version) Compare(other string) (int, error) {
+	a, err := canonical(v.AppVersion)
+	if err != nil {
+		return 0, err
+	}
+
+	b, err := canonical(other)
+	if err != nil {
+		return 0, err
+	}
+
+	return semver.Compare(a, b), nil
+}
+
+// AtLeast reports whether AppVersion is greater than or equal to min
+func (v *Version) AtLeast(min string) (bool, error) {
+	cmp, err := v.Compare(min)
+	if err != nil {
+		return false, err
+	}
+	return cmp >= 0, nil
+}
+
+// Valid reports whether s is a semantic version, with or without the leading "v"
+func Valid(s string) bool {
+	_, err := canonical(s)
+	return err == nil
+}
+
+func canonical(s string) (string, error) {
+	if !strings.HasPrefix(s, "v") {
+		s = "v" + s
+	}
+
+	if !semver.IsValid(s) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidVersion, s)
+	}
+
+	return s, nil
+}
@@ -0,0 +1,76 @@
+package version
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		name        string
+		version     string
+		other       string
+		want        int
+		wantAtLeast bool
+		wantErr     error
+	}{
+		{name: "equal", version: "v1.2.3", other: "v1.2.3", want: 0, wantAtLeast: true},
+		{name: "greater", version: "v1.3.0", other: "v1.2.9", want: 1, wantAtLeast: true},
+		{name: "lesser", version: "v1.2.3", other: "v1.10.0", want: -1},
+		{name: "greater major", version: "v2.0.0", other: "v1.99.99", want: 1, wantAtLeast: true},
+		{name: "pre-release before its release", version: "v1.2.3-rc.1", other: "v1.2.3", want: -1},
+		{name: "release after its pre-release", version: "v1.2.3", other: "v1.2.3-rc.1", want: 1, wantAtLeast: true},
+		{name: "pre-releases", version: "v1.2.3-rc.2", other: "v1.2.3-rc.10", want: -1},
+		{name: "without the v prefix", version: "1.2.3", other: "v1.2.3", want: 0, wantAtLeast: true},
+		{name: "other without the v prefix", version: "v1.2.4", other: "1.2.3", want: 1, wantAtLeast: true},
+		{name: "invalid version", version: "latest", other: "v1.2.3", wantErr: ErrInvalidVersion},
+		{name: "invalid other", version: "v1.2.3", other: "1.2.x", wantErr: ErrInvalidVersion},
+		{name: "empty version", version: "", other: "v1.2.3", wantErr: ErrInvalidVersion},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &Version{AppVersion: tt.version}
+
+			got, err := v.Compare(tt.other)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+
+			if got != tt.want {
+				t.Errorf("expected Compare to return %d, got %d", tt.want, got)
+			}
+
+			atLeast, err := v.AtLeast(tt.other)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected AtLeast error %v, got %v", tt.wantErr, err)
+			}
+
+			if atLeast != tt.wantAtLeast {
+				t.Errorf("expected AtLeast to return %v, got %v", tt.wantAtLeast, atLeast)
+			}
+		})
+	}
+}
+
+func TestValid(t *testing.T) {
+	tests := []struct {
+		version string
+		want    bool
+	}{
+		{"v1.2.3", true},
+		{"1.2.3", true},
+		{"v1.2.3-rc.1", true},
+		{"v1.2.3+build.5", true},
+		{"", false},
+		{"v", false},
+		{"latest", false},
+		{"1.2.x", false},
+	}
+
+	for _, tt := range tests {
+		if got := Valid(tt.version); got != tt.want {
+			t.Errorf("%q: expected %v, got %v", tt.version, tt.want, got)
+		}
+	}
+}
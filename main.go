@@ -17,7 +17,12 @@ package main
 
 import (
 	"github.com/metal-toolbox/fleet-rest-skeleton/cmd"
+	_ "github.com/metal-toolbox/fleet-rest-skeleton/cmd/completion"
+	_ "github.com/metal-toolbox/fleet-rest-skeleton/cmd/healthcheck"
+	_ "github.com/metal-toolbox/fleet-rest-skeleton/cmd/metrics"
+	_ "github.com/metal-toolbox/fleet-rest-skeleton/cmd/ping"
 	_ "github.com/metal-toolbox/fleet-rest-skeleton/cmd/server"
+	_ "github.com/metal-toolbox/fleet-rest-skeleton/cmd/validate"
 	_ "github.com/metal-toolbox/fleet-rest-skeleton/cmd/version"
 )
 
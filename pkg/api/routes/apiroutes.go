@@ -0,0 +1,76 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// apiRoutes registers API functions with the registry, wrapped by wrapAPICall and
+// protected by the scopes of the action implied by the method on the given items:
+// read for GET, create for POST, update for PUT and PATCH, and delete for DELETE.
+// Its methods return the apiRoutes so that registrations can be chained.
+type apiRoutes struct {
+	r *routeRegistry
+	// schema, when set, is the JSON schema payloads must conform to
+	schema []byte
+}
+
+// api returns a registrar for API functions on top of the registry
+func (r *routeRegistry) api() apiRoutes {
+	return apiRoutes{r: r}
+}
+
+// withSchema returns a registrar for API functions whose payloads must conform to the
+// JSON schema, wrapped by wrapAPICallWithSchema
+func (a apiRoutes) withSchema(schema []byte) apiRoutes {
+	a.schema = schema
+	return a
+}
+
+// GET registers fn to read the items
+//
+//nolint:unused
+func (a apiRoutes) GET(path, name string, fn apiHandler, items ...string) apiRoutes {
+	a.r.handle(http.MethodGet, path, name, readScopes(items...), a.wrap(fn))
+	return a
+}
+
+// POST registers fn to create the items
+func (a apiRoutes) POST(path, name string, fn apiHandler, items ...string) apiRoutes {
+	a.r.handle(http.MethodPost, path, name, createScopes(items...), a.wrap(fn))
+	return a
+}
+
+// PUT registers fn to update the items
+//
+//nolint:unused
+func (a apiRoutes) PUT(path, name string, fn apiHandler, items ...string) apiRoutes {
+	a.r.handle(http.MethodPut, path, name, updateScopes(items...), a.wrap(fn))
+	return a
+}
+
+// PATCH registers fn to partially update the items
+//
+//nolint:unused
+func (a apiRoutes) PATCH(path, name string, fn apiHandler, items ...string) apiRoutes {
+	a.r.handle(http.MethodPatch, path, name, updateScopes(items...), a.wrap(fn))
+	return a
+}
+
+// DELETE registers fn to delete the items
+//
+//nolint:unused
+func (a apiRoutes) DELETE(path, name string, fn apiHandler, items ...string) apiRoutes {
+	a.r.handle(http.MethodDelete, path, name, deleteScopes(items...), a.wrap(fn))
+	return a
+}
+
+// wrap wraps fn into middleware, validating payloads against the schema when set
+func (a apiRoutes) wrap(fn apiHandler) gin.HandlerFunc {
+	if a.schema != nil {
+		return wrapAPICallWithSchema(a.schema, fn)
+	}
+
+	return wrapAPICall(fn)
+}
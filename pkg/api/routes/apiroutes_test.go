@@ -0,0 +1,92 @@
+package routes
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/metal-toolbox/fleet-rest-skeleton/internal/app"
+)
+
+func TestAPIRoutesProtectWithActionScopes(t *testing.T) {
+	setTestAuth(t, []app.APIKey{
+		{Name: "reader", Key: "reader-key", Scopes: []string{"read:things"}},
+		{Name: "creator", Key: "creator-key", Scopes: []string{"create:things"}},
+		{Name: "updater", Key: "updater-key", Scopes: []string{"update:things"}},
+		{Name: "deleter", Key: "deleter-key", Scopes: []string{"delete:things"}},
+	}, nil)
+
+	gin.SetMode(gin.TestMode)
+	g := gin.New()
+
+	fn := func(context.Context, map[string]any) (map[string]any, error) {
+		return map[string]any{"ok": true}, nil
+	}
+
+	r := newRouteRegistry(g, &app.Configuration{})
+	r.api().
+		GET("/things", "things-read", fn, "things").
+		POST("/things", "things-create", fn, "things").
+		PUT("/things", "things-update", fn, "things").
+		PATCH("/things", "things-patch", fn, "things").
+		DELETE("/things", "things-delete", fn, "things")
+
+	tests := []struct {
+		method     string
+		wantScopes []string
+		allowedKey string
+	}{
+		{http.MethodGet, []string{"read", "read:things"}, "reader-key"},
+		{http.MethodPost, []string{"create", "create:things", "write"}, "creator-key"},
+		{http.MethodPut, []string{"update", "update:things", "write"}, "updater-key"},
+		{http.MethodPatch, []string{"update", "update:things", "write"}, "updater-key"},
+		{http.MethodDelete, []string{"delete", "delete:things", "write"}, "deleter-key"},
+	}
+
+	keys := []string{"reader-key", "creator-key", "updater-key", "deleter-key"}
+
+	for i, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			info := r.info[i]
+			if info.Method != tt.method {
+				t.Fatalf("expected the %s route registered, got %s", tt.method, info.Method)
+			}
+
+			if !slices.Equal(info.Scopes, tt.wantScopes) {
+				t.Errorf("expected scopes %v, got %v", tt.wantScopes, info.Scopes)
+			}
+
+			for _, key := range keys {
+				want := http.StatusForbidden
+				if key == tt.allowedKey {
+					want = http.StatusOK
+				}
+
+				if w := serve(g, tt.method, "/things", "", apiKeyHeader, key); w.Code != want {
+					t.Errorf("%s: expected %d, got %d", key, want, w.Code)
+				}
+			}
+		})
+	}
+}
+
+func TestActionScopes(t *testing.T) {
+	tests := []struct {
+		name   string
+		scopes []string
+		want   []string
+	}{
+		{"create", createScopes("things"), []string{"create", "create:things", "write"}},
+		{"read", readScopes("things"), []string{"read", "read:things"}},
+		{"update", updateScopes("things", "things"), []string{"update", "update:things", "write"}},
+		{"delete", deleteScopes("things"), []string{"delete", "delete:things", "write"}},
+	}
+
+	for _, tt := range tests {
+		if !slices.Equal(tt.scopes, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, tt.scopes)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package routes
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/metal-toolbox/fleet-rest-skeleton/internal/app"
+	"github.com/metal-toolbox/fleet-rest-skeleton/internal/audit"
+	"go.uber.org/zap"
+)
+
+// authPassedKey is the gin context key set once a request got past the auth handler
+const authPassedKey = "auth.passed"
+
+// auditor records the outcome of authenticating and authorizing requests to the
+// audit logger, and to the audit sink when one is configured
+type auditor struct {
+	log  *zap.Logger
+	sink *audit.HTTPSink
+}
+
+// newAuditor returns an auditor logging through a logger of its own, starting the
+// audit sink when one is configured. The sink stops with the App.
+func newAuditor(theApp *app.App) *auditor {
+	a := &auditor{
+		log: theApp.Log.Named("audit"),
+	}
+
+	if cfg := theApp.Cfg.Audit; cfg.SinkURL != "" {
+		a.sink = audit.NewHTTPSink(cfg.SinkURL, cfg.BufferSize, cfg.Retries, theApp.Log)
+		go a.sink.Run(theApp.Context())
+	}
+
+	return a
+}
+
+// compose returns the middleware recording a single audit entry for every request,
+// whether or not it got past the auth handler. It must precede the auth handler,
+// which is followed by markAuthPassed.
+func (a *auditor) compose() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		e := audit.Entry{
+			Time:      time.Now(),
+			RequestID: RequestID(c),
+			Subject:   authSubject(c),
+			Scopes:    authScopes(c),
+			Method:    c.Request.Method,
+			Route:     routeTemplate(c),
+			Status:    c.Writer.Status(),
+			Allowed:   c.GetBool(authPassedKey),
+		}
+
+		a.log.Info("auth decision",
+			zap.String("request_id", e.RequestID),
+			zap.String("subject", e.Subject),
+			zap.Strings("scopes", e.Scopes),
+			zap.String("method", e.Method),
+			zap.String("route", e.Route),
+			zap.Int("status", e.Status),
+			zap.Bool("allowed", e.Allowed),
+		)
+
+		if a.sink != nil {
+			a.sink.Ship(e)
+		}
+	}
+}
+
+// markAuthPassed records that the request got past the auth handler
+func markAuthPassed(c *gin.Context) {
+	c.Set(authPassedKey, true)
+}
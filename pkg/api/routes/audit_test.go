@@ -0,0 +1,89 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/metal-toolbox/fleet-rest-skeleton/internal/app"
+	"github.com/metal-toolbox/fleet-rest-skeleton/internal/audit"
+)
+
+func TestAuditEntries(t *testing.T) {
+	restoreAuthOnCleanup(t)
+
+	shipped := make(chan audit.Entry, 4)
+	sink := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var e audit.Entry
+		if err := json.NewDecoder(r.Body).Decode(&e); err != nil {
+			t.Errorf("decoding entry: %v", err)
+		}
+		shipped <- e
+	}))
+	defer sink.Close()
+
+	h, logs := newTestHandler(t, &app.Configuration{
+		APIKeys: []app.APIKey{
+			{Name: "reader", Key: "reader-key", Scopes: []string{"read:log-level"}},
+			{Name: "writer", Key: "writer-key", Scopes: []string{"write"}},
+		},
+		Audit: app.AuditConfig{SinkURL: sink.URL},
+	})
+
+	tests := []struct {
+		name        string
+		key         string
+		wantStatus  int
+		wantAllowed bool
+		wantSubject string
+		wantScopes  []string
+	}{
+		{"allowed", "reader-key", http.StatusOK, true, "api-key:reader", []string{"read:log-level"}},
+		{"lacking the scope", "writer-key", http.StatusForbidden, false, "api-key:writer", []string{"write"}},
+		{"unauthenticated", "", http.StatusUnauthorized, false, "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := logs.FilterMessage("auth decision").Len()
+
+			var headers []string
+			if tt.key != "" {
+				headers = []string{apiKeyHeader, tt.key}
+			}
+
+			w := serve(h, http.MethodGet, "/api/log/level", "", headers...)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("expected %d, got %d", tt.wantStatus, w.Code)
+			}
+
+			entries := logs.FilterMessage("auth decision").All()[before:]
+			if len(entries) != 1 {
+				t.Fatalf("expected exactly one audit entry, got %d", len(entries))
+			}
+
+			fields := entries[0].ContextMap()
+			if entries[0].LoggerName != "audit" {
+				t.Errorf("expected the entry on the audit logger, got %q", entries[0].LoggerName)
+			}
+
+			if fields["allowed"] != tt.wantAllowed || fields["subject"] != tt.wantSubject ||
+				fields["method"] != http.MethodGet || fields["route"] != "/api/log/level" ||
+				fields["status"] != int64(tt.wantStatus) {
+				t.Errorf("unexpected audit fields %v", fields)
+			}
+
+			select {
+			case e := <-shipped:
+				if e.Allowed != tt.wantAllowed || e.Subject != tt.wantSubject || !slices.Equal(e.Scopes, tt.wantScopes) {
+					t.Errorf("expected the shipped entry to match, got %+v", e)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("timed out waiting for the entry to be shipped")
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package routes
+
+import (
+	"crypto/sha256"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/metal-toolbox/fleet-rest-skeleton/internal/app"
+	"go.hollow.sh/toolbox/ginauth"
+	"go.hollow.sh/toolbox/ginjwt"
+)
+
+const (
+	authorizationHeader = "Authorization"
+	apiKeyHeader        = "X-API-Key"
+
+	// context keys describing a caller authenticated by this package
+	authSubjectKey = "auth.subject"
+	authScopesKey  = "auth.scopes"
+)
+
+var (
+	errNoCredentials = errors.New("no credentials provided")
+	errUnknownAPIKey = errors.New("unknown api key")
+	errMissingScope  = errors.New("credentials lack a required scope")
+)
+
+var (
+	apiKeys           map[[sha256.Size]byte]app.APIKey
+	apiKeysConfigured bool
+
+	// jwtAuthConfigured is set when JWT issuers are configured, even while their keys
+	// have yet to be fetched
+	jwtAuthConfigured bool
+
+	// jwtVerifier returns the verifier for JWTs, nil until their keys are fetched
+	jwtVerifier = func() tokenVerifier {
+		if mw := authMiddleWare.Load(); mw != nil {
+			return mw
+		}
+		return nil
+	}
+)
+
+// tokenVerifier verifies a JWT presented by a caller holds one of the scopes
+type tokenVerifier interface {
+	VerifyTokenWithScopes(c *gin.Context, scopes []string) (ginauth.ClaimMetadata, error)
+}
+
+// setAPIKeys indexes the configured API keys by their digest, so that looking a key
+// up doesn't leak timing information about the keys themselves.
+func setAPIKeys(keys []app.APIKey) {
+	apiKeys = make(map[[sha256.Size]byte]app.APIKey, len(keys))
+	for _, k := range keys {
+		apiKeys[sha256.Sum256([]byte(k.Key))] = k
+	}
+	apiKeysConfigured = len(keys) > 0
+}
+
+// authConfigured indicates whether any method of authenticating callers is set up
+func authConfigured() bool {
+	return jwtAuthConfigured || apiKeysConfigured || len(clientScopes) > 0
+}
+
+// composeAuthHandler requires callers to authenticate with a verified client
+// certificate, an API key or a JWT holding at least one of the scopes. Credentials
+// are tried in that order, an API key that isn't known falling through to the JWT.
+func composeAuthHandler(scopes []string) gin.HandlerFunc {
+	if !authConfigured() {
+		return ginNoOp
+	}
+
+	return func(c *gin.Context) {
+		// client certificates mapped to no scopes fall through to the other credentials
+		if id, held, ok := mappedClientCert(clientCertIdentities(c.Request)); ok {
+			authenticateClientCert(c, id, held, scopes)
+			return
+		}
+
+		if key := c.GetHeader(apiKeyHeader); key != "" {
+			if k, ok := apiKeys[sha256.Sum256([]byte(key))]; ok {
+				authenticateAPIKey(c, k, scopes)
+				return
+			}
+
+			if !jwtAuthConfigured {
+				respondError(c, http.StatusUnauthorized, errUnknownAPIKey)
+				return
+			}
+		}
+
+		if !jwtAuthConfigured {
+			respondError(c, http.StatusUnauthorized, errNoCredentials)
+			return
+		}
+
+		authenticateJWT(c, scopes)
+	}
+}
+
+// authenticateAPIKey admits the request when the key is mapped to one of the scopes.
+// The key's name and scopes are attached to the context, so that callers denied for
+// lacking a scope are still identified.
+func authenticateAPIKey(c *gin.Context, k app.APIKey, scopes []string) {
+	c.Set(authSubjectKey, "api-key:"+k.Name)
+	c.Set(authScopesKey, k.Scopes)
+
+	if !grantsAny(k.Scopes, scopes) {
+		respondError(c, http.StatusForbidden, errMissingScope)
+	}
+}
+
+// authenticateJWT admits the request when it carries a JWT holding one of the
+// scopes, attaching the token's subject and roles to the context. The verifier is
+// resolved per request to pick up refreshed keys.
+func authenticateJWT(c *gin.Context, scopes []string) {
+	v := jwtVerifier()
+	if v == nil {
+		respondError(c, http.StatusServiceUnavailable, errJWKSUnavailable)
+		return
+	}
+
+	cm, err := v.VerifyTokenWithScopes(c, scopes)
+	if err != nil {
+		status := http.StatusUnauthorized
+
+		var authErr *ginauth.AuthError
+		if errors.As(err, &authErr) {
+			status = authErr.HTTPErrorCode
+		}
+
+		respondError(c, status, err)
+		return
+	}
+
+	c.Set(authSubjectKey, "jwt:"+cm.Subject)
+	c.Set(authScopesKey, cm.Roles)
+}
+
+// authSubject returns the authenticated caller, empty for anonymous callers
+func authSubject(c *gin.Context) string {
+	if subject := c.GetString(authSubjectKey); subject != "" {
+		return subject
+	}
+
+	if subject := ginjwt.GetSubject(c); subject != "" {
+		return "jwt:" + subject
+	}
+
+	return ""
+}
+
+// authScopes returns the scopes held by the authenticated caller, whichever way
+// they authenticated
+func authScopes(c *gin.Context) []string {
+	return c.GetStringSlice(authScopesKey)
+}
+
+// composeOptionalAuthHandler authenticates callers that present credentials,
+// attaching their identity to the context, while letting requests without any
+// through anonymously. Credentials that are presented but invalid are still rejected.
+func composeOptionalAuthHandler(scopes []string) gin.HandlerFunc {
+	if !authConfigured() {
+		return ginNoOp
+	}
+
+	required := composeAuthHandler(scopes)
+	return func(c *gin.Context) {
+		_, _, certMapped := mappedClientCert(clientCertIdentities(c.Request))
+		if c.GetHeader(authorizationHeader) == "" && c.GetHeader(apiKeyHeader) == "" && !certMapped {
+			return
+		}
+		required(c)
+	}
+}
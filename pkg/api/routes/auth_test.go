@@ -0,0 +1,263 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/metal-toolbox/fleet-rest-skeleton/internal/app"
+	"go.hollow.sh/toolbox/ginauth"
+)
+
+// fakeVerifier verifies bearer tokens against a fixed set of claims, standing in
+// for an IdP
+type fakeVerifier map[string]ginauth.ClaimMetadata
+
+func (f fakeVerifier) VerifyTokenWithScopes(c *gin.Context, scopes []string) (ginauth.ClaimMetadata, error) {
+	cm, ok := f[strings.TrimPrefix(c.GetHeader(authorizationHeader), "Bearer ")]
+	if !ok {
+		return ginauth.ClaimMetadata{}, ginauth.NewAuthenticationError("invalid token")
+	}
+
+	if !grantsAny(cm.Roles, scopes) {
+		return ginauth.ClaimMetadata{}, ginauth.NewAuthorizationError("missing scope")
+	}
+
+	return cm, nil
+}
+
+// restoreAuthOnCleanup restores the auth configuration once the test is done
+func restoreAuthOnCleanup(t *testing.T) {
+	t.Helper()
+
+	prevKeys, prevKeysConfigured := apiKeys, apiKeysConfigured
+	prevJWT, prevVerifier := jwtAuthConfigured, jwtVerifier
+	prevMW := authMiddleWare.Load()
+	t.Cleanup(func() {
+		apiKeys, apiKeysConfigured = prevKeys, prevKeysConfigured
+		jwtAuthConfigured, jwtVerifier = prevJWT, prevVerifier
+		authMiddleWare.Store(prevMW)
+	})
+}
+
+// setTestAuth configures the API keys and, when verifier isn't nil, JWT auth for
+// the duration of the test
+func setTestAuth(t *testing.T, keys []app.APIKey, verifier tokenVerifier) {
+	t.Helper()
+
+	restoreAuthOnCleanup(t)
+	setAPIKeys(keys)
+	jwtAuthConfigured = verifier != nil
+	jwtVerifier = func() tokenVerifier { return verifier }
+}
+
+// newAuthTestRouter serves the caller's subject and scopes from a route requiring
+// the read scope
+func newAuthTestRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.GET("/protected", composeAuthHandler([]string{"read"}), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"subject": authSubject(c), "scopes": authScopes(c)})
+	})
+
+	return r
+}
+
+func TestAuthHandler(t *testing.T) {
+	keys := []app.APIKey{
+		{Name: "reader", Key: "reader-key", Scopes: []string{"read"}},
+		{Name: "writer", Key: "writer-key", Scopes: []string{"write"}},
+	}
+
+	verifier := fakeVerifier{
+		"reader-token": {Subject: "alice", Roles: []string{"read"}},
+		"writer-token": {Subject: "bob", Roles: []string{"write"}},
+	}
+
+	tests := []struct {
+		name        string
+		jwt         bool
+		headers     []string
+		wantStatus  int
+		wantSubject string
+		wantScopes  []string
+	}{
+		{
+			name:        "valid key",
+			headers:     []string{apiKeyHeader, "reader-key"},
+			wantStatus:  http.StatusOK,
+			wantSubject: "api-key:reader",
+			wantScopes:  []string{"read"},
+		},
+		{
+			name:       "valid key lacking the scope",
+			headers:    []string{apiKeyHeader, "writer-key"},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "unknown key without jwt auth",
+			headers:    []string{apiKeyHeader, "nope"},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:        "unknown key with a valid jwt",
+			jwt:         true,
+			headers:     []string{apiKeyHeader, "nope", authorizationHeader, "Bearer reader-token"},
+			wantStatus:  http.StatusOK,
+			wantSubject: "jwt:alice",
+			wantScopes:  []string{"read"},
+		},
+		{
+			name:       "unknown key with an invalid jwt",
+			jwt:        true,
+			headers:    []string{apiKeyHeader, "nope", authorizationHeader, "Bearer forged"},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:        "absent key with jwt fallback",
+			jwt:         true,
+			headers:     []string{authorizationHeader, "Bearer reader-token"},
+			wantStatus:  http.StatusOK,
+			wantSubject: "jwt:alice",
+			wantScopes:  []string{"read"},
+		},
+		{
+			name:       "absent key with a jwt lacking the scope",
+			jwt:        true,
+			headers:    []string{authorizationHeader, "Bearer writer-token"},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "no credentials",
+			jwt:        true,
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var v tokenVerifier
+			if tt.jwt {
+				v = verifier
+			}
+			setTestAuth(t, keys, v)
+
+			w := serve(newAuthTestRouter(), http.MethodGet, "/protected", "", tt.headers...)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("expected %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+
+			if w.Code != http.StatusOK {
+				return
+			}
+
+			var body struct {
+				Subject string   `json:"subject"`
+				Scopes  []string `json:"scopes"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decoding body: %v", err)
+			}
+
+			if body.Subject != tt.wantSubject {
+				t.Errorf("expected subject %q, got %q", tt.wantSubject, body.Subject)
+			}
+
+			if !slices.Equal(body.Scopes, tt.wantScopes) {
+				t.Errorf("expected scopes %v, got %v", tt.wantScopes, body.Scopes)
+			}
+		})
+	}
+}
+
+func TestAuthHandlerWithoutJWKS(t *testing.T) {
+	setTestAuth(t, nil, nil)
+	jwtAuthConfigured = true
+
+	w := serve(newAuthTestRouter(), http.MethodGet, "/protected", "", authorizationHeader, "Bearer reader-token")
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 until the keys are fetched, got %d", w.Code)
+	}
+}
+
+func TestOptionalAuth(t *testing.T) {
+	restoreAuthOnCleanup(t)
+
+	h, _ := newTestHandler(t, &app.Configuration{
+		APIKeys: []app.APIKey{
+			{Name: "reader", Key: "reader-key", Scopes: []string{"read:identity"}},
+			{Name: "writer", Key: "writer-key", Scopes: []string{"write"}},
+		},
+	})
+
+	jwtAuthConfigured = true
+	verifier := fakeVerifier{"reader-token": {Subject: "alice", Roles: []string{"read:identity"}}}
+	jwtVerifier = func() tokenVerifier { return verifier }
+
+	tests := []struct {
+		name       string
+		headers    []string
+		wantStatus int
+		wantBody   whoAmIResponse
+	}{
+		{
+			name:       "anonymous",
+			wantStatus: http.StatusOK,
+			wantBody:   whoAmIResponse{},
+		},
+		{
+			name:       "valid key",
+			headers:    []string{apiKeyHeader, "reader-key"},
+			wantStatus: http.StatusOK,
+			wantBody:   whoAmIResponse{Authenticated: true, Subject: "api-key:reader", Scopes: []string{"read:identity"}},
+		},
+		{
+			name:       "valid token",
+			headers:    []string{authorizationHeader, "Bearer reader-token"},
+			wantStatus: http.StatusOK,
+			wantBody:   whoAmIResponse{Authenticated: true, Subject: "jwt:alice", Scopes: []string{"read:identity"}},
+		},
+		{
+			name:       "invalid token",
+			headers:    []string{authorizationHeader, "Bearer forged"},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "unknown key",
+			headers:    []string{apiKeyHeader, "nope"},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "key lacking the scope",
+			headers:    []string{apiKeyHeader, "writer-key"},
+			wantStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(h, http.MethodGet, "/api/whoami", "", tt.headers...)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("expected %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+
+			if w.Code != http.StatusOK {
+				return
+			}
+
+			var body whoAmIResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decoding body: %v", err)
+			}
+
+			if body.Authenticated != tt.wantBody.Authenticated || body.Subject != tt.wantBody.Subject ||
+				!slices.Equal(body.Scopes, tt.wantBody.Scopes) {
+				t.Errorf("expected %+v, got %+v", tt.wantBody, body)
+			}
+		})
+	}
+}
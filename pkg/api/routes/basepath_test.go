@@ -0,0 +1,83 @@
+package routes
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/metal-toolbox/fleet-rest-skeleton/internal/app"
+	"gopkg.in/yaml.v3"
+)
+
+// restoreBasePathOnCleanup restores the base path once the test is done
+func restoreBasePathOnCleanup(t *testing.T) {
+	t.Helper()
+
+	prev := basePath
+	t.Cleanup(func() { basePath = prev })
+}
+
+func TestRoutesAreServedUnderTheBasePath(t *testing.T) {
+	restoreBasePathOnCleanup(t)
+
+	h, _ := newTestHandler(t, &app.Configuration{BasePath: "/fleet/"})
+
+	tests := []struct {
+		method     string
+		path       string
+		wantStatus int
+	}{
+		{http.MethodGet, "/fleet/api/version", http.StatusOK},
+		{http.MethodGet, "/fleet/_health/liveness", http.StatusOK},
+		{http.MethodGet, "/fleet" + app.ReadinessPath, http.StatusOK},
+		{http.MethodGet, "/fleet" + openAPIPath, http.StatusOK},
+		{http.MethodGet, "/api/version", http.StatusNotFound},
+		{http.MethodGet, "/_health/liveness", http.StatusNotFound},
+		{http.MethodGet, openAPIPath, http.StatusNotFound},
+		{http.MethodGet, "/fleetapi/version", http.StatusNotFound},
+		{http.MethodDelete, "/fleet/api/version", http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		if w := serve(h, tt.method, tt.path, ""); w.Code != tt.wantStatus {
+			t.Errorf("%s %s: expected %d, got %d", tt.method, tt.path, tt.wantStatus, w.Code)
+		}
+	}
+
+	if got := serve(h, http.MethodDelete, "/fleet/api/version", "").Header().Get("Allow"); got != http.MethodGet {
+		t.Errorf("expected the allowed methods of the route under the base path, got %q", got)
+	}
+
+	var spec struct {
+		Servers []struct {
+			URL string `yaml:"url"`
+		} `yaml:"servers"`
+	}
+	if err := yaml.Unmarshal(serve(h, http.MethodGet, "/fleet"+openAPIPath, "").Body.Bytes(), &spec); err != nil {
+		t.Fatalf("decoding the document: %v", err)
+	}
+
+	if len(spec.Servers) != 1 || spec.Servers[0].URL != "/fleet" {
+		t.Errorf("expected the document to list the base path as its server, got %+v", spec.Servers)
+	}
+}
+
+func TestTrimBasePath(t *testing.T) {
+	restoreBasePathOnCleanup(t)
+	basePath = "/fleet"
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/fleet/api/echo", "/api/echo"},
+		{"/fleet", "/"},
+		{"/fleetapi/echo", "/fleetapi/echo"},
+		{"/api/echo", "/api/echo"},
+	}
+
+	for _, tt := range tests {
+		if got := trimBasePath(tt.path); got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.path, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+var errBodyTooLarge = errors.New("request body too large")
+
+// composeBodyLimit caps request bodies at limit bytes. Requests declaring a larger
+// body are rejected up front, and reads past the limit fail with an
+// *http.MaxBytesError.
+func composeBodyLimit(limit int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.ContentLength > limit {
+			respondError(c, http.StatusRequestEntityTooLarge, fmt.Errorf("%w, limit is %d bytes", errBodyTooLarge, limit))
+			return
+		}
+
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+		c.Next()
+	}
+}
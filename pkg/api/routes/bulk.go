@@ -0,0 +1,85 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+var (
+	errBatchTooLarge = errors.New("batch exceeds the maximum size")
+	errEmptyItem     = errors.New("item has no fields")
+)
+
+// bulkResult reports the outcome of creating one item of a bulk request
+type bulkResult struct {
+	Index  int    `json:"index"`
+	Status int    `json:"status"`
+	ID     string `json:"id,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// createItem is the per-item create operation. The skeleton only validates the
+// item and assigns it an ID; a real service would persist it here.
+func createItem(_ context.Context, item map[string]any) (string, error) {
+	if len(item) == 0 {
+		return "", errEmptyItem
+	}
+	return uuid.NewString(), nil
+}
+
+// composeBulkCreateHandler creates every item in a JSON array and reports a result
+// per item. The response is a 200 when all items were created and a 207 when any
+// failed. Batches larger than maxBatch are rejected outright, and items not yet
+// processed when the request context ends are reported as unavailable.
+func composeBulkCreateHandler(maxBatch int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var items []map[string]any
+		if err := c.ShouldBindJSON(&items); err != nil {
+			respondBindError(c, err)
+			return
+		}
+
+		if len(items) > maxBatch {
+			respondError(c, http.StatusBadRequest, fmt.Errorf("%w of %d items", errBatchTooLarge, maxBatch))
+			return
+		}
+
+		ctx := c.Request.Context()
+		results := make([]bulkResult, len(items))
+		failed := 0
+
+		for i, item := range items {
+			results[i] = bulkResult{Index: i}
+
+			if err := ctx.Err(); err != nil {
+				failed++
+				results[i].Status = http.StatusServiceUnavailable
+				results[i].Error = err.Error()
+				continue
+			}
+
+			id, err := createItem(ctx, item)
+			if err != nil {
+				failed++
+				results[i].Status = http.StatusBadRequest
+				results[i].Error = err.Error()
+				continue
+			}
+
+			results[i].Status = http.StatusCreated
+			results[i].ID = id
+		}
+
+		status := http.StatusOK
+		if failed > 0 {
+			status = http.StatusMultiStatus
+		}
+
+		c.JSON(status, gin.H{"results": results})
+	}
+}
@@ -0,0 +1,93 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/metal-toolbox/fleet-rest-skeleton/internal/app"
+)
+
+func TestBulkCreate(t *testing.T) {
+	h, _ := newTestHandler(t, &app.Configuration{MaxBatchSize: 3})
+
+	tests := []struct {
+		name         string
+		body         string
+		wantStatus   int
+		wantStatuses []int
+		wantErr      error
+	}{
+		{
+			name:         "below the limit",
+			body:         `[{"name": "a"}]`,
+			wantStatus:   http.StatusOK,
+			wantStatuses: []int{http.StatusCreated},
+		},
+		{
+			name:         "at the limit",
+			body:         `[{"name": "a"}, {"name": "b"}, {"name": "c"}]`,
+			wantStatus:   http.StatusOK,
+			wantStatuses: []int{http.StatusCreated, http.StatusCreated, http.StatusCreated},
+		},
+		{
+			name:       "over the limit",
+			body:       `[{"name": "a"}, {"name": "b"}, {"name": "c"}, {"name": "d"}]`,
+			wantStatus: http.StatusBadRequest,
+			wantErr:    errBatchTooLarge,
+		},
+		{
+			name:         "mixed item errors",
+			body:         `[{"name": "a"}, {}, {"name": "c"}]`,
+			wantStatus:   http.StatusMultiStatus,
+			wantStatuses: []int{http.StatusCreated, http.StatusBadRequest, http.StatusCreated},
+		},
+		{
+			name:       "not an array",
+			body:       `{"name": "a"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(h, http.MethodPost, "/api/bulk", tt.body)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("expected %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+
+			if tt.wantErr != nil {
+				if msg := decodeError(t, w).Message; !strings.HasPrefix(msg, tt.wantErr.Error()) {
+					t.Errorf("expected %q, got %q", tt.wantErr, msg)
+				}
+			}
+
+			if tt.wantStatuses == nil {
+				return
+			}
+
+			var body struct {
+				Results []bulkResult `json:"results"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decoding body: %v", err)
+			}
+
+			if len(body.Results) != len(tt.wantStatuses) {
+				t.Fatalf("expected %d results, got %d", len(tt.wantStatuses), len(body.Results))
+			}
+
+			for i, r := range body.Results {
+				if r.Index != i || r.Status != tt.wantStatuses[i] {
+					t.Errorf("result %d: expected index %d and status %d, got %+v", i, i, tt.wantStatuses[i], r)
+				}
+
+				created := r.Status == http.StatusCreated
+				if created != (r.ID != "") || created != (r.Error == "") {
+					t.Errorf("result %d: expected an id when created and an error otherwise, got %+v", i, r)
+				}
+			}
+		})
+	}
+}
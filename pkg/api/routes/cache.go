@@ -0,0 +1,165 @@
+package routes
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/metal-toolbox/fleet-rest-skeleton/internal/metrics"
+)
+
+// cachedResponse is a successful response captured for replay
+type cachedResponse struct {
+	status int
+	header http.Header
+	body   []byte
+	stored time.Time
+}
+
+// responseCache is an in-memory cache of GET responses keyed by cacheKey. Entries are
+// only ever invalidated by their TTL expiring.
+type responseCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]cachedResponse
+}
+
+func newResponseCache(ttl time.Duration) *responseCache {
+	return &responseCache{
+		ttl:     ttl,
+		entries: make(map[string]cachedResponse),
+	}
+}
+
+func (rc *responseCache) get(key string, now time.Time) (cachedResponse, bool) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	entry, ok := rc.entries[key]
+	if !ok {
+		return cachedResponse{}, false
+	}
+
+	if now.Sub(entry.stored) >= rc.ttl {
+		delete(rc.entries, key)
+		return cachedResponse{}, false
+	}
+
+	return entry, true
+}
+
+func (rc *responseCache) set(key string, entry cachedResponse) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	// sweep anything expired so the cache doesn't grow without bound
+	for k, e := range rc.entries {
+		if entry.stored.Sub(e.stored) >= rc.ttl {
+			delete(rc.entries, k)
+		}
+	}
+
+	rc.entries[key] = entry
+}
+
+// bodyRecorder captures everything written to the response body
+type bodyRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *bodyRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *bodyRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// cacheKey identifies the response to a request, so callers never get a response
+// rendered for someone else or in a format they didn't ask for
+func cacheKey(c *gin.Context) string {
+	return strings.Join([]string{
+		authSubject(c),
+		negotiatedFormat(c),
+		c.Request.URL.Path + "?" + c.Request.URL.RawQuery,
+	}, "\x00")
+}
+
+// composeResponseCache serves repeated GETs for a route from the cache until the
+// TTL expires, recording hits and misses against the handler name. Responses of
+// authenticated routes are marked private, as they are cached per caller, so that
+// shared caches never serve one caller's response to another.
+func composeResponseCache(name string, ttl time.Duration, authenticated bool) gin.HandlerFunc {
+	rc := newResponseCache(ttl)
+
+	cacheControl := func(maxAge time.Duration) string {
+		if authenticated {
+			return fmt.Sprintf("private, max-age=%d", int(maxAge.Seconds()))
+		}
+		return fmt.Sprintf("max-age=%d", int(maxAge.Seconds()))
+	}
+
+	vary := "Accept"
+	if authenticated {
+		vary = strings.Join([]string{authorizationHeader, apiKeyHeader, "Accept"}, ", ")
+	}
+
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodGet {
+			c.Next()
+			return
+		}
+
+		now := time.Now()
+		key := cacheKey(c)
+
+		if entry, ok := rc.get(key, now); ok {
+			metrics.ResponseCacheLookup(name, true)
+
+			age := now.Sub(entry.stored)
+			for k, v := range entry.header {
+				c.Writer.Header()[k] = v
+			}
+			c.Header("Age", strconv.Itoa(int(age.Seconds())))
+			c.Header("Cache-Control", cacheControl(ttl-age))
+			c.Header("Vary", vary)
+			c.Data(entry.status, entry.header.Get("Content-Type"), entry.body)
+			c.Abort()
+			return
+		}
+
+		metrics.ResponseCacheLookup(name, false)
+
+		w := &bodyRecorder{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Header("Age", "0")
+		c.Header("Cache-Control", cacheControl(ttl))
+		c.Header("Vary", vary)
+		c.Next()
+
+		if w.Status() != http.StatusOK {
+			return
+		}
+
+		header := w.Header().Clone()
+		header.Del(requestIDHeader)
+		header.Del("Age")
+		header.Del("Cache-Control")
+		header.Del("Vary")
+
+		rc.set(key, cachedResponse{
+			status: w.Status(),
+			header: header,
+			body:   w.body.Bytes(),
+			stored: now,
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package routes
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/metal-toolbox/fleet-rest-skeleton/internal/app"
+)
+
+func TestResponseCacheKeysOnCallerAndFormat(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	calls := 0
+	r := gin.New()
+	r.GET("/cached",
+		func(c *gin.Context) {
+			c.Set(authSubjectKey, c.GetHeader("X-Test-Subject"))
+		},
+		composeResponseCache("cached", time.Minute, true),
+		func(c *gin.Context) {
+			calls++
+			respondPayload(c, http.StatusOK, gin.H{"subject": c.GetString(authSubjectKey)})
+		},
+	)
+
+	requests := []struct {
+		name      string
+		subject   string
+		accept    string
+		wantCalls int
+	}{
+		{"first request", "alice", "application/json", 1},
+		{"repeated request", "alice", "application/json", 1},
+		{"other caller", "bob", "application/json", 2},
+		{"other format", "alice", mimeMsgpack, 3},
+		{"repeated other format", "alice", mimeMsgpack, 3},
+	}
+
+	for _, tt := range requests {
+		w := serve(r, http.MethodGet, "/cached", "", "X-Test-Subject", tt.subject, "Accept", tt.accept)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d", tt.name, w.Code)
+		}
+
+		if calls != tt.wantCalls {
+			t.Errorf("%s: expected the handler to have run %d times, got %d", tt.name, tt.wantCalls, calls)
+		}
+
+		if got := w.Header().Get("Content-Type"); !strings.HasPrefix(got, tt.accept) {
+			t.Errorf("%s: expected content type %q, got %q", tt.name, tt.accept, got)
+		}
+	}
+}
+
+func TestOpenAPIDocumentIsCached(t *testing.T) {
+	h, _ := newTestHandler(t, &app.Configuration{})
+
+	w := serve(h, http.MethodGet, openAPIPath, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+
+	if got := w.Header().Get("Cache-Control"); got != "max-age=300" {
+		t.Errorf("expected the document to be cacheable for the TTL, got Cache-Control %q", got)
+	}
+
+	again := serve(h, http.MethodGet, openAPIPath, "")
+	if again.Body.String() != w.Body.String() {
+		t.Error("expected the cached document to match the original")
+	}
+}
+
+func TestResponseCacheHeaders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name             string
+		authenticated    bool
+		wantCacheControl string
+		wantVary         string
+	}{
+		{"public", false, "max-age=", "Accept"},
+		{"authenticated", true, "private, max-age=", "Authorization, X-API-Key, Accept"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.GET("/cached", composeResponseCache("cached", time.Minute, tt.authenticated), func(c *gin.Context) {
+				respondPayload(c, http.StatusOK, gin.H{"ok": true})
+			})
+
+			for _, attempt := range []string{"miss", "hit"} {
+				w := serve(r, http.MethodGet, "/cached", "")
+
+				if got := w.Header().Get("Cache-Control"); !strings.HasPrefix(got, tt.wantCacheControl) {
+					t.Errorf("%s: expected Cache-Control starting %q, got %q", attempt, tt.wantCacheControl, got)
+				}
+
+				if got := w.Header().Values("Vary"); len(got) != 1 || got[0] != tt.wantVary {
+					t.Errorf("%s: expected Vary %q, got %q", attempt, tt.wantVary, got)
+				}
+			}
+		})
+	}
+}
+
+func TestCachedRoutesRequiringAuthArePrivate(t *testing.T) {
+	setTestAuth(t, []app.APIKey{{Name: "reader", Key: "reader-key", Scopes: []string{"read:things"}}}, nil)
+
+	gin.SetMode(gin.TestMode)
+	g := gin.New()
+
+	r := newRouteRegistry(g, &app.Configuration{})
+	r.handleCached("/things", "things", readScopes("things"), time.Minute, func(c *gin.Context) {
+		respondPayload(c, http.StatusOK, gin.H{"subject": authSubject(c)})
+	})
+
+	w := serve(g, http.MethodGet, "/things", "", apiKeyHeader, "reader-key")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+
+	if got := w.Header().Get("Cache-Control"); !strings.HasPrefix(got, "private,") {
+		t.Errorf("expected the response to be private, got Cache-Control %q", got)
+	}
+}
@@ -0,0 +1,56 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/metal-toolbox/fleet-rest-skeleton/internal/version"
+	"go.uber.org/zap"
+)
+
+const (
+	clientVersionHeader      = "X-Client-Version"
+	upgradeRecommendedHeader = "X-Upgrade-Recommended"
+)
+
+// composeClientVersionCheck compares the X-Client-Version sent by clients with the
+// configured minimums. Clients below recommended are told to upgrade through the
+// X-Upgrade-Recommended header, while those below required are rejected with a 426.
+// Either minimum may be empty, and requests without a valid client version are let
+// through.
+func composeClientVersionCheck(recommended, required string, l *zap.Logger) (gin.HandlerFunc, error) {
+	for _, v := range []string{recommended, required} {
+		if v != "" && !version.Valid(v) {
+			return nil, fmt.Errorf("%w: %q", version.ErrInvalidVersion, v)
+		}
+	}
+
+	return func(c *gin.Context) {
+		cv := c.GetHeader(clientVersionHeader)
+		if cv == "" {
+			return
+		}
+
+		client := &version.Version{AppVersion: cv}
+
+		if required != "" {
+			if ok, err := client.AtLeast(required); err == nil && !ok {
+				respondError(c, http.StatusUpgradeRequired,
+					fmt.Errorf("client version %s is no longer supported, upgrade to %s or later", cv, required))
+				return
+			}
+		}
+
+		if recommended != "" {
+			if ok, err := client.AtLeast(recommended); err == nil && !ok {
+				c.Header(upgradeRecommendedHeader, recommended)
+				l.Info("outdated client version",
+					zap.String("client_version", cv),
+					zap.String("recommended", recommended),
+					zap.String("request_id", RequestID(c)),
+				)
+			}
+		}
+	}, nil
+}
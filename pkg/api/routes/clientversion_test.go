@@ -0,0 +1,52 @@
+package routes
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/metal-toolbox/fleet-rest-skeleton/internal/app"
+)
+
+func TestClientVersionCheck(t *testing.T) {
+	h, logs := newTestHandler(t, &app.Configuration{
+		RecommendedClientVersion: "v1.5.0",
+		RequiredClientVersion:    "v1.0.0",
+	})
+
+	tests := []struct {
+		name        string
+		version     string
+		wantStatus  int
+		wantUpgrade string
+	}{
+		{"no version", "", http.StatusOK, ""},
+		{"compatible version", "v1.6.0", http.StatusOK, ""},
+		{"soft-old version", "v1.2.0", http.StatusOK, "v1.5.0"},
+		{"hard-old version", "v0.9.0", http.StatusUpgradeRequired, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs.TakeAll()
+
+			var headers []string
+			if tt.version != "" {
+				headers = []string{clientVersionHeader, tt.version}
+			}
+
+			w := serve(h, http.MethodGet, "/api/version", "", headers...)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("expected %d, got %d", tt.wantStatus, w.Code)
+			}
+
+			if got := w.Header().Get(upgradeRecommendedHeader); got != tt.wantUpgrade {
+				t.Errorf("expected %s %q, got %q", upgradeRecommendedHeader, tt.wantUpgrade, got)
+			}
+
+			logged := logs.FilterMessage("outdated client version").Len() > 0
+			if want := tt.wantUpgrade != ""; logged != want {
+				t.Errorf("expected the outdated version to be logged: %v, got %v", want, logged)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// concurrencyRetryAfter is how long, in seconds, clients are asked to wait when all
+// request slots are taken
+const concurrencyRetryAfter = 1
+
+var errTooManyRequestsInFlight = errors.New("too many requests in flight, retry later")
+
+// composeConcurrencyLimit caps the number of requests served at once, protecting
+// dependencies from more work than they can take. Requests beyond the cap are turned
+// away with a 503 and a Retry-After header. Health endpoints are never turned away,
+// so that probes keep working under load.
+func composeConcurrencyLimit(limit int) gin.HandlerFunc {
+	slots := make(chan struct{}, limit)
+
+	return func(c *gin.Context) {
+		if strings.HasPrefix(trimBasePath(c.Request.URL.Path), healthPathPrefix) {
+			return
+		}
+
+		select {
+		case slots <- struct{}{}:
+			// released even when a handler panics
+			defer func() { <-slots }()
+			c.Next()
+		default:
+			c.Header("Retry-After", strconv.Itoa(concurrencyRetryAfter))
+			respondError(c, http.StatusServiceUnavailable, errTooManyRequestsInFlight)
+		}
+	}
+}
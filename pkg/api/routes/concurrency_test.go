@@ -0,0 +1,72 @@
+package routes
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestConcurrencyLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	const limit = 3
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	r := gin.New()
+	r.Use(composeConcurrencyLimit(limit))
+	r.GET("/slow", func(c *gin.Context) {
+		started <- struct{}{}
+		<-release
+		c.Status(http.StatusOK)
+	})
+	r.GET("/panic", func(*gin.Context) { panic("boom") })
+	r.GET("/_health/liveness", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	held := make(chan int, limit)
+	for i := 0; i < limit; i++ {
+		go func() { held <- serve(r, http.MethodGet, "/slow", "").Code }()
+		<-started
+	}
+
+	w := serve(r, http.MethodGet, "/slow", "")
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected the request beyond the cap to get 503, got %d", w.Code)
+	}
+
+	if got := w.Header().Get("Retry-After"); got != strconv.Itoa(concurrencyRetryAfter) {
+		t.Errorf("expected Retry-After %d, got %q", concurrencyRetryAfter, got)
+	}
+
+	if w := serve(r, http.MethodGet, "/_health/liveness", ""); w.Code != http.StatusOK {
+		t.Errorf("expected health endpoints to bypass the cap, got %d", w.Code)
+	}
+
+	close(release)
+	for i := 0; i < limit; i++ {
+		if code := <-held; code != http.StatusOK {
+			t.Errorf("expected requests within the cap to get 200, got %d", code)
+		}
+	}
+
+	// panicking handlers release their slot
+	for i := 0; i < limit+1; i++ {
+		func() {
+			defer func() { _ = recover() }()
+			serve(r, http.MethodGet, "/panic", "")
+		}()
+	}
+
+	go func() { held <- serve(r, http.MethodGet, "/slow", "").Code }()
+	select {
+	case <-started:
+		if code := <-held; code != http.StatusOK {
+			t.Errorf("expected 200 once a slot is free, got %d", code)
+		}
+	case code := <-held:
+		t.Fatalf("expected a slot to be free once handlers panicked, got %d", code)
+	}
+}
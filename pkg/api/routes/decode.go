@@ -0,0 +1,86 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// exposeDecodeErrors returns raw decode errors to callers in place of their translation
+var exposeDecodeErrors bool
+
+// respondBindError replies to a request whose body couldn't be decoded, telling
+// bodies over the size limit apart from malformed ones. Callers get a friendly
+// description of what is wrong with the body, while the raw error, which may
+// mention internal types, is only logged.
+func respondBindError(c *gin.Context, err error) {
+	var mbe *http.MaxBytesError
+	if errors.As(err, &mbe) {
+		respondError(c, http.StatusRequestEntityTooLarge, fmt.Errorf("%w, limit is %d bytes", errBodyTooLarge, mbe.Limit))
+		return
+	}
+
+	msg := decodeErrorMessage(err)
+	if exposeDecodeErrors {
+		msg = err.Error()
+	}
+
+	respondError(c, http.StatusBadRequest, err, withMessage(msg))
+}
+
+// decodeErrorMessage translates common JSON and msgpack decoding errors into messages
+// meaningful to API callers
+func decodeErrorMessage(err error) string {
+	var (
+		se  *json.SyntaxError
+		ute *json.UnmarshalTypeError
+	)
+
+	switch {
+	case errors.Is(err, io.EOF):
+		return "request body is empty"
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return "request body is truncated JSON"
+	case errors.As(err, &se):
+		return fmt.Sprintf("request body is malformed JSON at offset %d", se.Offset)
+	case errors.As(err, &ute):
+		if ute.Field == "" {
+			return "request body must be a JSON " + jsonKind(ute.Type)
+		}
+		return fmt.Sprintf("field %q must be a JSON %s, got %s", ute.Field, jsonKind(ute.Type), ute.Value)
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		return "unknown field " + strings.TrimPrefix(err.Error(), "json: unknown field ")
+	case strings.HasPrefix(err.Error(), "msgpack: unknown field "):
+		return "unknown field " + strings.TrimPrefix(err.Error(), "msgpack: unknown field ")
+	default:
+		return "request body is invalid"
+	}
+}
+
+// jsonKind names the JSON type that decodes into t
+func jsonKind(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.String:
+		return "string"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Map, reflect.Struct:
+		return "object"
+	case reflect.Pointer:
+		return jsonKind(t.Elem())
+	default:
+		return "value"
+	}
+}
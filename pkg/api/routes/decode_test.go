@@ -0,0 +1,47 @@
+package routes
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/metal-toolbox/fleet-rest-skeleton/internal/app"
+)
+
+func TestBindErrorMessages(t *testing.T) {
+	tests := []struct {
+		name    string
+		expose  bool
+		body    string
+		wantMsg string
+	}{
+		{"malformed", false, `{"a": }`, "request body is malformed JSON at offset"},
+		{"truncated", false, `{"a": 1`, "request body is truncated JSON"},
+		{"not an object", false, `[1, 2]`, "request body must be a JSON object"},
+		{"raw error exposed", true, `[1, 2]`, "json: cannot unmarshal array"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, _ := newTestHandler(t, &app.Configuration{ExposeDecodeErrors: tt.expose})
+
+			w := serve(h, http.MethodPost, "/api/echo", tt.body)
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("expected 400, got %d", w.Code)
+			}
+
+			if msg := decodeError(t, w).Message; !strings.HasPrefix(msg, tt.wantMsg) {
+				t.Errorf("expected a message starting %q, got %q", tt.wantMsg, msg)
+			}
+		})
+	}
+}
+
+func TestOversizedBodyIsTooLarge(t *testing.T) {
+	h, _ := newTestHandler(t, &app.Configuration{MaxRequestBytes: 16})
+
+	w := serve(h, http.MethodPost, "/api/echo", `{"key": "a value longer than the limit"}`)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413, got %d", w.Code)
+	}
+}
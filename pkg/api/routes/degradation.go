@@ -0,0 +1,151 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/metal-toolbox/fleet-rest-skeleton/internal/app"
+)
+
+const (
+	readinessOK          = "ok"
+	readinessDegraded    = "degraded"
+	readinessUnavailable = "unavailable"
+
+	// dependencyRecheckInterval is how often health checks are run to gate requests
+	dependencyRecheckInterval = 2 * time.Second
+	// dependencyCheckTimeout bounds the health checks run to gate requests
+	dependencyCheckTimeout = time.Second
+
+	healthPathPrefix = "/_health/"
+)
+
+var errDependencyDown = errors.New("temporarily unavailable, a dependency is down")
+
+// routeClass returns the class of routes a request method belongs to under the
+// degradation policy
+func routeClass(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return app.RouteClassRead
+	default:
+		return app.RouteClassWrite
+	}
+}
+
+// failingChecks returns the names of the failed health checks, sorted
+func failingChecks(results map[string]error) []string {
+	var down []string
+	for name, err := range results {
+		if err != nil {
+			down = append(down, name)
+		}
+	}
+	slices.Sort(down)
+
+	return down
+}
+
+// readiness summarizes the failed health checks under the degradation policy. The
+// service is degraded while it can still serve reads, and unavailable otherwise.
+// Checks the policy doesn't cover, e.g. prewarming, leave it unavailable.
+func readiness(cfg *app.Configuration, down []string) (string, int) {
+	if len(down) == 0 {
+		return readinessOK, http.StatusOK
+	}
+
+	for _, dep := range down {
+		if !cfg.DegradationCovers(dep) || cfg.DegradationAffects(dep, app.RouteClassRead) {
+			return readinessUnavailable, http.StatusServiceUnavailable
+		}
+	}
+
+	return readinessDegraded, http.StatusOK
+}
+
+// dependencyGate tracks which dependencies are down, running the App's health checks
+// in the background every dependencyRecheckInterval so that requests only ever read
+// the last results.
+type dependencyGate struct {
+	app *app.App
+
+	mu   sync.Mutex
+	down []string
+}
+
+// run refreshes the failing dependencies every dependencyRecheckInterval until ctx
+// is done
+func (g *dependencyGate) run(ctx context.Context) {
+	ticker := time.NewTicker(dependencyRecheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			g.refresh(ctx)
+		}
+	}
+}
+
+func (g *dependencyGate) refresh(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, dependencyCheckTimeout)
+	defer cancel()
+
+	down := failingChecks(g.app.CheckHealth(ctx))
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.down = down
+}
+
+// affecting returns the dependencies that are down and prevent serving routes of class
+func (g *dependencyGate) affecting(class string) []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var affected []string
+	for _, dep := range g.down {
+		if g.app.Cfg.DegradationAffects(dep, class) {
+			affected = append(affected, dep)
+		}
+	}
+
+	return affected
+}
+
+// composeDegradationGate rejects requests with a 503 while a dependency they need is
+// down according to the degradation policy. Requests for routes with a fallback are
+// let through, marked as degraded, for the route to serve its fallback once the
+// caller is authenticated. Health endpoints are never rejected. Dependencies are checked once
+// up front, and then in the background until the App is done.
+func composeDegradationGate(theApp *app.App, fallbacks func(method, path string) *routeFallback) gin.HandlerFunc {
+	gate := &dependencyGate{app: theApp}
+	gate.refresh(theApp.Context())
+	go gate.run(theApp.Context())
+
+	return func(c *gin.Context) {
+		if strings.HasPrefix(trimBasePath(c.Request.URL.Path), healthPathPrefix) {
+			return
+		}
+
+		if down := gate.affecting(routeClass(c.Request.Method)); len(down) > 0 {
+			if fallbacks(c.Request.Method, trimBasePath(c.FullPath())) != nil {
+				c.Set(degradedKey, down)
+				return
+			}
+
+			respondError(c, http.StatusServiceUnavailable,
+				fmt.Errorf("%w: %s", errDependencyDown, strings.Join(down, ", ")))
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/metal-toolbox/fleet-rest-skeleton/internal/app"
+)
+
+var errDown = errors.New("down")
+
+// stubFleetDB is a FleetDB client whose ping fails with err when set
+type stubFleetDB struct {
+	err error
+}
+
+func (s stubFleetDB) Ping(context.Context) error {
+	return s.err
+}
+
+func TestDegradation(t *testing.T) {
+	tests := []struct {
+		name          string
+		degradation   map[string][]string
+		wantRead      int
+		wantWrite     int
+		wantReadiness string
+	}{
+		{
+			name:          "write dependency down",
+			degradation:   map[string][]string{"fleetdb": {app.RouteClassWrite}},
+			wantRead:      http.StatusOK,
+			wantWrite:     http.StatusServiceUnavailable,
+			wantReadiness: readinessDegraded,
+		},
+		{
+			name:          "read dependency down",
+			degradation:   map[string][]string{"fleetdb": {app.RouteClassRead, app.RouteClassWrite}},
+			wantRead:      http.StatusServiceUnavailable,
+			wantWrite:     http.StatusServiceUnavailable,
+			wantReadiness: readinessUnavailable,
+		},
+		{
+			name:          "unlisted dependency down",
+			degradation:   map[string][]string{"event stream": {app.RouteClassWrite}},
+			wantRead:      http.StatusOK,
+			wantWrite:     http.StatusOK,
+			wantReadiness: readinessUnavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, _ := newTestHandler(t, &app.Configuration{Degradation: tt.degradation},
+				app.WithFleetDBClient(stubFleetDB{err: errDown}))
+
+			if w := serve(h, http.MethodGet, openAPIPath, ""); w.Code != tt.wantRead {
+				t.Errorf("expected reads to get %d, got %d", tt.wantRead, w.Code)
+			}
+
+			if w := serve(h, http.MethodPost, "/api/echo", "{}"); w.Code != tt.wantWrite {
+				t.Errorf("expected writes to get %d, got %d", tt.wantWrite, w.Code)
+			}
+
+			w := serve(h, http.MethodGet, app.ReadinessPath, "")
+			if got := decodeHealth(t, w).Status; got != tt.wantReadiness {
+				t.Errorf("expected readiness %q, got %q", tt.wantReadiness, got)
+			}
+		})
+	}
+}
+
+func TestVersionIsServedFromItsFallbackWhileDegraded(t *testing.T) {
+	h, _ := newTestHandler(t,
+		&app.Configuration{Degradation: map[string][]string{"fleetdb": {app.RouteClassRead}}},
+		app.WithFleetDBClient(stubFleetDB{err: errDown}))
+
+	w := serve(h, http.MethodGet, versionPath, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+
+	if got := w.Header().Get(degradedHeader); got != "true" {
+		t.Errorf("expected the response to be marked degraded, got %q", got)
+	}
+}
+
+// newFallbackTestRouter serves a protected route with a fallback, counting calls to
+// its handler, whose requests are marked as degraded while degraded is set
+func newFallbackTestRouter(degraded *bool, calls *int) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	g := gin.New()
+	g.Use(func(c *gin.Context) {
+		if *degraded {
+			c.Set(degradedKey, []string{"fleetdb"})
+		}
+	})
+
+	r := newRouteRegistry(g, &app.Configuration{})
+	r.handleWithFallback(http.MethodGet, "/thing", "thing", readScopes("thing"),
+		gin.H{"source": "static"}, time.Minute,
+		func(c *gin.Context) {
+			*calls++
+			c.JSON(http.StatusOK, gin.H{"source": "live", "subject": authSubject(c)})
+		})
+
+	return g
+}
+
+func TestRouteFallback(t *testing.T) {
+	setTestAuth(t, []app.APIKey{
+		{Name: "alice", Key: "alice-key", Scopes: []string{"read:thing"}},
+		{Name: "bob", Key: "bob-key", Scopes: []string{"read:thing"}},
+		{Name: "eve", Key: "eve-key", Scopes: []string{"read:other"}},
+	}, nil)
+
+	var (
+		degraded bool
+		calls    int
+	)
+	h := newFallbackTestRouter(&degraded, &calls)
+
+	get := func(key string) (int, map[string]string, string) {
+		var headers []string
+		if key != "" {
+			headers = []string{apiKeyHeader, key}
+		}
+
+		w := serve(h, http.MethodGet, "/thing", "", headers...)
+
+		var body map[string]string
+		_ = json.Unmarshal(w.Body.Bytes(), &body)
+
+		return w.Code, body, w.Header().Get(degradedHeader)
+	}
+
+	// the real handler runs while nothing is down, its response recorded
+	if code, body, hdr := get("alice-key"); code != http.StatusOK || body["source"] != "live" || hdr != "" {
+		t.Fatalf("expected the live response, got %d %v degraded %q", code, body, hdr)
+	}
+
+	degraded = true
+
+	tests := []struct {
+		name        string
+		key         string
+		wantStatus  int
+		wantSource  string
+		wantSubject string
+	}{
+		{"anonymous caller", "", http.StatusUnauthorized, "", ""},
+		{"caller lacking the scope", "eve-key", http.StatusForbidden, "", ""},
+		{"caller with a recorded response", "alice-key", http.StatusOK, "live", "api-key:alice"},
+		{"caller without a recorded response", "bob-key", http.StatusOK, "static", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, body, hdr := get(tt.key)
+			if code != tt.wantStatus {
+				t.Fatalf("expected %d, got %d", tt.wantStatus, code)
+			}
+
+			if code != http.StatusOK {
+				return
+			}
+
+			if hdr != "true" {
+				t.Errorf("expected the response to be marked degraded, got %q", hdr)
+			}
+
+			if body["source"] != tt.wantSource || body["subject"] != tt.wantSubject {
+				t.Errorf("expected source %q for %q, got %v", tt.wantSource, tt.wantSubject, body)
+			}
+		})
+	}
+
+	if calls != 1 {
+		t.Errorf("expected the handler to only run while nothing was down, ran %d times", calls)
+	}
+}
@@ -0,0 +1,121 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	errRouteNotFound    = errors.New("invalid request - route not found")
+	errMethodNotAllowed = errors.New("invalid request - method not allowed")
+)
+
+// errorResponse is the body of every error response returned by the API
+type errorResponse struct {
+	Code      int    `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id,omitempty"`
+	// AllowedMethods lists the methods served for the path of a 405 response
+	AllowedMethods []string `json:"allowed_methods,omitempty"`
+	// SchemaErrors lists the violations of a 400 response to a payload failing its schema
+	SchemaErrors []string `json:"schema_errors,omitempty"`
+}
+
+// errorOption adds details to the body of an error response
+type errorOption func(*errorResponse)
+
+// withMessage replaces the message of an error response, which is otherwise the text
+// of the error, e.g. with one that is safe to show callers
+func withMessage(msg string) errorOption {
+	return func(r *errorResponse) {
+		r.Message = msg
+	}
+}
+
+// withAllowedMethods lists the methods served for the path of a 405 response
+func withAllowedMethods(methods []string) errorOption {
+	return func(r *errorResponse) {
+		r.AllowedMethods = methods
+	}
+}
+
+// withSchemaErrors lists the schema violations of a 400 response
+func withSchemaErrors(violations []string) errorOption {
+	return func(r *errorResponse) {
+		r.SchemaErrors = violations
+	}
+}
+
+// respondError aborts the request with the given status and a structured error body.
+// The error is also recorded on the context so that it is logged.
+func respondError(c *gin.Context, status int, err error, opts ...errorOption) {
+	body := errorResponse{
+		Code:      status,
+		Message:   err.Error(),
+		RequestID: RequestID(c),
+	}
+	for _, opt := range opts {
+		opt(&body)
+	}
+
+	_ = c.Error(err)
+	c.AbortWithStatusJSON(status, body)
+}
+
+// MissingDerivedFieldError indicates that data the service derives for itself, rather
+// than taking it from the caller, is missing although the request depends on it.
+// It is reported as a 422 naming the field instead of a generic 500.
+type MissingDerivedFieldError struct {
+	Field string
+}
+
+func (e *MissingDerivedFieldError) Error() string {
+	return "missing required derived field: " + e.Field
+}
+
+// ReservedKeysError indicates the caller sent keys that are reserved for use by the
+// service. It is reported as a 400 naming the offending keys.
+type ReservedKeysError struct {
+	Keys []string
+}
+
+func (e *ReservedKeysError) Error() string {
+	return "payload contains reserved keys: " + strings.Join(e.Keys, ", ")
+}
+
+// StatusError carries the status an API function wants its error reported with
+type StatusError struct {
+	Status int
+	Err    error
+}
+
+func (e *StatusError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *StatusError) Unwrap() error {
+	return e.Err
+}
+
+// statusForError maps an error returned by an API function to the response status
+func statusForError(err error) int {
+	var mdfe *MissingDerivedFieldError
+	if errors.As(err, &mdfe) {
+		return http.StatusUnprocessableEntity
+	}
+
+	var rke *ReservedKeysError
+	if errors.As(err, &rke) {
+		return http.StatusBadRequest
+	}
+
+	var se *StatusError
+	if errors.As(err, &se) {
+		return se.Status
+	}
+
+	return http.StatusInternalServerError
+}
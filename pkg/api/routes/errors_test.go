@@ -0,0 +1,89 @@
+package routes
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/metal-toolbox/fleet-rest-skeleton/internal/app"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestErrorResponsesShareAShape(t *testing.T) {
+	h, _ := newTestHandler(t, &app.Configuration{})
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{"bad bind", http.MethodPost, "/api/echo", "{", http.StatusBadRequest},
+		{"handler error", http.MethodPost, "/api/error", "{}", http.StatusInternalServerError},
+		{"unknown route", http.MethodGet, "/api/nothing-here", "", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(h, tt.method, tt.path, tt.body)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d", w.Code, tt.status)
+			}
+
+			body := decodeError(t, w)
+			if body.Code != tt.status || body.Message == "" || body.RequestID == "" {
+				t.Errorf("body = %+v, want the status, a message and a request ID", body)
+			}
+		})
+	}
+}
+
+func TestClientErrorsAreNotLoggedAsErrors(t *testing.T) {
+	h, logs := newTestHandler(t, &app.Configuration{})
+
+	tests := []struct {
+		path  string
+		level zapcore.Level
+	}{
+		{"/api/nothing-here", zapcore.InfoLevel},
+		{"/api/error", zapcore.ErrorLevel},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			method := http.MethodGet
+			if tt.path == "/api/error" {
+				method = http.MethodPost
+			}
+			serve(h, method, tt.path, "")
+
+			entries := logs.TakeAll()
+			last := entries[len(entries)-1]
+			if last.Level != tt.level {
+				t.Errorf("logged %q at %s, want %s", last.Message, last.Level, tt.level)
+			}
+		})
+	}
+}
+
+func TestMissingDerivedFieldIsUnprocessable(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.POST("/derived", wrapAPICall(func(_ context.Context, _ map[string]any) (map[string]any, error) {
+		return nil, fmt.Errorf("resolving server: %w", &MissingDerivedFieldError{Field: "facility_code"})
+	}))
+
+	w := serve(r, http.MethodPost, "/derived", "{}")
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusUnprocessableEntity)
+	}
+
+	if body := decodeError(t, w); !strings.Contains(body.Message, "facility_code") {
+		t.Errorf("message = %q, want it to name the field", body.Message)
+	}
+}
@@ -0,0 +1,109 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// degradedHeader marks responses served from a fallback rather than by the route
+	degradedHeader = "X-Degraded"
+
+	// degradedKey holds the dependencies that are down when the degradation gate
+	// leaves a request to its route's fallback
+	degradedKey = "degraded"
+)
+
+// routeFallback holds what a route serves while a dependency it needs is down: the
+// last successful response for the same request when one is recent enough, and a
+// static payload otherwise.
+type routeFallback struct {
+	static any
+	recent *responseCache
+}
+
+func newRouteFallback(static any, maxAge time.Duration) *routeFallback {
+	fb := &routeFallback{static: static}
+	if maxAge > 0 {
+		fb.recent = newResponseCache(maxAge)
+	}
+
+	return fb
+}
+
+// fallbackKey identifies the recorded response to replay for a request, so callers
+// are only ever replayed responses rendered for them
+func fallbackKey(c *gin.Context) string {
+	return c.Request.Method + "\x00" + cacheKey(c)
+}
+
+// serve responds with a 200 from the fallback, returning false when it has nothing
+// to serve the request with
+func (fb *routeFallback) serve(c *gin.Context) bool {
+	if fb.recent != nil {
+		if entry, ok := fb.recent.get(fallbackKey(c), time.Now()); ok {
+			for k, v := range entry.header {
+				c.Writer.Header()[k] = v
+			}
+			c.Header(degradedHeader, "true")
+			c.Data(http.StatusOK, entry.header.Get("Content-Type"), entry.body)
+			c.Abort()
+			return true
+		}
+	}
+
+	if fb.static == nil {
+		return false
+	}
+
+	c.Header(degradedHeader, "true")
+	c.JSON(http.StatusOK, fb.static)
+	c.Abort()
+
+	return true
+}
+
+// composeFallback serves requests the degradation gate let through while a
+// dependency is down from the fallback, failing them with a 503 when it has nothing
+// to serve. It runs after auth, so fallbacks are only ever served to callers allowed
+// on the route. Otherwise the route's successful responses are recorded for the
+// fallback to replay.
+func composeFallback(fb *routeFallback) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if down := c.GetStringSlice(degradedKey); len(down) > 0 {
+			if !fb.serve(c) {
+				respondError(c, http.StatusServiceUnavailable,
+					fmt.Errorf("%w: %s", errDependencyDown, strings.Join(down, ", ")))
+			}
+
+			return
+		}
+
+		if fb.recent == nil {
+			return
+		}
+
+		now := time.Now()
+		w := &bodyRecorder{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Next()
+
+		if w.Status() != http.StatusOK {
+			return
+		}
+
+		header := w.Header().Clone()
+		header.Del(requestIDHeader)
+
+		fb.recent.set(fallbackKey(c), cachedResponse{
+			status: w.Status(),
+			header: header,
+			body:   w.body.Bytes(),
+			stored: now,
+		})
+	}
+}
@@ -1,8 +1,131 @@
 package routes
 
-import "errors"
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"time"
 
-func apiEcho(m map[string]any) (map[string]any, error) {
+	"github.com/gin-gonic/gin"
+	"github.com/metal-toolbox/fleet-rest-skeleton/internal/app"
+	"github.com/metal-toolbox/fleet-rest-skeleton/internal/version"
+)
+
+const (
+	versionPath = "/api/version"
+	// versionFallbackMaxAge is how long the last version response is replayed for
+	// while degraded, before falling back to the bare version
+	versionFallbackMaxAge = time.Minute
+)
+
+// versionResponse is the build version along with process details computed per request
+type versionResponse struct {
+	*version.Version
+	UptimeSeconds    float64 `json:"uptime_seconds"`
+	GoMaxProcs       int     `json:"gomaxprocs"`
+	MemoryLimitBytes int64   `json:"memory_limit_bytes"`
+}
+
+func composeVersionHandler(theApp *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, versionResponse{
+			Version:          version.Current(),
+			UptimeSeconds:    theApp.Uptime().Seconds(),
+			GoMaxProcs:       runtime.GOMAXPROCS(0),
+			MemoryLimitBytes: debug.SetMemoryLimit(-1),
+		})
+	}
+}
+
+// whoAmIResponse describes the caller of the whoami endpoint
+type whoAmIResponse struct {
+	Authenticated bool     `json:"authenticated"`
+	Subject       string   `json:"subject,omitempty"`
+	Scopes        []string `json:"scopes,omitempty"`
+}
+
+// apiWhoAmI responds with the identity of the caller, or reports them as anonymous
+// when they presented no credentials
+func apiWhoAmI(c *gin.Context) {
+	subject := authSubject(c)
+
+	respondPayload(c, http.StatusOK, whoAmIResponse{
+		Authenticated: subject != "",
+		Subject:       subject,
+		Scopes:        authScopes(c),
+	})
+}
+
+// echoSchema is the JSON schema of validated echo payloads, objects naming what is
+// echoed
+var echoSchema = []byte(`{
+	"type": "object",
+	"required": ["name"],
+	"properties": {
+		"name": {"type": "string", "minLength": 1}
+	}
+}`)
+
+// echoVersions are the versions of the versioned echo's payloads. Version 1 wrapped
+// the object echoed in a payload field, version 2 takes it as it is.
+var echoVersions = schemaVersions{
+	latest: "2",
+	decoders: map[string]schemaDecoder{
+		"1": func(c *gin.Context) (map[string]any, error) {
+			var v1 struct {
+				Payload map[string]any `json:"payload"`
+			}
+			if err := bindPayload(c, &v1); err != nil && !errors.Is(err, io.EOF) {
+				return nil, err
+			}
+
+			if v1.Payload == nil {
+				return map[string]any{}, nil
+			}
+
+			return v1.Payload, nil
+		},
+		"2": func(c *gin.Context) (map[string]any, error) {
+			m := make(map[string]any)
+			// an empty body is taken as an empty object
+			if err := bindPayload(c, &m); err != nil && !errors.Is(err, io.EOF) {
+				return nil, err
+			}
+
+			return m, nil
+		},
+	},
+}
+
+// composeEcho returns the echo handler, rejecting payloads with top-level keys that
+// start with any of the reserved prefixes.
+func composeEcho(reservedPrefixes []string) apiHandler {
+	return func(ctx context.Context, m map[string]any) (map[string]any, error) {
+		var reserved []string
+		for k := range m {
+			for _, p := range reservedPrefixes {
+				if p != "" && strings.HasPrefix(k, p) {
+					reserved = append(reserved, k)
+					break
+				}
+			}
+		}
+
+		if len(reserved) > 0 {
+			slices.Sort(reserved)
+			return nil, &ReservedKeysError{Keys: reserved}
+		}
+
+		return apiEcho(ctx, m)
+	}
+}
+
+func apiEcho(_ context.Context, m map[string]any) (map[string]any, error) {
 	rm := make(map[string]any)
 
 	for k, v := range m {
@@ -12,6 +135,44 @@ func apiEcho(m map[string]any) (map[string]any, error) {
 	return rm, nil
 }
 
-func apiError(_ map[string]any) (map[string]any, error) {
-	return nil, errors.New("bad times")
+// apiEchoRaw responds with the request body and content type exactly as received,
+// preserving key order and number formatting lost by decoding the body.
+func apiEchoRaw(c *gin.Context) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	contentType := c.GetHeader("Content-Type")
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	c.Data(http.StatusOK, contentType, body)
+}
+
+var errInvalidErrorStatus = errors.New("status must be a 4xx or 5xx status code")
+
+// errorRequest is the payload of /api/error
+type errorRequest struct {
+	// Status is the status to fail with, a 500 when absent
+	Status *int `json:"status" msgpack:"status"`
+}
+
+// apiError always fails, with the status given in the payload's status field or a 500
+// when there is none. Unknown fields are rejected, so that a misspelled status isn't
+// mistaken for an absent one.
+func apiError(_ context.Context, req errorRequest) (struct{}, error) {
+	err := errors.New("bad times")
+
+	if req.Status == nil {
+		return struct{}{}, err
+	}
+
+	if *req.Status < 400 || *req.Status > 599 {
+		return struct{}{}, &StatusError{Status: http.StatusBadRequest, Err: errInvalidErrorStatus}
+	}
+
+	return struct{}{}, &StatusError{Status: *req.Status, Err: err}
 }
@@ -0,0 +1,213 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/metal-toolbox/fleet-rest-skeleton/internal/app"
+	"github.com/metal-toolbox/fleet-rest-skeleton/internal/metrics"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestEchoRejectsReservedKeys(t *testing.T) {
+	h, _ := newTestHandler(t, &app.Configuration{EchoReservedKeyPrefixes: []string{"_"}})
+
+	w := serve(h, http.MethodPost, "/api/echo", `{"_id": 1, "_rev": 2, "name": "a"}`)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", w.Code)
+	}
+
+	if msg := decodeError(t, w).Message; !strings.Contains(msg, "_id, _rev") {
+		t.Errorf("expected the message to name the reserved keys, got %q", msg)
+	}
+
+	if w := serve(h, http.MethodPost, "/api/echo", `{"name": "a"}`); w.Code != http.StatusOK {
+		t.Errorf("expected a payload without reserved keys to be echoed, got %d", w.Code)
+	}
+}
+
+func TestEchoRawPreservesTheBody(t *testing.T) {
+	h, _ := newTestHandler(t, &app.Configuration{})
+
+	body := `{"zeta": 1, "alpha": 12345678901234567890, "mid": [3, 2, 1]}`
+
+	w := serve(h, http.MethodPost, "/api/echo/raw", body)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+
+	if got := w.Body.String(); got != body {
+		t.Errorf("expected the body verbatim, got %s", got)
+	}
+
+	if got := w.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("expected the request's content type, got %q", got)
+	}
+}
+
+func TestEchoBodies(t *testing.T) {
+	h, _ := newTestHandler(t, &app.Configuration{})
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{"empty body", "", http.StatusOK, `{}`},
+		{"valid json", `{"name":"a"}`, http.StatusOK, `{"name":"a"}`},
+		{"malformed json", `{"name":`, http.StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(h, http.MethodPost, "/api/echo", tt.body)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("expected %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+
+			if tt.wantBody != "" && strings.TrimSpace(w.Body.String()) != tt.wantBody {
+				t.Errorf("expected %s, got %s", tt.wantBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestErrorStatus(t *testing.T) {
+	h, _ := newTestHandler(t, &app.Configuration{})
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantMsg    string
+	}{
+		{"default", `{}`, http.StatusInternalServerError, "bad times"},
+		{"teapot", `{"status": 418}`, http.StatusTeapot, "bad times"},
+		{"server error", `{"status": 503}`, http.StatusServiceUnavailable, "bad times"},
+		{"success status", `{"status": 200}`, http.StatusBadRequest, errInvalidErrorStatus.Error()},
+		{"fractional status", `{"status": 418.5}`, http.StatusBadRequest, `field "status" must be a JSON number, got number 418.5`},
+		{"non numeric status", `{"status": "418"}`, http.StatusBadRequest, `field "status" must be a JSON number, got string`},
+		{"unknown field", `{"stauts": 418}`, http.StatusBadRequest, `unknown field "stauts"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(h, http.MethodPost, "/api/error", tt.body)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("expected %d, got %d", tt.wantStatus, w.Code)
+			}
+
+			body := decodeError(t, w)
+			if body.Code != tt.wantStatus || body.Message != tt.wantMsg {
+				t.Errorf("expected a %d error body with %q, got %+v", tt.wantStatus, tt.wantMsg, body)
+			}
+		})
+	}
+}
+
+func TestErrorRejectsUnknownMsgpackFields(t *testing.T) {
+	h, _ := newTestHandler(t, &app.Configuration{})
+
+	tests := []struct {
+		name       string
+		payload    map[string]any
+		wantStatus int
+	}{
+		{"unknown field", map[string]any{"stauts": 418}, http.StatusBadRequest},
+		{"clean payload", map[string]any{"status": 418}, http.StatusTeapot},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := msgpack.Marshal(tt.payload)
+			if err != nil {
+				t.Fatalf("encoding payload: %v", err)
+			}
+
+			w := serve(h, http.MethodPost, "/api/error", string(body), "Content-Type", mimeMsgpack)
+			if w.Code != tt.wantStatus {
+				t.Errorf("expected %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+// decodeVersion decodes the body of a version endpoint response
+func decodeVersion(t *testing.T, w *httptest.ResponseRecorder) versionResponse {
+	t.Helper()
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var body versionResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decoding version body %q: %v", w.Body.String(), err)
+	}
+
+	return body
+}
+
+func TestVersionReportsUptime(t *testing.T) {
+	h, _ := newTestHandler(t, &app.Configuration{})
+
+	first := decodeVersion(t, serve(h, http.MethodGet, versionPath, ""))
+
+	time.Sleep(10 * time.Millisecond)
+
+	second := decodeVersion(t, serve(h, http.MethodGet, versionPath, ""))
+
+	if first.UptimeSeconds <= 0 {
+		t.Errorf("expected a positive uptime, got %v", first.UptimeSeconds)
+	}
+
+	if second.UptimeSeconds <= first.UptimeSeconds {
+		t.Errorf("expected the uptime to increase from %v, got %v", first.UptimeSeconds, second.UptimeSeconds)
+	}
+}
+
+func TestVersionReportsRuntimeSettings(t *testing.T) {
+	metrics.Reset()
+
+	prevProcs := runtime.GOMAXPROCS(0)
+	prevLimit := debug.SetMemoryLimit(-1)
+	t.Cleanup(func() {
+		runtime.GOMAXPROCS(prevProcs)
+		debug.SetMemoryLimit(prevLimit)
+	})
+
+	const (
+		procs = 3
+		limit = 512 << 20
+	)
+
+	cfg := &app.Configuration{GoMaxProcs: procs}
+	h, _ := newTestHandler(t, cfg)
+	app.ConfigureRuntime(cfg)
+	debug.SetMemoryLimit(limit)
+
+	body := decodeVersion(t, serve(h, http.MethodGet, versionPath, ""))
+
+	if body.GoMaxProcs != procs {
+		t.Errorf("expected gomaxprocs %d, got %d", procs, body.GoMaxProcs)
+	}
+
+	if body.MemoryLimitBytes != limit {
+		t.Errorf("expected memory_limit_bytes %d, got %d", limit, body.MemoryLimitBytes)
+	}
+
+	if got := metricValue(t, "skeleton_runtime_gomaxprocs"); got != procs {
+		t.Errorf("expected the gomaxprocs gauge to be %d, got %v", procs, got)
+	}
+
+	if got := metricValue(t, "skeleton_runtime_memory_limit_bytes"); got != limit {
+		t.Errorf("expected the memory limit gauge to be %d, got %v", limit, got)
+	}
+}
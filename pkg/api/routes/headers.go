@@ -0,0 +1,122 @@
+package routes
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// essentialHeaders are never trimmed from a response, whatever their size
+var essentialHeaders = []string{
+	"Age",
+	"Cache-Control",
+	"Content-Encoding",
+	"Content-Length",
+	"Content-Type",
+	"Location",
+	"Retry-After",
+	"Set-Cookie",
+	"Www-Authenticate",
+	requestIDHeader,
+}
+
+// headerGuardWriter trims the response headers down to a size limit just before they
+// are written out.
+type headerGuardWriter struct {
+	gin.ResponseWriter
+	limit   int
+	log     *zap.Logger
+	guarded bool
+}
+
+func (w *headerGuardWriter) guard() {
+	if w.guarded || w.Written() {
+		return
+	}
+	w.guarded = true
+
+	size := headerSize(w.Header())
+	if size <= w.limit {
+		return
+	}
+
+	dropped := trimHeaders(w.Header(), w.limit)
+	w.log.Warn("response headers exceed the size limit, trimmed non-essential headers",
+		zap.Int("size", size),
+		zap.Int("limit", w.limit),
+		zap.Strings("dropped", dropped),
+	)
+}
+
+func (w *headerGuardWriter) WriteHeaderNow() {
+	w.guard()
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *headerGuardWriter) Write(b []byte) (int, error) {
+	w.guard()
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *headerGuardWriter) WriteString(s string) (int, error) {
+	w.guard()
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *headerGuardWriter) Flush() {
+	w.guard()
+	w.ResponseWriter.Flush()
+}
+
+// composeHeaderGuard keeps response headers within limit bytes, as some clients
+// reject responses with overly large headers.
+func composeHeaderGuard(limit int, l *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Writer = &headerGuardWriter{
+			ResponseWriter: c.Writer,
+			limit:          limit,
+			log:            l,
+		}
+		c.Next()
+	}
+}
+
+// headerSize approximates the size of the headers on the wire
+func headerSize(h http.Header) int {
+	size := 0
+	for k, vs := range h {
+		for _, v := range vs {
+			size += len(k) + len(v) + len(": \r\n")
+		}
+	}
+	return size
+}
+
+// trimHeaders drops non-essential headers, largest first, until the headers fit
+// within limit bytes or only essential headers remain. It returns the names of the
+// dropped headers.
+func trimHeaders(h http.Header, limit int) []string {
+	var candidates []string
+	for k := range h {
+		if !slices.Contains(essentialHeaders, k) {
+			candidates = append(candidates, k)
+		}
+	}
+
+	slices.SortFunc(candidates, func(a, b string) int {
+		return headerSize(http.Header{b: h[b]}) - headerSize(http.Header{a: h[a]})
+	})
+
+	var dropped []string
+	for _, k := range candidates {
+		if headerSize(h) <= limit {
+			break
+		}
+		h.Del(k)
+		dropped = append(dropped, k)
+	}
+
+	return dropped
+}
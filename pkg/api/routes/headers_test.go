@@ -0,0 +1,40 @@
+package routes
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+func TestHeaderGuardTrimsNonEssentialHeaders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.Use(composeHeaderGuard(400, zap.NewNop()))
+	r.GET("/big", func(c *gin.Context) {
+		c.Header("X-Small", "kept")
+		c.Header("X-Large", strings.Repeat("x", 512))
+		c.Header("Location", strings.Repeat("y", 300))
+		c.String(http.StatusOK, "ok")
+	})
+
+	w := serve(r, http.MethodGet, "/big", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+
+	if w.Header().Get("X-Large") != "" {
+		t.Error("expected the largest non-essential header to be dropped")
+	}
+
+	if w.Header().Get("Location") == "" {
+		t.Error("expected the essential header to be kept, whatever its size")
+	}
+
+	if w.Header().Get("X-Small") != "kept" {
+		t.Error("expected headers within the limit once trimmed to be kept")
+	}
+}
@@ -0,0 +1,56 @@
+package routes
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/metal-toolbox/fleet-rest-skeleton/internal/app"
+)
+
+// healthResponse is the body of health endpoint responses. Plain responses only
+// carry the status.
+type healthResponse struct {
+	Status string            `json:"status"`
+	Time   *time.Time        `json:"time,omitempty"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// composeLivenessHandler reports the service is running
+func composeLivenessHandler(theApp *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		resp := healthResponse{Status: readinessOK}
+
+		if theApp.Cfg.HealthResponse == app.HealthResponseDetailed {
+			now := time.Now()
+			resp.Time = &now
+		}
+
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+// composeReadinessHandler reports whether the App's dependencies are usable. The
+// service is reported degraded, still responding 200, while the dependencies that
+// are down only prevent serving writes, and 503 otherwise.
+func composeReadinessHandler(theApp *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		results := theApp.CheckHealth(c.Request.Context())
+
+		status, code := readiness(theApp.Cfg, failingChecks(results))
+		resp := healthResponse{Status: status}
+
+		if theApp.Cfg.HealthResponse == app.HealthResponseDetailed {
+			resp.Checks = make(map[string]string, len(results))
+			for name, err := range results {
+				if err != nil {
+					resp.Checks[name] = err.Error()
+					continue
+				}
+				resp.Checks[name] = readinessOK
+			}
+		}
+
+		c.JSON(code, resp)
+	}
+}
@@ -0,0 +1,43 @@
+package routes
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/metal-toolbox/fleet-rest-skeleton/internal/app"
+)
+
+func TestHealthResponses(t *testing.T) {
+	tests := []struct {
+		name       string
+		response   string
+		wantTime   bool
+		wantChecks map[string]string
+	}{
+		{"plain", app.HealthResponsePlain, false, nil},
+		{"detailed", app.HealthResponseDetailed, true, map[string]string{"fleetdb": "down"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, _ := newTestHandler(t, &app.Configuration{HealthResponse: tt.response},
+				app.WithFleetDBClient(stubFleetDB{err: errDown}))
+
+			liveness := decodeHealth(t, serve(h, http.MethodGet, "/_health/liveness", ""))
+			if liveness.Status != readinessOK || (liveness.Time != nil) != tt.wantTime {
+				t.Errorf("unexpected liveness body %+v", liveness)
+			}
+
+			w := serve(h, http.MethodGet, app.ReadinessPath, "")
+			if w.Code != http.StatusServiceUnavailable {
+				t.Errorf("expected 503 while a dependency is down, got %d", w.Code)
+			}
+
+			readiness := decodeHealth(t, w)
+			if readiness.Status != readinessUnavailable || len(readiness.Checks) != len(tt.wantChecks) ||
+				readiness.Checks["fleetdb"] != tt.wantChecks["fleetdb"] {
+				t.Errorf("unexpected readiness body %+v", readiness)
+			}
+		})
+	}
+}
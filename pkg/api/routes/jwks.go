@@ -0,0 +1,141 @@
+package routes
+
+import (
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/metal-toolbox/fleet-rest-skeleton/internal/app"
+	"github.com/metal-toolbox/fleet-rest-skeleton/internal/metrics"
+	"go.hollow.sh/toolbox/ginauth"
+	"go.hollow.sh/toolbox/ginjwt"
+	"go.uber.org/zap"
+)
+
+const (
+	// minJWKSRetry is the first delay before retrying a failed JWKS refresh
+	minJWKSRetry = time.Second
+	// maxJWKSRetry caps the delay between retries when keys aren't refreshed periodically
+	maxJWKSRetry = time.Minute
+)
+
+var (
+	errJWKSTimeout     = errors.New("timed out fetching jwks")
+	errJWKSUnavailable = errors.New("token signing keys are unavailable, retry later")
+)
+
+// newJWTMiddleware builds the JWT middleware, fetching the JWKS of every issuer
+var newJWTMiddleware = ginjwt.NewMultiTokenMiddlewareFromConfigs
+
+// fetchJWKS builds the JWT middleware, which fetches the JWKS of every issuer, giving
+// up after timeout. A timeout of 0 waits for the fetch to complete.
+func fetchJWKS(configs []ginjwt.AuthConfig, timeout time.Duration) (*ginauth.MultiTokenMiddleware, error) {
+	type fetched struct {
+		mw  *ginauth.MultiTokenMiddleware
+		err error
+	}
+
+	start := time.Now()
+	done := make(chan fetched, 1)
+
+	go func() {
+		mw, err := newJWTMiddleware(configs...)
+		done <- fetched{mw, err}
+	}()
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	select {
+	case f := <-done:
+		result := metrics.JWKSFetchSuccess
+		if f.err != nil {
+			result = metrics.JWKSFetchFailure
+		}
+		metrics.JWKSFetch(result, start)
+
+		return f.mw, f.err
+	case <-expired:
+		// the fetch is left to complete in the background, its result discarded
+		metrics.JWKSFetch(metrics.JWKSFetchTimeout, start)
+		return nil, errJWKSTimeout
+	}
+}
+
+// jwksRefresher periodically rebuilds the JWT middleware, re-fetching the JWKS of
+// every issuer so that rotated signing keys are picked up without a restart.
+type jwksRefresher struct {
+	app      *app.App
+	configs  []ginjwt.AuthConfig
+	interval time.Duration
+	timeout  time.Duration
+}
+
+// run refreshes the keys every interval until the App is done, backing off with
+// jitter while fetches fail. When keys couldn't be fetched at startup they are
+// retried right away, and with an interval of 0 run returns once they are fetched.
+func (r *jwksRefresher) run() {
+	failures := 0
+
+	first := r.interval
+	if authMiddleWare.Load() == nil {
+		first = minJWKSRetry
+	}
+
+	timer := time.NewTimer(first)
+	defer timer.Stop()
+
+	for range timer.C {
+		if r.app.ContextDone() {
+			return
+		}
+
+		if err := r.refresh(); err != nil {
+			failures++
+			delay := r.backoff(failures)
+			r.app.Log.Warn("jwks refresh failed, keeping the current keys",
+				zap.Error(err),
+				zap.Int("failures", failures),
+				zap.Duration("retry_in", delay),
+			)
+			timer.Reset(delay)
+			continue
+		}
+
+		failures = 0
+		r.app.Verbose().Info("jwks refreshed")
+
+		if r.interval <= 0 {
+			return
+		}
+		timer.Reset(r.interval)
+	}
+}
+
+func (r *jwksRefresher) refresh() error {
+	mw, err := fetchJWKS(r.configs, r.timeout)
+	if err != nil {
+		return err
+	}
+
+	authMiddleWare.Store(mw)
+	return nil
+}
+
+// backoff returns an exponentially growing delay after consecutive failures, with
+// jitter so replicas don't hammer the IdP in lockstep. It never exceeds the refresh
+// interval, or maxJWKSRetry without one.
+func (r *jwksRefresher) backoff(failures int) time.Duration {
+	limit := r.interval
+	if limit <= 0 {
+		limit = maxJWKSRetry
+	}
+	d := min(minJWKSRetry<<min(failures-1, 16), limit)
+
+	//nolint:gosec // jitter doesn't need a secure source
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
@@ -0,0 +1,195 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/metal-toolbox/fleet-rest-skeleton/internal/app"
+	"go.hollow.sh/toolbox/ginauth"
+	"go.hollow.sh/toolbox/ginjwt"
+)
+
+// setSlowJWKS makes fetching the JWKS hang until the test is done
+func setSlowJWKS(t *testing.T) {
+	t.Helper()
+
+	release := make(chan struct{})
+	prev := newJWTMiddleware
+	t.Cleanup(func() {
+		close(release)
+		newJWTMiddleware = prev
+	})
+
+	newJWTMiddleware = func(...ginjwt.AuthConfig) (*ginauth.MultiTokenMiddleware, error) {
+		<-release
+		return nil, errJWKSUnavailable
+	}
+}
+
+func TestSlowJWKSFetchTimesOut(t *testing.T) {
+	setSlowJWKS(t)
+
+	const metric = "skeleton_auth_jwks_fetch_seconds"
+	before := metricValue(t, metric, "result", "timeout")
+
+	start := time.Now()
+	if _, err := fetchJWKS(nil, 50*time.Millisecond); err != errJWKSTimeout {
+		t.Fatalf("expected the fetch to time out, got %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the fetch to give up after the timeout, took %v", elapsed)
+	}
+
+	if got := metricValue(t, metric, "result", "timeout") - before; got != 1 {
+		t.Errorf("expected the timed out fetch's latency to be recorded, got %v observations", got)
+	}
+}
+
+func TestSlowJWKSFailsAuthWith503(t *testing.T) {
+	setSlowJWKS(t)
+	restoreAuthOnCleanup(t)
+	authMiddleWare.Store(nil)
+
+	h, _ := newTestHandler(t, &app.Configuration{
+		JWTAuth:          []ginjwt.AuthConfig{{Enabled: true, Issuer: "issuer", JWKSURI: "http://127.0.0.1:0/jwks"}},
+		JWKSFetchTimeout: 50 * time.Millisecond,
+	})
+
+	w := serve(h, http.MethodPost, "/api/echo", "{}", authorizationHeader, "Bearer token")
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 while the keys can't be fetched, got %d", w.Code)
+	}
+}
+
+// rotatingJWKS serves a JWKS whose signing keys can be rotated
+type rotatingJWKS struct {
+	mu   sync.Mutex
+	kids []string
+}
+
+func (j *rotatingJWKS) rotate(kids ...string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.kids = kids
+}
+
+func (j *rotatingJWKS) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	keys := make([]map[string]string, 0, len(j.kids))
+	for _, kid := range j.kids {
+		keys = append(keys, map[string]string{"kid": kid})
+	}
+
+	_ = json.NewEncoder(w).Encode(map[string]any{"keys": keys})
+}
+
+// keyVerifier accepts tokens "signed-by-<kid>" for the keys of a fetched JWKS
+type keyVerifier struct {
+	fakeVerifier
+}
+
+func (keyVerifier) SetMetadata(*gin.Context, ginauth.ClaimMetadata) {}
+
+// setFakeJWKSFetch builds the JWT middleware from the key IDs served at the JWKS URI
+// of every issuer, rather than verifying signatures against real keys
+func setFakeJWKSFetch(t *testing.T) {
+	t.Helper()
+
+	prev := newJWTMiddleware
+	t.Cleanup(func() { newJWTMiddleware = prev })
+
+	newJWTMiddleware = func(configs ...ginjwt.AuthConfig) (*ginauth.MultiTokenMiddleware, error) {
+		mw, err := ginauth.NewMultiTokenMiddleware()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, ac := range configs {
+			resp, err := http.Get(ac.JWKSURI)
+			if err != nil {
+				return nil, err
+			}
+
+			var jwks struct {
+				Keys []struct {
+					Kid string `json:"kid"`
+				} `json:"keys"`
+			}
+			err = json.NewDecoder(resp.Body).Decode(&jwks)
+			resp.Body.Close()
+
+			if err != nil {
+				return nil, err
+			}
+
+			v := keyVerifier{fakeVerifier{}}
+			for _, k := range jwks.Keys {
+				v.fakeVerifier["signed-by-"+k.Kid] = ginauth.ClaimMetadata{Subject: "svc", Roles: []string{"read"}}
+			}
+
+			if err := mw.Add(v); err != nil {
+				return nil, err
+			}
+		}
+
+		return mw, nil
+	}
+}
+
+func TestJWKSRefresherPicksUpRotatedKeys(t *testing.T) {
+	setFakeJWKSFetch(t)
+	restoreAuthOnCleanup(t)
+
+	jwks := &rotatingJWKS{kids: []string{"key-1"}}
+	srv := httptest.NewServer(jwks)
+	defer srv.Close()
+
+	configs := []ginjwt.AuthConfig{{Enabled: true, Issuer: "issuer", JWKSURI: srv.URL}}
+
+	mw, err := fetchJWKS(configs, time.Second)
+	if err != nil {
+		t.Fatalf("fetching jwks: %v", err)
+	}
+
+	jwtAuthConfigured = true
+	authMiddleWare.Store(mw)
+
+	r := newAuthTestRouter()
+	status := func(token string) int {
+		return serve(r, http.MethodGet, "/protected", "", authorizationHeader, "Bearer "+token).Code
+	}
+
+	if got := status("signed-by-key-1"); got != http.StatusOK {
+		t.Fatalf("expected a token signed by the current key to be accepted, got %d", got)
+	}
+
+	if got := status("signed-by-key-2"); got != http.StatusUnauthorized {
+		t.Fatalf("expected a token signed by an unknown key to be rejected, got %d", got)
+	}
+
+	theApp, _ := newTestApp(t, &app.Configuration{QuietStartup: true})
+	refresher := &jwksRefresher{app: theApp, configs: configs, interval: 10 * time.Millisecond, timeout: time.Second}
+	go refresher.run()
+
+	jwks.rotate("key-2")
+
+	deadline := time.Now().Add(5 * time.Second)
+	for status("signed-by-key-2") != http.StatusOK {
+		if time.Now().After(deadline) {
+			t.Fatal("expected a token signed by the rotated key to be accepted after a refresh")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if got := status("signed-by-key-1"); got != http.StatusUnauthorized {
+		t.Errorf("expected a token signed by the retired key to be rejected, got %d", got)
+	}
+}
@@ -0,0 +1,47 @@
+package routes
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// loggerKey is the gin context key holding the request logger
+const loggerKey = "logger"
+
+// loggerContextKey is the request context key holding the request logger
+type loggerContextKey struct{}
+
+// setRequestLogger attaches a child of l carrying the request ID, trace ID and path to
+// both the gin context and the request context
+func setRequestLogger(c *gin.Context, l *zap.Logger) {
+	rl := l.With(
+		zap.String("request_id", RequestID(c)),
+		traceIDField(c),
+		zap.String("path", c.Request.URL.Path),
+	)
+
+	c.Set(loggerKey, rl)
+	c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), loggerContextKey{}, rl))
+}
+
+// Logger returns the logger of the request being handled, carrying its request ID so
+// that entries logged mid-request can be correlated.
+func Logger(c *gin.Context) *zap.Logger {
+	if l, ok := c.Value(loggerKey).(*zap.Logger); ok {
+		return l
+	}
+
+	return LoggerFromContext(c.Request.Context())
+}
+
+// LoggerFromContext returns the request logger held by a request context, for code
+// only handed the context. The global zap logger is returned when there is none.
+func LoggerFromContext(ctx context.Context) *zap.Logger {
+	if l, ok := ctx.Value(loggerContextKey{}).(*zap.Logger); ok {
+		return l
+	}
+
+	return zap.L()
+}
@@ -0,0 +1,40 @@
+package routes
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestRequestLoggerCarriesTheRequestID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	core, logs := observer.New(zapcore.InfoLevel)
+	l := zap.New(core)
+
+	r := gin.New()
+	r.Use(composeRequestID(l), func(c *gin.Context) { setRequestLogger(c, l) })
+	r.GET("/logged", func(c *gin.Context) {
+		Logger(c).Info("from the gin context")
+		LoggerFromContext(c.Request.Context()).Info("from the request context")
+		c.Status(http.StatusOK)
+	})
+
+	serve(r, http.MethodGet, "/logged", "", requestIDHeader, "req-1")
+
+	entries := logs.All()
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %v", entries)
+	}
+
+	for _, e := range entries {
+		fields := e.ContextMap()
+		if fields["request_id"] != "req-1" || fields["path"] != "/logged" {
+			t.Errorf("%q: expected the request ID and path, got %v", e.Message, fields)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// logLevelPayload is the body of log level requests and responses, as with zap's
+// AtomicLevel HTTP handler
+type logLevelPayload struct {
+	Level string `json:"level" binding:"required"`
+}
+
+var errLogLevelFixed = errors.New("the log level can't be changed, the logger isn't bound to an adjustable level")
+
+// composeGetLogLevel responds with the current log level
+func composeGetLogLevel(lvl zap.AtomicLevel) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, logLevelPayload{Level: lvl.String()})
+	}
+}
+
+// composeSetLogLevel changes the log level of the running service, responding with
+// the new level. Changes are rejected with a 409 unless the level is adjustable,
+// rather than reporting a change that has no effect.
+func composeSetLogLevel(lvl zap.AtomicLevel, adjustable bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !adjustable {
+			respondError(c, http.StatusConflict, errLogLevelFixed)
+			return
+		}
+
+		var p logLevelPayload
+		if err := c.ShouldBindJSON(&p); err != nil {
+			respondBindError(c, err)
+			return
+		}
+
+		l, err := zapcore.ParseLevel(p.Level)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, err)
+			return
+		}
+
+		if l != lvl.Level() {
+			Logger(c).Info("changing log level",
+				zap.Stringer("from", lvl.Level()),
+				zap.Stringer("to", l),
+			)
+			lvl.SetLevel(l)
+		}
+
+		c.JSON(http.StatusOK, logLevelPayload{Level: lvl.String()})
+	}
+}
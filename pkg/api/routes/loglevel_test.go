@@ -0,0 +1,98 @@
+package routes
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/metal-toolbox/fleet-rest-skeleton/internal/app"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestLogLevelEndpoints(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	lvl := zap.NewAtomicLevelAt(zapcore.InfoLevel)
+
+	r := gin.New()
+	r.GET("/level", composeGetLogLevel(lvl))
+	r.PUT("/level", composeSetLogLevel(lvl, true))
+
+	if w := serve(r, http.MethodGet, "/level", ""); w.Body.String() != `{"level":"info"}` {
+		t.Errorf("expected the current level, got %s", w.Body.String())
+	}
+
+	w := serve(r, http.MethodPut, "/level", `{"level": "debug"}`)
+	if w.Code != http.StatusOK || w.Body.String() != `{"level":"debug"}` {
+		t.Errorf("expected the new level, got %d %s", w.Code, w.Body.String())
+	}
+
+	if lvl.Level() != zapcore.DebugLevel {
+		t.Errorf("expected the level to change, got %v", lvl.Level())
+	}
+
+	if w := serve(r, http.MethodPut, "/level", `{"level": "loud"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected an unknown level to be rejected, got %d", w.Code)
+	}
+
+	if lvl.Level() != zapcore.DebugLevel {
+		t.Errorf("expected a rejected level to leave the level alone, got %v", lvl.Level())
+	}
+}
+
+func TestLogLevelEndpointsChangeTheAppsLevel(t *testing.T) {
+	lvl := zap.NewAtomicLevelAt(zapcore.InfoLevel)
+	core, logs := observer.New(lvl)
+
+	theApp, _ := newTestApp(t, &app.Configuration{LogLevel: "info"}, app.WithLogLevel(lvl))
+	theApp.Log = zap.New(core)
+
+	other, _ := newTestApp(t, &app.Configuration{LogLevel: "info"})
+	otherLevel, _ := other.LogLevel()
+
+	h := ComposeHTTPServer(theApp).Handler
+
+	theApp.Log.Debug("before")
+
+	w := serve(h, http.MethodPut, "/api/log/level", `{"level": "debug"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	theApp.Log.Debug("after")
+
+	if logs.FilterMessage("before").Len() != 0 {
+		t.Error("expected debug entries to be dropped at the info level")
+	}
+
+	if logs.FilterMessage("after").Len() != 1 {
+		t.Error("expected debug entries to be emitted once the level changed")
+	}
+
+	if got := otherLevel.Level(); got != zapcore.InfoLevel {
+		t.Errorf("expected other Apps' levels to be unchanged, got %s", got)
+	}
+}
+
+func TestLogLevelOfAnUnboundLoggerCantBeChanged(t *testing.T) {
+	cfg := &app.Configuration{}
+	theApp, _ := newTestApp(t, cfg)
+	unbound := app.NewApp(theApp.Context(), cfg, zap.NewNop())
+
+	h := ComposeHTTPServer(unbound).Handler
+
+	if w := serve(h, http.MethodGet, "/api/log/level", ""); w.Code != http.StatusOK {
+		t.Errorf("expected the level to be reported, got %d", w.Code)
+	}
+
+	w := serve(h, http.MethodPut, "/api/log/level", `{"level": "debug"}`)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d", w.Code)
+	}
+
+	if msg := decodeError(t, w).Message; msg != errLogLevelFixed.Error() {
+		t.Errorf("expected %q, got %q", errLogLevelFixed, msg)
+	}
+}
@@ -0,0 +1,60 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// mimeMsgpack is the media type of msgpack payloads, offered to clients that would
+// rather not pay for JSON serialization
+const mimeMsgpack = "application/msgpack"
+
+// bindPayload decodes the request body into obj, as msgpack when the request's
+// Content-Type says so and as JSON otherwise
+func bindPayload(c *gin.Context, obj any) error {
+	if c.ContentType() == mimeMsgpack {
+		return msgpack.NewDecoder(c.Request.Body).Decode(obj)
+	}
+
+	return c.ShouldBindJSON(obj)
+}
+
+// bindStrictPayload is bindPayload rejecting bodies with fields obj has no place for
+func bindStrictPayload(c *gin.Context, obj any) error {
+	if c.ContentType() == mimeMsgpack {
+		dec := msgpack.NewDecoder(c.Request.Body)
+		dec.DisallowUnknownFields(true)
+		return dec.Decode(obj)
+	}
+
+	dec := json.NewDecoder(c.Request.Body)
+	dec.DisallowUnknownFields()
+	return dec.Decode(obj)
+}
+
+// negotiatedFormat returns the payload format respondPayload writes for the
+// client's Accept header
+func negotiatedFormat(c *gin.Context) string {
+	return c.NegotiateFormat(binding.MIMEJSON, mimeMsgpack)
+}
+
+// respondPayload writes obj with the status, as msgpack when the client's Accept
+// header prefers it and as JSON otherwise. Error responses are always JSON.
+func respondPayload(c *gin.Context, status int, obj any) {
+	if negotiatedFormat(c) != mimeMsgpack {
+		c.JSON(status, obj)
+		return
+	}
+
+	body, err := msgpack.Marshal(obj)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.Data(status, mimeMsgpack, body)
+}
@@ -0,0 +1,64 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/metal-toolbox/fleet-rest-skeleton/internal/app"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestEchoRoundTripsFormats(t *testing.T) {
+	h, _ := newTestHandler(t, &app.Configuration{})
+
+	payload := map[string]any{"name": "a", "tags": []any{"x", "y"}, "nested": map[string]any{"ok": true}}
+
+	tests := []struct {
+		name      string
+		mime      string
+		marshal   func(any) ([]byte, error)
+		unmarshal func([]byte, any) error
+	}{
+		{"json", "application/json", json.Marshal, json.Unmarshal},
+		{"msgpack", mimeMsgpack, msgpack.Marshal, msgpack.Unmarshal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := tt.marshal(payload)
+			if err != nil {
+				t.Fatalf("encoding payload: %v", err)
+			}
+
+			w := serve(h, http.MethodPost, "/api/echo", string(body), "Content-Type", tt.mime, "Accept", tt.mime)
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+			}
+
+			if got := w.Header().Get("Content-Type"); !strings.HasPrefix(got, tt.mime) {
+				t.Errorf("expected content type %q, got %q", tt.mime, got)
+			}
+
+			var echoed map[string]any
+			if err := tt.unmarshal(w.Body.Bytes(), &echoed); err != nil {
+				t.Fatalf("decoding response: %v", err)
+			}
+
+			if !reflect.DeepEqual(echoed, payload) {
+				t.Errorf("expected %v echoed, got %v", payload, echoed)
+			}
+		})
+	}
+}
+
+func TestMalformedMsgpackIsRejected(t *testing.T) {
+	h, _ := newTestHandler(t, &app.Configuration{})
+
+	w := serve(h, http.MethodPost, "/api/echo", "\xc1", "Content-Type", mimeMsgpack)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", w.Code)
+	}
+}
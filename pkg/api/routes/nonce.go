@@ -0,0 +1,110 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	nonceHeader     = "X-Nonce"
+	timestampHeader = "X-Timestamp"
+
+	// NonceStoreOption is the App option key under which a NonceStore may be provided,
+	// replacing the in-memory store
+	NonceStoreOption = "nonce_store"
+)
+
+var (
+	errReplayedNonce    = errors.New("request nonce has already been used")
+	errMissingTimestamp = errors.New("a nonce requires a valid " + timestampHeader + " header")
+	errStaleTimestamp   = errors.New("request timestamp is outside the accepted window")
+)
+
+// NonceStore keeps track of request nonces in use
+type NonceStore interface {
+	// Use records the nonce as used for ttl, returning false when it is already in use
+	Use(ctx context.Context, nonce string, ttl time.Duration) (bool, error)
+}
+
+// memoryNonceStore is a NonceStore local to this process
+type memoryNonceStore struct {
+	mu        sync.Mutex
+	nonces    map[string]time.Time
+	nextSweep time.Time
+}
+
+// NewMemoryNonceStore returns a NonceStore holding nonces in memory. Replicas each have
+// their own store, so a shared store is needed to reject nonces replayed to another
+// replica.
+func NewMemoryNonceStore() NonceStore {
+	return &memoryNonceStore{nonces: make(map[string]time.Time)}
+}
+
+func (s *memoryNonceStore) Use(_ context.Context, nonce string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// expired nonces are swept at most once per ttl
+	if now.After(s.nextSweep) {
+		for n, expiry := range s.nonces {
+			if now.After(expiry) {
+				delete(s.nonces, n)
+			}
+		}
+		s.nextSweep = now.Add(ttl)
+	}
+
+	if expiry, ok := s.nonces[nonce]; ok && now.Before(expiry) {
+		return false, nil
+	}
+
+	s.nonces[nonce] = now.Add(ttl)
+
+	return true, nil
+}
+
+// composeReplayProtection rejects requests reusing an X-Nonce within window with a 409.
+// Requests with a nonce must carry an X-Timestamp (unix seconds) within window of the
+// current time, so that nonces only need to be kept for the window.
+func composeReplayProtection(store NonceStore, window time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		nonce := c.GetHeader(nonceHeader)
+		if nonce == "" {
+			c.Next()
+			return
+		}
+
+		ts, err := strconv.ParseInt(c.GetHeader(timestampHeader), 10, 64)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, errMissingTimestamp)
+			return
+		}
+
+		if skew := time.Since(time.Unix(ts, 0)); skew > window || skew < -window {
+			respondError(c, http.StatusBadRequest, errStaleTimestamp)
+			return
+		}
+
+		// a nonce must outlive any timestamp that is still accepted
+		fresh, err := store.Use(c.Request.Context(), nonce, 2*window)
+		if err != nil {
+			respondError(c, http.StatusServiceUnavailable, err)
+			return
+		}
+
+		if !fresh {
+			respondError(c, http.StatusConflict, errReplayedNonce)
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,37 @@
+package routes
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/metal-toolbox/fleet-rest-skeleton/internal/app"
+)
+
+func TestReplayProtection(t *testing.T) {
+	h, _ := newTestHandler(t, &app.Configuration{ReplayWindow: time.Minute})
+
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	stale := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+
+	tests := []struct {
+		name    string
+		headers []string
+		want    int
+	}{
+		{"no nonce", nil, http.StatusOK},
+		{"fresh nonce", []string{nonceHeader, "n1", timestampHeader, now}, http.StatusOK},
+		{"replayed nonce", []string{nonceHeader, "n1", timestampHeader, now}, http.StatusConflict},
+		{"other nonce", []string{nonceHeader, "n2", timestampHeader, now}, http.StatusOK},
+		{"missing timestamp", []string{nonceHeader, "n3"}, http.StatusBadRequest},
+		{"stale timestamp", []string{nonceHeader, "n4", timestampHeader, stale}, http.StatusBadRequest},
+	}
+
+	// run in order, as later cases depend on the nonces used by earlier ones
+	for _, tt := range tests {
+		if w := serve(h, http.MethodPost, "/api/echo", "{}", tt.headers...); w.Code != tt.want {
+			t.Errorf("%s: expected %d, got %d", tt.name, tt.want, w.Code)
+		}
+	}
+}
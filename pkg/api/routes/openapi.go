@@ -0,0 +1,123 @@
+package routes
+
+import (
+	_ "embed"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/metal-toolbox/fleet-rest-skeleton/internal/version"
+	"gopkg.in/yaml.v3"
+)
+
+const openAPIPath = "/api/openapi.yaml"
+
+// openAPICacheTTL is how long clients and the response cache may reuse the document
+const openAPICacheTTL = 5 * time.Minute
+
+// openAPIBase documents routes in detail, routes it omits are added with the details
+// known to the route registry
+//
+//go:embed openapi.yaml
+var openAPIBase []byte
+
+// registerOpenAPI serves the OpenAPI document describing every route registered so
+// far, so it must be called once all other routes are registered.
+func registerOpenAPI(r *routeRegistry) error {
+	var spec []byte
+
+	if !r.disabled[openAPIPath] {
+		r.handleCached(openAPIPath, "openapi", nil, openAPICacheTTL, func(c *gin.Context) {
+			c.Data(http.StatusOK, "application/yaml", spec)
+		})
+	}
+
+	var err error
+	spec, err = r.openAPISpec()
+
+	return err
+}
+
+// openAPISpec merges the registered routes into the base OpenAPI document, recording
+// the method, path and scopes of each.
+func (r *routeRegistry) openAPISpec() ([]byte, error) {
+	spec := map[string]any{}
+	if err := yaml.Unmarshal(openAPIBase, &spec); err != nil {
+		return nil, err
+	}
+
+	if info, ok := spec["info"].(map[string]any); ok && version.Current().AppVersion != "" {
+		info["version"] = version.Current().AppVersion
+	}
+
+	if basePath != "" {
+		spec["servers"] = []any{map[string]any{"url": basePath}}
+	}
+
+	paths, ok := spec["paths"].(map[string]any)
+	if !ok {
+		paths = map[string]any{}
+		spec["paths"] = paths
+	}
+
+	for _, ri := range r.info {
+		path, params := openAPIPathTemplate(ri.Path)
+
+		item, ok := paths[path].(map[string]any)
+		if !ok {
+			item = map[string]any{}
+			paths[path] = item
+		}
+
+		method := strings.ToLower(ri.Method)
+
+		op, ok := item[method].(map[string]any)
+		if !ok {
+			op = map[string]any{
+				"summary": ri.Handler,
+				"responses": map[string]any{
+					"default": map[string]any{"$ref": "#/components/responses/Error"},
+				},
+			}
+			if len(params) > 0 {
+				op["parameters"] = params
+			}
+			item[method] = op
+		}
+
+		if ri.protected() {
+			op["security"] = []any{
+				map[string]any{"bearerAuth": []string{}},
+				map[string]any{"apiKey": []string{}},
+			}
+			// bearer and API key schemes carry no scopes, callers need any one of these
+			op["x-scopes"] = ri.Scopes
+		}
+	}
+
+	return yaml.Marshal(spec)
+}
+
+// openAPIPathTemplate converts gin path parameters (/servers/:id, /files/*path) to
+// OpenAPI templates (/servers/{id}, /files/{path}), returning the template along with
+// the parameter declarations it requires.
+func openAPIPathTemplate(path string) (string, []any) {
+	var params []any
+
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		if strings.HasPrefix(s, ":") || strings.HasPrefix(s, "*") {
+			name := s[1:]
+			segments[i] = "{" + name + "}"
+			params = append(params, map[string]any{
+				"name":     name,
+				"in":       "path",
+				"required": true,
+				"schema":   map[string]any{"type": "string"},
+			})
+		}
+	}
+
+	return strings.Join(segments, "/"), params
+}
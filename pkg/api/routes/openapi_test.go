@@ -0,0 +1,63 @@
+package routes
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/metal-toolbox/fleet-rest-skeleton/internal/app"
+	"gopkg.in/yaml.v3"
+)
+
+func TestOpenAPIDocumentsRegisteredRoutes(t *testing.T) {
+	h, _ := newTestHandler(t, &app.Configuration{})
+
+	w := serve(h, http.MethodGet, openAPIPath, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+
+	var spec struct {
+		Paths map[string]map[string]struct {
+			Summary string   `yaml:"summary"`
+			Scopes  []string `yaml:"x-scopes"`
+		} `yaml:"paths"`
+	}
+	if err := yaml.Unmarshal(w.Body.Bytes(), &spec); err != nil {
+		t.Fatalf("decoding spec: %v", err)
+	}
+
+	for _, route := range []struct{ path, method string }{
+		{"/api/version", "get"},
+		{"/api/echo", "post"},
+		{"/api/echo/raw", "post"},
+		{"/api/whoami", "get"},
+		{"/api/echo/validated", "post"},
+		{"/api/echo/versioned", "post"},
+		{app.ReadinessPath, "get"},
+	} {
+		if _, ok := spec.Paths[route.path][route.method]; !ok {
+			t.Errorf("expected %s %s to be documented", route.method, route.path)
+		}
+	}
+
+	if got, want := spec.Paths["/api/echo/raw"]["post"].Scopes, createScopes("response"); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected the raw echo's scopes %v, got %v", want, got)
+	}
+
+	if _, ok := spec.Paths[pprofPrefix+"heap"]; ok {
+		t.Error("expected routes that aren't registered not to be documented")
+	}
+}
+
+func TestOpenAPIPathTemplate(t *testing.T) {
+	path, params := openAPIPathTemplate("/api/servers/:id/files/*path")
+
+	if path != "/api/servers/{id}/files/{path}" {
+		t.Errorf("expected gin parameters converted to templates, got %s", path)
+	}
+
+	if len(params) != 2 {
+		t.Errorf("expected a declaration for each parameter, got %v", params)
+	}
+}
@@ -0,0 +1,19 @@
+package routes
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/metal-toolbox/fleet-rest-skeleton/internal/metrics"
+)
+
+// TimeOp runs fn as a named sub-operation of the request being handled, recording
+// its duration in the handler_operation_seconds histogram. The observation carries
+// the request ID as an exemplar to correlate it with the request.
+func TimeOp(c *gin.Context, name string, fn func() error) error {
+	start := time.Now()
+	err := fn()
+	metrics.HandlerOperation(name, RequestID(c), start)
+
+	return err
+}
@@ -0,0 +1,79 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/metal-toolbox/fleet-rest-skeleton/internal/metrics"
+	"go.uber.org/zap"
+)
+
+func TestTimeOp(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	metrics.Reset()
+
+	const (
+		metric = "skeleton_handler_operation_seconds"
+		sleep  = 5 * time.Millisecond
+	)
+
+	errLookup := errors.New("lookup failed")
+
+	var lookupErr, storeErr error
+
+	r := gin.New()
+	r.Use(composeRequestID(zap.NewNop()))
+	r.GET("/ops", func(c *gin.Context) {
+		lookupErr = TimeOp(c, "lookup", func() error {
+			time.Sleep(sleep)
+			return errLookup
+		})
+		storeErr = TimeOp(c, "store", func() error { return nil })
+		c.Status(http.StatusNoContent)
+	})
+
+	serve(r, http.MethodGet, "/ops", "", requestIDHeader, "req-1")
+
+	if !errors.Is(lookupErr, errLookup) || storeErr != nil {
+		t.Errorf("expected the errors of the operations to be returned, got %v and %v", lookupErr, storeErr)
+	}
+
+	for _, name := range []string{"lookup", "store"} {
+		if got := metricValue(t, metric, "name", name); got != 1 {
+			t.Errorf("%s: expected 1 observation, got %v", name, got)
+		}
+	}
+
+	if got := histogramSum(t, metric, "name", "lookup"); got < sleep.Seconds() {
+		t.Errorf("expected the lookup to take at least %v, got %vs", sleep, got)
+	}
+
+	families, err := metrics.Registry().Gather()
+	if err != nil {
+		t.Fatalf("gathering metrics: %v", err)
+	}
+
+	var exemplars []string
+	for _, f := range families {
+		if f.GetName() != metric {
+			continue
+		}
+
+		for _, m := range f.GetMetric() {
+			for _, b := range m.GetHistogram().GetBucket() {
+				for _, l := range b.GetExemplar().GetLabel() {
+					if l.GetName() == "request_id" {
+						exemplars = append(exemplars, l.GetValue())
+					}
+				}
+			}
+		}
+	}
+
+	if len(exemplars) != 2 || exemplars[0] != "req-1" || exemplars[1] != "req-1" {
+		t.Errorf("expected both observations to carry the request ID as an exemplar, got %v", exemplars)
+	}
+}
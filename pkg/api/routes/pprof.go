@@ -0,0 +1,31 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/pprof" //nolint:gosec // the default mux it registers on is never served
+
+	"github.com/gin-gonic/gin"
+)
+
+const pprofPrefix = "/debug/pprof/"
+
+// pprofProfiles are the runtime profiles served by name
+var pprofProfiles = []string{"allocs", "block", "goroutine", "heap", "mutex", "threadcreate"}
+
+// registerPprof serves the net/http/pprof endpoints under /debug/pprof/, requiring the
+// debug read scope when auth is configured. CPU profiles and traces are cut short by
+// the write and request timeouts, so keep their seconds parameter below those.
+func registerPprof(r *routeRegistry) {
+	scopes := readScopes("debug")
+
+	r.handle(http.MethodGet, pprofPrefix, "pprof", scopes, gin.WrapF(pprof.Index))
+	r.handle(http.MethodGet, pprofPrefix+"cmdline", "pprof", scopes, gin.WrapF(pprof.Cmdline))
+	r.handle(http.MethodGet, pprofPrefix+"profile", "pprof", scopes, gin.WrapF(pprof.Profile))
+	r.handle(http.MethodGet, pprofPrefix+"symbol", "pprof", scopes, gin.WrapF(pprof.Symbol))
+	r.handle(http.MethodPost, pprofPrefix+"symbol", "pprof", scopes, gin.WrapF(pprof.Symbol))
+	r.handle(http.MethodGet, pprofPrefix+"trace", "pprof", scopes, gin.WrapF(pprof.Trace))
+
+	for _, name := range pprofProfiles {
+		r.handle(http.MethodGet, pprofPrefix+name, "pprof", scopes, gin.WrapH(pprof.Handler(name)))
+	}
+}
@@ -0,0 +1,49 @@
+package routes
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/metal-toolbox/fleet-rest-skeleton/internal/app"
+)
+
+func TestPprofEndpoints(t *testing.T) {
+	tests := []struct {
+		name    string
+		enabled bool
+		want    int
+	}{
+		{"disabled", false, http.StatusNotFound},
+		{"enabled", true, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, _ := newTestHandler(t, &app.Configuration{EnablePprof: tt.enabled})
+
+			if w := serve(h, http.MethodGet, pprofPrefix+"goroutine?debug=1", ""); w.Code != tt.want {
+				t.Errorf("expected %d, got %d", tt.want, w.Code)
+			}
+		})
+	}
+}
+
+func TestPprofEndpointsRequireTheDebugScope(t *testing.T) {
+	restoreAuthOnCleanup(t)
+
+	h, _ := newTestHandler(t, &app.Configuration{
+		EnablePprof: true,
+		APIKeys: []app.APIKey{
+			{Name: "debugger", Key: "debug-key", Scopes: []string{"read:debug"}},
+			{Name: "other", Key: "other-key", Scopes: []string{"read:time"}},
+		},
+	})
+
+	if w := serve(h, http.MethodGet, pprofPrefix+"cmdline", "", apiKeyHeader, "debug-key"); w.Code != http.StatusOK {
+		t.Errorf("expected the debug scope to be let through, got %d", w.Code)
+	}
+
+	if w := serve(h, http.MethodGet, pprofPrefix+"cmdline", "", apiKeyHeader, "other-key"); w.Code != http.StatusForbidden {
+		t.Errorf("expected other scopes to be forbidden, got %d", w.Code)
+	}
+}
@@ -0,0 +1,73 @@
+package routes
+
+import (
+	"context"
+
+	"github.com/metal-toolbox/fleet-rest-skeleton/internal/metrics"
+	"go.hollow.sh/toolbox/events"
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+)
+
+// Publication is a change stored by a handler and announced on the event stream, so
+// that consumers only hear of changes that were stored. Store and Confirm may be nil.
+type Publication struct {
+	Subject string
+	Msg     []byte
+	// Store persists the change ahead of announcing it, Unstore removes it again
+	Store   func(context.Context) error
+	Unstore func(context.Context) error
+	// Confirm records the change was announced, e.g. marking it queued, once published.
+	// Retract withdraws the announcement when Confirm fails.
+	Confirm func(context.Context) error
+	Retract func(context.Context) error
+}
+
+// PublishWithRollback stores the change, publishes it and confirms it was published,
+// rolling back the steps taken so far when one fails: the store when publishing
+// fails, and the announcement and the store when confirming fails. Each rollback is
+// counted in the rollback_total metric by what triggered it and whether it succeeded,
+// and failed rollbacks are logged, as they leave state behind to clean up by hand.
+// The error of the failed step is returned, along with that of the rollback.
+func PublishWithRollback(ctx context.Context, stream events.Stream, p Publication) error {
+	if p.Store != nil {
+		if err := p.Store(ctx); err != nil {
+			return err
+		}
+	}
+
+	if err := stream.Publish(ctx, p.Subject, p.Msg); err != nil {
+		return multierr.Append(err, rollback(ctx, metrics.RollbackPublishFailure, p.Subject, p.Unstore))
+	}
+
+	if p.Confirm != nil {
+		if err := p.Confirm(ctx); err != nil {
+			return multierr.Append(err, rollback(ctx, metrics.RollbackStoreFailure, p.Subject, p.Retract, p.Unstore))
+		}
+	}
+
+	return nil
+}
+
+// rollback runs the undo steps given, skipping those that are nil, and records the
+// rollback for the reason it was triggered
+func rollback(ctx context.Context, reason, subject string, undo ...func(context.Context) error) error {
+	var err error
+	for _, fn := range undo {
+		if fn != nil {
+			err = multierr.Append(err, fn(ctx))
+		}
+	}
+
+	metrics.Rollback(reason, err)
+
+	if err != nil {
+		LoggerFromContext(ctx).Error("rollback failed",
+			zap.String("reason", reason),
+			zap.String("subject", subject),
+			zap.Error(err),
+		)
+	}
+
+	return err
+}
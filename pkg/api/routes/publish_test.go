@@ -0,0 +1,146 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/metal-toolbox/fleet-rest-skeleton/internal/metrics"
+	"go.hollow.sh/toolbox/events"
+)
+
+const rollbackMetric = "skeleton_rollback_total"
+
+var (
+	errPublish = errors.New("publish failed")
+	errStep    = errors.New("step failed")
+)
+
+// recordingStream records the subjects published to, failing with err when set
+type recordingStream struct {
+	events.Stream
+	err       error
+	published []string
+}
+
+func (s *recordingStream) Publish(_ context.Context, subject string, _ []byte) error {
+	if s.err != nil {
+		return s.err
+	}
+
+	s.published = append(s.published, subject)
+	return nil
+}
+
+func TestPublishWithRollback(t *testing.T) {
+	tests := []struct {
+		name          string
+		storeErr      error
+		publishErr    error
+		confirmErr    error
+		undoErr       error
+		wantErr       error
+		wantPublished bool
+		wantSteps     []string
+		wantReason    string
+		wantResult    string
+	}{
+		{
+			name:          "published",
+			wantPublished: true,
+			wantSteps:     []string{"store", "confirm"},
+		},
+		{
+			name:      "store failure",
+			storeErr:  errStep,
+			wantErr:   errStep,
+			wantSteps: []string{"store"},
+		},
+		{
+			name:       "publish failure rolled back",
+			publishErr: errPublish,
+			wantErr:    errPublish,
+			wantSteps:  []string{"store", "unstore"},
+			wantReason: metrics.RollbackPublishFailure,
+			wantResult: "success",
+		},
+		{
+			name:       "publish failure failing to roll back",
+			publishErr: errPublish,
+			undoErr:    errDown,
+			wantErr:    errDown,
+			wantSteps:  []string{"store", "unstore"},
+			wantReason: metrics.RollbackPublishFailure,
+			wantResult: "failure",
+		},
+		{
+			name:          "confirm failure rolled back",
+			confirmErr:    errStep,
+			wantErr:       errStep,
+			wantPublished: true,
+			wantSteps:     []string{"store", "confirm", "retract", "unstore"},
+			wantReason:    metrics.RollbackStoreFailure,
+			wantResult:    "success",
+		},
+		{
+			name:          "confirm failure failing to roll back",
+			confirmErr:    errStep,
+			undoErr:       errDown,
+			wantErr:       errDown,
+			wantPublished: true,
+			wantSteps:     []string{"store", "confirm", "retract", "unstore"},
+			wantReason:    metrics.RollbackStoreFailure,
+			wantResult:    "failure",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metrics.Reset()
+
+			var steps []string
+			step := func(name string, err error) func(context.Context) error {
+				return func(context.Context) error {
+					steps = append(steps, name)
+					return err
+				}
+			}
+
+			stream := &recordingStream{err: tt.publishErr}
+			err := PublishWithRollback(context.Background(), stream, Publication{
+				Subject: "servers.created",
+				Msg:     []byte(`{}`),
+				Store:   step("store", tt.storeErr),
+				Unstore: step("unstore", tt.undoErr),
+				Confirm: step("confirm", tt.confirmErr),
+				Retract: step("retract", tt.undoErr),
+			})
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("expected error %v, got %v", tt.wantErr, err)
+			}
+
+			if published := len(stream.published) == 1; published != tt.wantPublished {
+				t.Errorf("expected published %v, got %v", tt.wantPublished, stream.published)
+			}
+
+			if !slices.Equal(steps, tt.wantSteps) {
+				t.Errorf("expected steps %v, got %v", tt.wantSteps, steps)
+			}
+
+			for _, reason := range []string{metrics.RollbackPublishFailure, metrics.RollbackStoreFailure} {
+				for _, result := range []string{"success", "failure"} {
+					want := 0.0
+					if reason == tt.wantReason && result == tt.wantResult {
+						want = 1
+					}
+
+					if got := metricValue(t, rollbackMetric, "reason", reason, "result", result); got != want {
+						t.Errorf("expected %v rollbacks for %s with result %s, got %v", want, reason, result, got)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package routes
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/metal-toolbox/fleet-rest-skeleton/internal/app"
+	"golang.org/x/time/rate"
+)
+
+// rateLimitRetryAfter is how long, in seconds, rate limited clients are asked to wait
+const rateLimitRetryAfter = 1
+
+var errRateLimited = errors.New("request rate limit exceeded, retry later")
+
+// newLimiter returns a limiter admitting rps requests per second with the burst,
+// defaulting the burst to a second's worth of requests
+func newLimiter(rps float64, burst int) *rate.Limiter {
+	if burst <= 0 {
+		burst = max(1, int(math.Ceil(rps)))
+	}
+
+	return rate.NewLimiter(rate.Limit(rps), burst)
+}
+
+// routeLimiters returns a limiter for every configured route override, keyed by
+// method and path
+func routeLimiters(overrides []app.RouteRateLimit) map[string]*rate.Limiter {
+	limiters := make(map[string]*rate.Limiter, len(overrides))
+	for _, o := range overrides {
+		limiters[routeKey(o.Method, o.Path)] = newLimiter(o.RequestsPerSecond, o.Burst)
+	}
+
+	return limiters
+}
+
+func routeKey(method, path string) string {
+	return strings.ToUpper(method) + " " + path
+}
+
+// composeRateLimit rejects requests with a 429 once the limiter runs out of tokens.
+// Health endpoints are never limited.
+func composeRateLimit(l *rate.Limiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if strings.HasPrefix(trimBasePath(c.Request.URL.Path), healthPathPrefix) {
+			return
+		}
+
+		if !l.Allow() {
+			c.Header("Retry-After", strconv.Itoa(rateLimitRetryAfter))
+			respondError(c, http.StatusTooManyRequests, errRateLimited)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package routes
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/metal-toolbox/fleet-rest-skeleton/internal/app"
+)
+
+func TestRouteRateLimitOverrides(t *testing.T) {
+	h, logs := newTestHandler(t, &app.Configuration{
+		RateLimit: app.RateLimitConfig{
+			Routes: []app.RouteRateLimit{
+				{Method: "post", Path: "/api/echo", RequestsPerSecond: 0.001, Burst: 1},
+				{Method: http.MethodGet, Path: "/api/nowhere", RequestsPerSecond: 1},
+			},
+		},
+	})
+
+	if w := serve(h, http.MethodPost, "/api/echo", "{}"); w.Code != http.StatusOK {
+		t.Fatalf("expected the first request within the burst, got %d", w.Code)
+	}
+
+	w := serve(h, http.MethodPost, "/api/echo", "{}")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Errorf("expected 429 with Retry-After once the route's limit is hit, got %d", w.Code)
+	}
+
+	if w := serve(h, http.MethodPost, "/api/echo/raw", "{}"); w.Code != http.StatusOK {
+		t.Errorf("expected other routes not to be limited, got %d", w.Code)
+	}
+
+	if logs.FilterMessage("rate limits configured for unknown routes are ignored").Len() != 1 {
+		t.Errorf("expected the override matching no route to be logged, got %v", logs.All())
+	}
+}
@@ -0,0 +1,56 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/metal-toolbox/fleet-rest-skeleton/internal/metrics"
+	"go.uber.org/zap"
+)
+
+var errInternal = errors.New("internal server error")
+
+// composeRecovery recovers from panics in the handlers that follow it, logging the
+// panic along with its stack and responding with a structured 500 carrying the
+// request ID, unless part of the response was already written. Panics with
+// http.ErrAbortHandler are passed on for the server to abort the response. Panics
+// over the client's connection breaking are recorded as errors on the request, for
+// the request logger to report as a disconnect, as nothing can be sent back anyway.
+func composeRecovery() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+
+			if err, ok := rec.(error); ok {
+				if errors.Is(err, http.ErrAbortHandler) {
+					panic(rec)
+				}
+
+				if isBrokenConnection(err) {
+					_ = c.Error(err)
+					c.Abort()
+					return
+				}
+			}
+
+			metrics.APIPanic(routeTemplate(c))
+			Logger(c).Error("recovered from panic handling API request",
+				zap.Any("panic", rec),
+				zap.Stack("stack"),
+			)
+
+			if c.Writer.Written() {
+				c.Abort()
+				return
+			}
+
+			respondError(c, http.StatusInternalServerError, errInternal)
+		}()
+
+		c.Next()
+	}
+}
@@ -0,0 +1,93 @@
+package routes
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// newPanickingRouter serves a route panicking with rec behind the request ID, logging
+// and recovery middleware
+func newPanickingRouter(rec any) (*gin.Engine, *observer.ObservedLogs) {
+	gin.SetMode(gin.TestMode)
+
+	core, logs := observer.New(zapcore.DebugLevel)
+	l := zap.New(core)
+
+	r := gin.New()
+	r.Use(composeRequestID(l), composeAppLogging(l, nil), composeRecovery())
+	r.GET("/panic", func(*gin.Context) { panic(rec) })
+
+	return r, logs
+}
+
+const panicsMetric = "skeleton_api_panics_total"
+
+func TestRecoveryRespondsWithAStructured500(t *testing.T) {
+	r, logs := newPanickingRouter("boom")
+	before := metricValue(t, panicsMetric, "endpoint", "/panic")
+
+	w := serve(r, http.MethodGet, "/panic", "")
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500, got %d", w.Code)
+	}
+
+	body := decodeError(t, w)
+	if body.RequestID == "" || body.RequestID != w.Header().Get(requestIDHeader) {
+		t.Errorf("expected the body to carry the request ID %q, got %+v", w.Header().Get(requestIDHeader), body)
+	}
+
+	if got := metricValue(t, panicsMetric, "endpoint", "/panic") - before; got != 1 {
+		t.Errorf("expected the panic to be counted, got %v", got)
+	}
+
+	if logs.FilterMessage("recovered from panic handling API request").Len() != 1 {
+		t.Error("expected the panic to be logged")
+	}
+}
+
+func TestRecoveryPassesOnAbortedHandlers(t *testing.T) {
+	r, _ := newPanickingRouter(http.ErrAbortHandler)
+
+	defer func() {
+		if rec := recover(); rec != http.ErrAbortHandler {
+			t.Errorf("expected http.ErrAbortHandler to be re-panicked, got %v", rec)
+		}
+	}()
+
+	serve(r, http.MethodGet, "/panic", "")
+	t.Error("expected the panic to reach the server")
+}
+
+func TestRecoveryIgnoresBrokenConnections(t *testing.T) {
+	for _, errno := range []syscall.Errno{syscall.EPIPE, syscall.ECONNRESET} {
+		t.Run(errno.Error(), func(t *testing.T) {
+			r, logs := newPanickingRouter(&net.OpError{Op: "write", Net: "tcp", Err: os.NewSyscallError("write", errno)})
+			before := metricValue(t, panicsMetric, "endpoint", "/panic")
+
+			w := serve(r, http.MethodGet, "/panic", "")
+			if w.Code == http.StatusInternalServerError || w.Body.Len() != 0 {
+				t.Errorf("expected nothing to be written, got %d %s", w.Code, w.Body.String())
+			}
+
+			if got := metricValue(t, panicsMetric, "endpoint", "/panic") - before; got != 0 {
+				t.Errorf("expected no panic to be counted, got %v", got)
+			}
+
+			if logs.FilterLevelExact(zapcore.ErrorLevel).Len() != 0 {
+				t.Errorf("expected no errors to be logged, got %v", logs.All())
+			}
+
+			if logs.FilterMessage("client disconnected during API request").Len() != 1 {
+				t.Error("expected the disconnect to be logged")
+			}
+		})
+	}
+}
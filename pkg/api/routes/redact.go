@@ -0,0 +1,35 @@
+package routes
+
+import (
+	"net/url"
+	"strings"
+)
+
+const redacted = "REDACTED"
+
+// redactQuery replaces the values of the named query parameters in a raw query
+// string, matching names case-insensitively. The rest of the query is left as is.
+func redactQuery(rawQuery string, names []string) string {
+	if rawQuery == "" || len(names) == 0 {
+		return rawQuery
+	}
+
+	pairs := strings.Split(rawQuery, "&")
+	for i, pair := range pairs {
+		key, _, _ := strings.Cut(pair, "=")
+
+		name, err := url.QueryUnescape(key)
+		if err != nil {
+			name = key
+		}
+
+		for _, n := range names {
+			if strings.EqualFold(name, n) {
+				pairs[i] = key + "=" + redacted
+				break
+			}
+		}
+	}
+
+	return strings.Join(pairs, "&")
+}
@@ -0,0 +1,45 @@
+package routes
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/metal-toolbox/fleet-rest-skeleton/internal/app"
+)
+
+func TestRedactQuery(t *testing.T) {
+	names := []string{"token", "password"}
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"", ""},
+		{"page=2", "page=2"},
+		{"token=abc&page=2", "token=" + redacted + "&page=2"},
+		{"TOKEN=abc", "TOKEN=" + redacted},
+		{"pass%77ord=abc", "pass%77ord=" + redacted},
+		{"token", "token=" + redacted},
+	}
+
+	for _, tt := range tests {
+		if got := redactQuery(tt.query, names); got != tt.want {
+			t.Errorf("redactQuery(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestRequestLogsRedactTheQuery(t *testing.T) {
+	h, logs := newTestHandler(t, &app.Configuration{})
+
+	serve(h, http.MethodGet, "/api/version?access_token=abc&verbose=1", "")
+
+	entries := logs.FilterMessage("api call complete").All()
+	if len(entries) != 1 {
+		t.Fatalf("expected the request to be logged, got %v", logs.All())
+	}
+
+	if got := entries[0].ContextMap()["query"]; got != "access_token="+redacted+"&verbose=1" {
+		t.Errorf("expected the default redacted parameters left out, got %q", got)
+	}
+}
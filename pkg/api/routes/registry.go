@@ -0,0 +1,328 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/metal-toolbox/fleet-rest-skeleton/internal/app"
+	"golang.org/x/time/rate"
+)
+
+// handlerKey is the gin context key holding the name a route was registered under
+const handlerKey = "handler"
+
+// unregisteredHandler labels requests that did not match a registered route
+const unregisteredHandler = "unregistered"
+
+// builtinEndpoints are the paths of the endpoints served out of the box which may be
+// disabled
+var builtinEndpoints = []string{
+	"/_health/liveness",
+	versionPath,
+	"/api/log/level",
+	openAPIPath,
+}
+
+var errAuthNotConfigured = errors.New("authentication is required but not configured")
+
+// basePath is the path prefix routes are mounted under, empty when they are mounted
+// at the root
+var basePath string
+
+// trimBasePath returns a request path or route template relative to the base path,
+// as routes are registered. Paths outside the base path are returned as they are.
+func trimBasePath(path string) string {
+	rest, ok := strings.CutPrefix(path, basePath)
+	switch {
+	case !ok:
+		return path
+	case rest == "":
+		return "/"
+	case rest[0] != '/':
+		return path
+	default:
+		return rest
+	}
+}
+
+// routeInfo describes a route registered with the API
+type routeInfo struct {
+	Method  string
+	Path    string
+	Handler string
+	Scopes  []string
+	// OptionalAuth allows anonymous callers, authenticating only those presenting a token
+	OptionalAuth bool
+	// CacheTTL enables response caching for GET requests when positive
+	CacheTTL time.Duration
+	// Streaming routes hold their connection open, and are limited separately
+	Streaming bool
+	// Fallback is served in place of failing while a dependency the route needs is down
+	Fallback *routeFallback
+}
+
+// protected indicates whether the route requires an authenticated caller
+func (ri routeInfo) protected() bool {
+	return len(ri.Scopes) > 0 && !ri.OptionalAuth
+}
+
+// routeRegistry records the routes added to the API and tags every request with
+// the name of the handler it was registered under. Metrics use that name as a
+// stable label, independent of whatever is in the request path.
+type routeRegistry struct {
+	routes gin.IRoutes
+	info   []routeInfo
+	// streamSlots is shared by all streaming routes to cap concurrent streams, nil
+	// unless streaming is enabled
+	streamSlots chan struct{}
+	// disabled holds the paths of builtin endpoints that aren't served
+	disabled map[string]bool
+	// rateLimits holds the limiters of routes with a rate limit override, keyed by
+	// method and path
+	rateLimits map[string]*rate.Limiter
+	// fallbacks holds the fallbacks of routes registered with one, keyed by method
+	// and path
+	fallbacks map[string]*routeFallback
+	// audit records auth decisions for routes requiring auth when set
+	audit *auditor
+}
+
+func newRouteRegistry(routes gin.IRoutes, cfg *app.Configuration) *routeRegistry {
+	r := &routeRegistry{
+		routes:     routes,
+		rateLimits: routeLimiters(cfg.RateLimit.Routes),
+		disabled:   make(map[string]bool, len(cfg.DisableBuiltinEndpoints)),
+		fallbacks:  make(map[string]*routeFallback),
+	}
+	if cfg.StreamingEnabled {
+		r.streamSlots = make(chan struct{}, cfg.MaxConcurrentStreams)
+	}
+	for _, path := range cfg.DisableBuiltinEndpoints {
+		r.disabled[path] = true
+	}
+
+	return r
+}
+
+// handle registers the handler chain for the method and path under the given name.
+// When scopes are given the route is protected by the auth middleware, requiring
+// the caller to hold at least one of them.
+func (r *routeRegistry) handle(method, path, name string, scopes []string, handlers ...gin.HandlerFunc) {
+	r.add(routeInfo{
+		Method:  method,
+		Path:    path,
+		Handler: name,
+		Scopes:  scopes,
+	}, handlers...)
+}
+
+// handleOptionalAuth registers a route like handle, but callers without a token are
+// let through anonymously. Handlers can tell the two apart by the claims attached to
+// the context.
+func (r *routeRegistry) handleOptionalAuth(method, path, name string, scopes []string, handlers ...gin.HandlerFunc) {
+	r.add(routeInfo{
+		Method:       method,
+		Path:         path,
+		Handler:      name,
+		Scopes:       scopes,
+		OptionalAuth: true,
+	}, handlers...)
+}
+
+// handleCached registers a GET route like handle, serving repeated requests for the
+// same caller, format, path and query from an in-memory cache until the TTL expires.
+func (r *routeRegistry) handleCached(path, name string, scopes []string, ttl time.Duration, handlers ...gin.HandlerFunc) {
+	r.add(routeInfo{
+		Method:   http.MethodGet,
+		Path:     path,
+		Handler:  name,
+		Scopes:   scopes,
+		CacheTTL: ttl,
+	}, handlers...)
+}
+
+// handleWithFallback registers a route like handle, which keeps serving with a 200
+// and an X-Degraded header while a dependency it needs is down. The last successful
+// response to the same request is replayed when younger than maxAge, and the static
+// payload is served otherwise. Without either the request fails as usual.
+func (r *routeRegistry) handleWithFallback(method, path, name string, scopes []string, static any, maxAge time.Duration, handlers ...gin.HandlerFunc) {
+	r.add(routeInfo{
+		Method:   method,
+		Path:     path,
+		Handler:  name,
+		Scopes:   scopes,
+		Fallback: newRouteFallback(static, maxAge),
+	}, handlers...)
+}
+
+// fallback returns the fallback of the route matching the method and path template
+func (r *routeRegistry) fallback(method, path string) *routeFallback {
+	return r.fallbacks[routeKey(method, path)]
+}
+
+// handleBuiltin registers a route like handle for an endpoint provided by this
+// service out of the box, unless its path is among those configured as disabled.
+func (r *routeRegistry) handleBuiltin(method, path, name string, scopes []string, handlers ...gin.HandlerFunc) {
+	if r.disabled[path] {
+		return
+	}
+
+	r.handle(method, path, name, scopes, handlers...)
+}
+
+// unknownDisabledEndpoints returns the disabled builtin endpoints matching no route
+// known to the registry
+func (r *routeRegistry) unknownDisabledEndpoints() []string {
+	var unknown []string
+	for path := range r.disabled {
+		if !slices.Contains(builtinEndpoints, path) {
+			unknown = append(unknown, path)
+		}
+	}
+	slices.Sort(unknown)
+
+	return unknown
+}
+
+// handleStream registers a GET route like handle for a handler streaming its
+// response. Streams are capped by the concurrent stream limit.
+func (r *routeRegistry) handleStream(path, name string, scopes []string, handlers ...gin.HandlerFunc) {
+	r.add(routeInfo{
+		Method:    http.MethodGet,
+		Path:      path,
+		Handler:   name,
+		Scopes:    scopes,
+		Streaming: true,
+	}, handlers...)
+}
+
+// add records the route and composes its middleware from the route metadata
+func (r *routeRegistry) add(ri routeInfo, handlers ...gin.HandlerFunc) {
+	r.info = append(r.info, ri)
+
+	chain := []gin.HandlerFunc{labelHandler(ri.Handler)}
+	if r.audit != nil && (ri.protected() || ri.OptionalAuth) {
+		chain = append(chain, r.audit.compose())
+	}
+
+	switch {
+	case ri.protected():
+		chain = append(chain, composeAuthHandler(ri.Scopes), markAuthPassed)
+	case ri.OptionalAuth:
+		chain = append(chain, composeOptionalAuthHandler(ri.Scopes), markAuthPassed)
+	}
+
+	if l, ok := r.rateLimits[routeKey(ri.Method, ri.Path)]; ok {
+		chain = append(chain, composeRateLimit(l))
+	}
+
+	if ri.Streaming {
+		chain = append(chain, composeStreamLimiter(r.streamSlots), composeStreamTTFB())
+	}
+
+	if ri.CacheTTL > 0 {
+		chain = append(chain, composeResponseCache(ri.Handler, ri.CacheTTL, ri.protected() || ri.OptionalAuth))
+	}
+
+	if ri.Fallback != nil {
+		r.fallbacks[routeKey(ri.Method, ri.Path)] = ri.Fallback
+		chain = append(chain, composeFallback(ri.Fallback))
+	}
+
+	r.routes.Handle(ri.Method, ri.Path, append(chain, handlers...)...)
+}
+
+// unmatchedRateLimits returns the rate limit overrides naming no registered route
+func (r *routeRegistry) unmatchedRateLimits() []string {
+	var unmatched []string
+	for key := range r.rateLimits {
+		if !slices.ContainsFunc(r.info, func(ri routeInfo) bool { return routeKey(ri.Method, ri.Path) == key }) {
+			unmatched = append(unmatched, key)
+		}
+	}
+	slices.Sort(unmatched)
+
+	return unmatched
+}
+
+// allowedMethods returns the methods of the routes matching the request path, sorted
+func (r *routeRegistry) allowedMethods(path string) []string {
+	var methods []string
+	for _, ri := range r.info {
+		if pathMatches(ri.Path, path) && !slices.Contains(methods, ri.Method) {
+			methods = append(methods, ri.Method)
+		}
+	}
+	slices.Sort(methods)
+
+	return methods
+}
+
+// pathMatches reports whether a request path matches a gin route template
+func pathMatches(template, path string) bool {
+	ts := strings.Split(template, "/")
+	ps := strings.Split(path, "/")
+
+	for i, t := range ts {
+		if strings.HasPrefix(t, "*") {
+			return true
+		}
+
+		if i >= len(ps) || (ps[i] != t && !(strings.HasPrefix(t, ":") && ps[i] != "")) {
+			return false
+		}
+	}
+
+	return len(ts) == len(ps)
+}
+
+// composeMethodNotAllowed responds 405 to requests for a path served with other
+// methods, listing those in the Allow header and the body
+func (r *routeRegistry) composeMethodNotAllowed() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		allowed := r.allowedMethods(trimBasePath(c.Request.URL.Path))
+		c.Header("Allow", strings.Join(allowed, ", "))
+
+		respondError(c, http.StatusMethodNotAllowed, errMethodNotAllowed, withAllowedMethods(allowed))
+	}
+}
+
+// checkAuthRequirement refuses to expose protected routes without authentication when
+// the configuration requires it outside of developer mode.
+func (r *routeRegistry) checkAuthRequirement(cfg *app.Configuration) error {
+	if !cfg.RequireAuthInProduction || cfg.DeveloperMode || authConfigured() {
+		return nil
+	}
+
+	var protected []string
+	for _, ri := range r.info {
+		if ri.protected() {
+			protected = append(protected, ri.Method+" "+ri.Path)
+		}
+	}
+
+	if len(protected) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("%w: protected routes %s", errAuthNotConfigured, strings.Join(protected, ", "))
+}
+
+func labelHandler(name string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(handlerKey, name)
+	}
+}
+
+// handlerName returns the name of the registered handler serving the request
+func handlerName(c *gin.Context) string {
+	if name := c.GetString(handlerKey); name != "" {
+		return name
+	}
+	return unregisteredHandler
+}
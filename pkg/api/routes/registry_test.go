@@ -0,0 +1,117 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/metal-toolbox/fleet-rest-skeleton/internal/app"
+)
+
+func TestDisabledBuiltinEndpoints(t *testing.T) {
+	h, logs := newTestHandler(t, &app.Configuration{
+		DisableBuiltinEndpoints: []string{"/_health/liveness", "/api/version", "/api/nope"},
+	})
+
+	tests := []struct {
+		path       string
+		wantStatus int
+	}{
+		{"/_health/liveness", http.StatusNotFound},
+		{"/api/version", http.StatusNotFound},
+		{app.ReadinessPath, http.StatusOK},
+		{openAPIPath, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		if w := serve(h, http.MethodGet, tt.path, ""); w.Code != tt.wantStatus {
+			t.Errorf("%s: expected %d, got %d", tt.path, tt.wantStatus, w.Code)
+		}
+	}
+
+	if logs.FilterMessage("disabled endpoints are not builtin endpoints, ignoring").Len() != 1 {
+		t.Error("expected a warning for disabling an endpoint that isn't builtin")
+	}
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	h, _ := newTestHandler(t, &app.Configuration{})
+
+	w := serve(h, http.MethodGet, "/api/echo", "")
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405, got %d", w.Code)
+	}
+
+	if got := w.Header().Get("Allow"); got != http.MethodPost {
+		t.Errorf("expected Allow %q, got %q", http.MethodPost, got)
+	}
+
+	if got := decodeError(t, w).AllowedMethods; !slices.Equal(got, []string{http.MethodPost}) {
+		t.Errorf("expected the body to list the allowed methods, got %v", got)
+	}
+
+	if w := serve(h, http.MethodGet, "/api/nope", ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected an unknown path to get 404, got %d", w.Code)
+	}
+}
+
+func TestCheckAuthRequirement(t *testing.T) {
+	protected := routeInfo{Method: http.MethodPost, Path: "/api/echo", Handler: "echo", Scopes: []string{"create:echo"}}
+	optional := routeInfo{Method: http.MethodGet, Path: "/api/whoami", Handler: "whoami", Scopes: []string{"read"}, OptionalAuth: true}
+	public := routeInfo{Method: http.MethodGet, Path: "/api/version", Handler: "version"}
+
+	tests := []struct {
+		name    string
+		cfg     app.Configuration
+		keys    []app.APIKey
+		routes  []routeInfo
+		wantErr bool
+	}{
+		{
+			name:    "production without auth",
+			cfg:     app.Configuration{RequireAuthInProduction: true},
+			routes:  []routeInfo{public, protected},
+			wantErr: true,
+		},
+		{
+			name:   "developer mode",
+			cfg:    app.Configuration{RequireAuthInProduction: true, DeveloperMode: true},
+			routes: []routeInfo{public, protected},
+		},
+		{
+			name:   "auth configured",
+			cfg:    app.Configuration{RequireAuthInProduction: true},
+			keys:   []app.APIKey{{Name: "writer", Key: "writer-key", Scopes: []string{"create:echo"}}},
+			routes: []routeInfo{public, protected},
+		},
+		{
+			name:   "not required",
+			cfg:    app.Configuration{},
+			routes: []routeInfo{public, protected},
+		},
+		{
+			name:   "no protected routes",
+			cfg:    app.Configuration{RequireAuthInProduction: true},
+			routes: []routeInfo{public, optional},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setTestAuth(t, tt.keys, nil)
+			setTestClientScopes(t, nil)
+
+			r := &routeRegistry{info: tt.routes}
+
+			err := r.checkAuthRequirement(&tt.cfg)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("expected an error %v, got %v", tt.wantErr, err)
+			}
+
+			if tt.wantErr && !errors.Is(err, errAuthNotConfigured) {
+				t.Errorf("expected %v, got %v", errAuthNotConfigured, err)
+			}
+		})
+	}
+}
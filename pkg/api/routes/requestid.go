@@ -0,0 +1,56 @@
+package routes
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const (
+	requestIDHeader = "X-Request-Id"
+	requestIDKey    = "request_id"
+)
+
+// composeRequestID makes sure every request carries a correlation ID. An ID supplied
+// by the caller is reused, otherwise a new one is generated. The ID is echoed back
+// to the caller in the response headers.
+//
+// Misbehaving proxies sometimes send several IDs, either as repeated headers or
+// joined into one. The first is used and a warning is logged.
+func composeRequestID(l *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ids []string
+		for _, v := range c.Request.Header.Values(requestIDHeader) {
+			for _, id := range strings.Split(v, ",") {
+				if id = strings.TrimSpace(id); id != "" {
+					ids = append(ids, id)
+				}
+			}
+		}
+
+		var id string
+		switch len(ids) {
+		case 0:
+			id = uuid.NewString()
+		case 1:
+			id = ids[0]
+		default:
+			id = ids[0]
+			l.Warn("multiple request IDs supplied, using the first",
+				zap.String("request_id", id),
+				zap.Strings("request_ids", ids),
+			)
+		}
+
+		c.Set(requestIDKey, id)
+		c.Header(requestIDHeader, id)
+		c.Next()
+	}
+}
+
+// RequestID returns the correlation ID of the request being handled
+func RequestID(c *gin.Context) string {
+	return c.GetString(requestIDKey)
+}
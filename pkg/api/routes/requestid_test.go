@@ -0,0 +1,164 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// newRequestIDTestRouter returns a router serving GET /id, which responds with the
+// request ID seen by the handler, and the logs of its requests
+func newRequestIDTestRouter() (*gin.Engine, *observer.ObservedLogs) {
+	gin.SetMode(gin.TestMode)
+
+	core, logs := observer.New(zapcore.InfoLevel)
+	l := zap.New(core)
+
+	r := gin.New()
+	r.Use(composeRequestID(l), composeAppLogging(l, nil))
+	r.GET("/id", func(c *gin.Context) {
+		c.String(http.StatusOK, RequestID(c))
+	})
+
+	return r, logs
+}
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers []string
+		want    string
+	}{
+		{
+			name:    "supplied",
+			headers: []string{requestIDHeader, "req-1"},
+			want:    "req-1",
+		},
+		{
+			name:    "blank is replaced",
+			headers: []string{requestIDHeader, " "},
+		},
+		{
+			name: "generated",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, logs := newRequestIDTestRouter()
+
+			w := serve(r, http.MethodGet, "/id", "", tt.headers...)
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected %d, got %d", http.StatusOK, w.Code)
+			}
+
+			id := w.Body.String()
+			if tt.want != "" && id != tt.want {
+				t.Errorf("expected the handler to see %q, got %q", tt.want, id)
+			}
+
+			if tt.want == "" {
+				if _, err := uuid.Parse(id); err != nil {
+					t.Errorf("expected a generated UUID, got %q: %v", id, err)
+				}
+			}
+
+			if got := w.Header().Get(requestIDHeader); got != id {
+				t.Errorf("expected the response header to echo %q, got %q", id, got)
+			}
+
+			entries := logs.FilterMessage("api call complete").All()
+			if len(entries) != 1 {
+				t.Fatalf("expected 1 log line, got %v", logs.All())
+			}
+
+			if got := entries[0].ContextMap()["request_id"]; got != id {
+				t.Errorf("expected the log line to carry %q, got %v", id, got)
+			}
+		})
+	}
+}
+
+func TestDuplicateRequestIDs(t *testing.T) {
+	const warning = "multiple request IDs supplied, using the first"
+
+	tests := []struct {
+		name        string
+		headers     []string
+		want        string
+		wantIDs     []string
+		wantWarning bool
+	}{
+		{
+			name:        "repeated headers",
+			headers:     []string{"req-1", "req-2"},
+			want:        "req-1",
+			wantIDs:     []string{"req-1", "req-2"},
+			wantWarning: true,
+		},
+		{
+			name:        "joined in one header",
+			headers:     []string{"req-1, req-2,req-3"},
+			want:        "req-1",
+			wantIDs:     []string{"req-1", "req-2", "req-3"},
+			wantWarning: true,
+		},
+		{
+			name:    "blanks are skipped",
+			headers: []string{" , req-1", ""},
+			want:    "req-1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, logs := newRequestIDTestRouter()
+
+			req := httptest.NewRequest(http.MethodGet, "/id", nil)
+			for _, v := range tt.headers {
+				req.Header.Add(requestIDHeader, v)
+			}
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if got := w.Body.String(); got != tt.want {
+				t.Errorf("expected the handler to see %q, got %q", tt.want, got)
+			}
+
+			if got := w.Header().Values(requestIDHeader); !slices.Equal(got, []string{tt.want}) {
+				t.Errorf("expected the response header to echo only %q, got %v", tt.want, got)
+			}
+
+			warnings := logs.FilterMessage(warning).All()
+			if got := len(warnings) == 1; got != tt.wantWarning {
+				t.Fatalf("expected a warning %v, got %v", tt.wantWarning, logs.All())
+			}
+
+			if !tt.wantWarning {
+				return
+			}
+
+			fields := warnings[0].ContextMap()
+			if fields["request_id"] != tt.want {
+				t.Errorf("expected the warning to name the ID used, got %v", fields["request_id"])
+			}
+
+			var ids []string
+			for _, id := range fields["request_ids"].([]any) {
+				ids = append(ids, id.(string))
+			}
+
+			if !slices.Equal(ids, tt.wantIDs) {
+				t.Errorf("expected the warning to list %v, got %v", tt.wantIDs, ids)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package routes
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// rereadableBody is a buffered request body that can be read again from the start
+// once rewound. Reads behave like any other body; rewinding is left to whoever read
+// it, so a partial read is never silently restarted.
+type rereadableBody struct {
+	*bytes.Reader
+}
+
+// Rewind moves the body back to its start.
+func (b *rereadableBody) Rewind() error {
+	_, err := b.Seek(0, io.SeekStart)
+	return err
+}
+
+func (b *rereadableBody) Close() error {
+	return nil
+}
+
+// rewindBody rewinds a body buffered by composeBodyBuffer. Middleware reading the
+// body calls it before passing the request on, so the rest of the chain sees the
+// whole body. Bodies that weren't buffered are left as they are.
+func rewindBody(c *gin.Context) error {
+	if b, ok := c.Request.Body.(*rereadableBody); ok {
+		return b.Rewind()
+	}
+
+	return nil
+}
+
+// composeBodyBuffer reads request bodies into memory ahead of the rest of the chain,
+// replacing them with a body that can be read any number of times, e.g. by signature
+// verification, schema validation and then the handler, each rewinding it with
+// rewindBody once done. Bodies are bounded by the body limit, which must be in place
+// ahead of this middleware. The buffered body is also stored under gin.BodyBytesKey
+// for c.ShouldBindBodyWith.
+func composeBodyBuffer() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Body == nil || c.Request.Body == http.NoBody {
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			respondBindError(c, err)
+			return
+		}
+		c.Request.Body.Close()
+
+		c.Set(gin.BodyBytesKey, body)
+		c.Request.Body = &rereadableBody{Reader: bytes.NewReader(body)}
+	}
+}
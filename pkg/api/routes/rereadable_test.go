@@ -0,0 +1,82 @@
+package routes
+
+import (
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/metal-toolbox/fleet-rest-skeleton/internal/app"
+)
+
+// readingMiddleware reads the whole body, failing the request if it isn't want, and
+// rewinds it when rewind is set
+func readingMiddleware(want string, rewind bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil || string(body) != want {
+			c.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+
+		if rewind {
+			if err := rewindBody(c); err != nil {
+				c.AbortWithStatus(http.StatusInternalServerError)
+			}
+		}
+	}
+}
+
+func TestBodyBufferRereading(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	const payload = `{"hello":"world"}`
+
+	tests := []struct {
+		name     string
+		rewind   bool
+		wantBody string
+	}{
+		{"rewound by each reader", true, payload},
+		{"not rewound", false, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.POST("/body",
+				composeBodyBuffer(),
+				readingMiddleware(payload, true),
+				readingMiddleware(payload, tt.rewind),
+				func(c *gin.Context) {
+					body, _ := io.ReadAll(c.Request.Body)
+					c.String(http.StatusOK, string(body))
+				},
+			)
+
+			w := serve(r, http.MethodPost, "/body", payload)
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected 200, got %d", w.Code)
+			}
+
+			if got := w.Body.String(); got != tt.wantBody {
+				t.Errorf("expected the handler to read %q, got %q", tt.wantBody, got)
+			}
+		})
+	}
+}
+
+func TestBodyBufferKeepsBodyForHandlers(t *testing.T) {
+	h, _ := newTestHandler(t, &app.Configuration{BufferRequestBodies: true, MaxRequestBytes: 1024})
+
+	const payload = `{"b":1,"a":2}`
+
+	w := serve(h, http.MethodPost, "/api/echo/raw", payload, "Content-Type", "application/json")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if got := w.Body.String(); got != payload {
+		t.Errorf("expected the buffered body %q, got %q", payload, got)
+	}
+}
@@ -1,8 +1,16 @@
 package routes
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -10,36 +18,65 @@ import (
 	"github.com/metal-toolbox/fleet-rest-skeleton/internal/metrics"
 	"github.com/metal-toolbox/fleet-rest-skeleton/internal/version"
 	"go.hollow.sh/toolbox/ginauth"
-	"go.hollow.sh/toolbox/ginjwt"
 	"go.uber.org/zap"
 )
 
 var (
-	readTimeout  = 10 * time.Second
-	writeTimeout = 20 * time.Second
+	readTimeout = 10 * time.Second
 
-	authMiddleWare *ginauth.MultiTokenMiddleware
+	// authMiddleWare is swapped out whenever the JWKS are refreshed
+	authMiddleWare atomic.Pointer[ginauth.MultiTokenMiddleware]
 	ginNoOp        = func(_ *gin.Context) {}
 )
 
-// apiHandler is a function that performs real work for this API.
-type apiHandler func(map[string]any) (map[string]any, error)
+// unmatchedRoute is the endpoint label for requests that matched no route
+const unmatchedRoute = "unmatched"
 
-func composeAppLogging(l *zap.Logger) gin.HandlerFunc {
+// requestStartKey is the gin context key holding the time the request was received
+const requestStartKey = "request_start"
+
+// apiHandler is a function that performs real work for this API. It is handed the
+// request context, carrying the request logger (see LoggerFromContext) and deadline.
+type apiHandler func(context.Context, map[string]any) (map[string]any, error)
+
+// composeAppLogging logs and records metrics for every request. The values of the
+// redacted query parameters are left out of the logs.
+func composeAppLogging(l *zap.Logger, redact []string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		start := time.Now()
+		c.Set(requestStartKey, start)
+		setRequestLogger(c, l)
 		// some evil middlewares modify this values
 		path := c.Request.URL.Path
-		query := c.Request.URL.RawQuery
+		query := redactQuery(c.Request.URL.RawQuery, redact)
 		c.Next() // call the next function in the chain
 		code := c.Writer.Status()
-		metrics.APICallEpilog(start, path, code)
+		endpoint := routeTemplate(c)
+		metrics.APICallEpilog(c.Request.Context(), start, endpoint, handlerName(c), code)
+		metrics.APICallSizes(endpoint, c.Request.ContentLength, c.Writer.Size())
 
 		fields := []zap.Field{
 			zap.String("path", path),
 			zap.String("query", query),
 			zap.Int("status-code", code),
 			zap.Time("start", start),
+			zap.String("request_id", RequestID(c)),
+			traceIDField(c),
+		}
+
+		if len(c.Errors) > 0 && clientDisconnected(c.Errors) {
+			// nothing is wrong on our side, the client went away mid-response
+			metrics.ClientDisconnect(endpoint)
+			fields = append(fields, zap.Strings("errors", c.Errors.Errors()))
+			l.Debug("client disconnected during API request", fields...)
+			return
+		}
+
+		if len(c.Errors) > 0 && code < http.StatusInternalServerError {
+			// the request was turned away, which is routine
+			fields = append(fields, zap.Strings("errors", c.Errors.Errors()))
+			l.Info("client error on API request", fields...)
+			return
 		}
 
 		if len(c.Errors) > 0 {
@@ -54,135 +91,340 @@ func composeAppLogging(l *zap.Logger) gin.HandlerFunc {
 	}
 }
 
+// clientDisconnected indicates whether every error recorded for a request stems from
+// the client closing its connection while the response was being written.
+func clientDisconnected(errs []*gin.Error) bool {
+	for _, e := range errs {
+		if !isBrokenConnection(e.Err) {
+			return false
+		}
+	}
+	return true
+}
+
+// isBrokenConnection indicates whether err stems from the client closing its
+// connection, e.g. a broken pipe or a connection reset by the peer
+func isBrokenConnection(err error) bool {
+	return errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, net.ErrClosed)
+}
+
+// routeTemplate returns the matched route template (e.g. /api/servers/:id) rather than
+// the raw request path, keeping metric label cardinality bounded.
+func routeTemplate(c *gin.Context) string {
+	if tmpl := c.FullPath(); tmpl != "" {
+		return tmpl
+	}
+	return unmatchedRoute
+}
+
 // ComposeHTTPServer returns an http.Server that handles our API
 func ComposeHTTPServer(theApp *app.App) *http.Server {
-	if len(theApp.Cfg.JWTAuth) != 0 {
-		var err error
-		authMiddleWare, err = ginjwt.NewMultiTokenMiddlewareFromConfigs(theApp.Cfg.JWTAuth...)
-		if err != nil {
+	jwtAuthConfigured = len(theApp.Cfg.JWTAuth) != 0
+	if jwtAuthConfigured {
+		mw, err := fetchJWKS(theApp.Cfg.JWTAuth, theApp.Cfg.JWKSFetchTimeout)
+		switch {
+		case errors.Is(err, errJWKSTimeout):
+			// keep serving, JWT auth responds 503 until the keys are fetched
+			theApp.Log.Error("timed out fetching jwks, retrying in the background",
+				zap.Duration("timeout", theApp.Cfg.JWKSFetchTimeout),
+			)
+		case err != nil:
 			theApp.Log.Fatal(
 				"failed to initialize auth middleware",
 				zap.Error(err),
 			)
+		default:
+			authMiddleWare.Store(mw)
+		}
+
+		if theApp.Cfg.JWKSRefreshInterval > 0 || authMiddleWare.Load() == nil {
+			refresher := &jwksRefresher{
+				app:      theApp,
+				configs:  theApp.Cfg.JWTAuth,
+				interval: theApp.Cfg.JWKSRefreshInterval,
+				timeout:  theApp.Cfg.JWKSFetchTimeout,
+			}
+			go refresher.run()
+		}
+
+		for issuer, source := range theApp.Cfg.JWTAuthSources() {
+			theApp.Verbose().Info("jwt auth configured",
+				zap.String("issuer", issuer),
+				zap.String("source", source),
+			)
 		}
 	}
 
+	setAPIKeys(theApp.Cfg.APIKeys)
+	basePath = strings.TrimSuffix(theApp.Cfg.BasePath, "/")
+	setClientScopes(theApp.Cfg.TLS.ClientScopes)
+	exposeDecodeErrors = theApp.Cfg.ExposeDecodeErrors
+
+	if theApp.Cfg.WriteTimeoutCutsStreams() {
+		theApp.Log.Warn("streaming is enabled with a finite write timeout, streams will be cut off; "+
+			"set write_timeout to 0 or enable disable_write_timeout_for_streaming",
+			zap.Duration("write_timeout", theApp.Cfg.WriteTimeout),
+		)
+	}
+
+	if configured, ok := theApp.Cfg.ConfiguredRequestTimeout(); ok {
+		theApp.Log.Warn("request timeout shortened to expire ahead of the write timeout",
+			zap.Duration("configured", configured),
+			zap.Duration("request_timeout", theApp.Cfg.RequestTimeout),
+			zap.Duration("write_timeout", theApp.Cfg.WriteTimeout),
+		)
+	}
+
 	g := gin.New()
+	r := newRouteRegistry(g, theApp.Cfg)
+	r.audit = newAuditor(theApp)
 
 	if !theApp.Cfg.DeveloperMode {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
-	// set up common middleware for logging and metrics
-	g.Use(composeAppLogging(theApp.Log), gin.Recovery())
+	// set up common middleware for request correlation, tracing, logging and metrics
+	g.Use(composeTracing(), composeRequestID(theApp.Log), composeAppLogging(theApp.Log, theApp.Cfg.RedactParams), composeRecovery())
+	g.Use(composeHeaderGuard(theApp.Cfg.MaxResponseHeaderBytes, theApp.Log))
+	g.Use(composeShutdownGate(theApp))
+
+	if theApp.Cfg.MaxRequestBytes > 0 {
+		g.Use(composeBodyLimit(theApp.Cfg.MaxRequestBytes))
+	}
+
+	if theApp.Cfg.BufferRequestBodies {
+		g.Use(composeBodyBuffer())
+	}
+
+	if rl := theApp.Cfg.RateLimit; rl.RequestsPerSecond > 0 {
+		g.Use(composeRateLimit(newLimiter(rl.RequestsPerSecond, rl.Burst)))
+	}
+
+	if theApp.Cfg.MaxConcurrentRequests > 0 {
+		g.Use(composeConcurrencyLimit(theApp.Cfg.MaxConcurrentRequests))
+	}
+
+	if theApp.Cfg.RecommendedClientVersion != "" || theApp.Cfg.RequiredClientVersion != "" {
+		check, err := composeClientVersionCheck(
+			theApp.Cfg.RecommendedClientVersion,
+			theApp.Cfg.RequiredClientVersion,
+			theApp.Log,
+		)
+		if err != nil {
+			theApp.Log.Fatal("invalid client version configuration",
+				zap.Error(err),
+			)
+		}
+		g.Use(check)
+	}
+
+	if theApp.Cfg.ReplayWindow > 0 {
+		store, ok := app.GetOption[NonceStore](theApp, NonceStoreOption)
+		if !ok {
+			store = NewMemoryNonceStore()
+		}
+		g.Use(composeReplayProtection(store, theApp.Cfg.ReplayWindow))
+	}
+
+	if len(theApp.Cfg.Degradation) > 0 {
+		g.Use(composeDegradationGate(theApp, r.fallback))
+	}
+
+	if theApp.Cfg.RequestTimeout > 0 || theApp.Cfg.MaxClientRequestTimeout > 0 {
+		g.Use(composeRequestTimeout(theApp.Cfg.RequestTimeout, theApp.Cfg.MaxClientRequestTimeout))
+	}
+
+	// routes are mounted once all middleware is in use, as groups only pick up the
+	// middleware in use when created
+	r.routes = g.Group(theApp.Cfg.BasePath)
 
 	// some boilerplate setup
 	g.NoRoute(func(c *gin.Context) {
-		c.JSON(http.StatusNotFound,
-			gin.H{
-				"message": "invalid request - route not found",
-			},
-		)
+		respondError(c, http.StatusNotFound, errRouteNotFound)
 	})
 
+	g.HandleMethodNotAllowed = true
+	g.NoMethod(r.composeMethodNotAllowed())
+
 	// a liveness endpoint
-	g.GET("/_health/liveness", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{"time": time.Now()})
-	})
+	r.handleBuiltin(http.MethodGet, "/_health/liveness", "liveness", nil, composeLivenessHandler(theApp))
 
-	g.GET("/api/version", func(c *gin.Context) {
-		c.JSON(http.StatusOK, version.Current())
-	})
+	// a readiness endpoint, failing while dependencies needed for reads are unusable
+	r.handle(http.MethodGet, app.ReadinessPath, "readiness", nil, composeReadinessHandler(theApp))
+
+	// the version is served from a fallback while dependencies needed for reads are
+	// down, as it needs none of them
+	if !r.disabled[versionPath] {
+		r.handleWithFallback(http.MethodGet, versionPath, "version", nil,
+			versionResponse{Version: version.Current()}, versionFallbackMaxAge,
+			composeVersionHandler(theApp))
+	}
+
+	// the caller's identity, for callers presenting credentials, or anonymous
+	r.handleOptionalAuth(http.MethodGet, "/api/whoami", "whoami",
+		readScopes("identity"),
+		apiWhoAmI)
+
+	// api functions, wrapped into middleware and protected by the create:response scope
+	r.api().
+		POST("/api/echo", "echo", composeEcho(theApp.Cfg.EchoReservedKeyPrefixes), "response")
+
+	// the echo, decoding payloads by the schema version the caller asks for
+	r.handle(http.MethodPost, "/api/echo/versioned", "echo-versioned",
+		createScopes("response"), // scopes enforced by the auth handler
+		wrapVersionedAPICall(echoVersions, composeEcho(theApp.Cfg.EchoReservedKeyPrefixes)))
 
-	g.POST("/api/echo",
-		composeAuthHandler(createScopes("response")), // auth handler
-		wrapAPICall(apiEcho))                         // api function, wrapped into middleware
+	// the echo, rejecting payloads that don't conform to its schema
+	r.api().withSchema(echoSchema).
+		POST("/api/echo/validated", "echo-validated", composeEcho(theApp.Cfg.EchoReservedKeyPrefixes), "response")
 
-	g.POST("/api/error",
-		composeAuthHandler(createScopes("response")),
-		wrapAPICall(apiError))
+	r.handle(http.MethodPost, "/api/error", "error",
+		createScopes("response"), // scopes enforced by the auth handler
+		wrapStrictAPICall(apiError))
 
-	// add other API endpoints to the gin Engine as required
+	r.handle(http.MethodPost, "/api/echo/raw", "echo-raw",
+		createScopes("response"), // scopes enforced by the auth handler
+		apiEchoRaw)
+
+	r.handle(http.MethodPost, "/api/bulk", "bulk",
+		createScopes("items"),
+		composeBulkCreateHandler(theApp.Cfg.MaxBatchSize))
+
+	if theApp.Cfg.StreamingEnabled {
+		r.handleStream("/api/stream/time", "stream-time",
+			readScopes("time"),
+			apiStreamTime)
+	}
+
+	logLevel, adjustable := theApp.LogLevel()
+	r.handleBuiltin(http.MethodGet, "/api/log/level", "log-level",
+		readScopes("log-level"),
+		composeGetLogLevel(logLevel))
+
+	r.handleBuiltin(http.MethodPut, "/api/log/level", "log-level",
+		updateScopes("log-level"),
+		composeSetLogLevel(logLevel, adjustable))
+
+	if theApp.Cfg.EnablePprof {
+		registerPprof(r)
+	}
 
-	return &http.Server{
+	// register other API endpoints with the route registry as required
+
+	if err := registerOpenAPI(r); err != nil {
+		theApp.Log.Fatal("failed to compose the openapi spec",
+			zap.Error(err),
+		)
+	}
+
+	if unknown := r.unknownDisabledEndpoints(); len(unknown) > 0 {
+		theApp.Log.Warn("disabled endpoints are not builtin endpoints, ignoring",
+			zap.Strings("endpoints", unknown),
+		)
+	}
+
+	if unmatched := r.unmatchedRateLimits(); len(unmatched) > 0 {
+		theApp.Log.Warn("rate limits configured for unknown routes are ignored",
+			zap.Strings("routes", unmatched),
+		)
+	}
+
+	if err := r.checkAuthRequirement(theApp.Cfg); err != nil {
+		theApp.Log.Fatal(
+			"refusing to serve unauthenticated protected routes",
+			zap.Error(err),
+		)
+	}
+
+	srv := &http.Server{
 		Addr:         theApp.Cfg.ListenAddress,
 		Handler:      g,
 		ReadTimeout:  readTimeout,
-		WriteTimeout: writeTimeout,
+		WriteTimeout: theApp.Cfg.WriteTimeout,
 	}
+
+	if theApp.Cfg.TLS.Enabled() {
+		tlsCfg, err := composeTLSConfig(&theApp.Cfg.TLS)
+		if err != nil {
+			theApp.Log.Fatal(
+				"failed to configure TLS",
+				zap.Error(err),
+			)
+		}
+		srv.TLSConfig = tlsCfg
+	}
+
+	return srv
 }
 
 // wrapAPICall is an adapter for any arbitrary code so that you can isolate your
 // logic from having to take gin-specific data structures and whatnot. It assumes
 // your API function takes a map[string]any and returns a JSON-serializable result
 // and an error. This function could be altered to pull any kind of parameter out
-// of the raw JSON input.
+// of the raw JSON input. Clients may send and accept msgpack in place of JSON.
 func wrapAPICall(fn apiHandler) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		var responseCode int
-
 		m := make(map[string]any)
-		if err := ctx.BindJSON(&m); err != nil {
-			ctx.JSON(http.StatusBadRequest, map[string]any{
-				"error": err.Error(),
-			})
+		// an empty body is taken as an empty object
+		if err := bindPayload(ctx, &m); err != nil && !errors.Is(err, io.EOF) {
+			respondBindError(ctx, err)
+			return
 		}
 
-		obj, err := fn(m)
-		if err == nil {
-			responseCode = http.StatusOK
-		} else {
-			responseCode = http.StatusInternalServerError
-			obj = map[string]any{
-				"error": err.Error(),
-			}
-		}
-		ctx.JSON(responseCode, obj)
+		respondAPICall(ctx, fn, m)
 	}
 }
 
-func composeAuthHandler(scopes []string) gin.HandlerFunc {
-	if authMiddleWare == nil {
-		return ginNoOp
-	}
-	return authMiddleWare.AuthRequired(scopes)
+// respondAPICall invokes the API function with the decoded request and writes out
+// its result.
+func respondAPICall(ctx *gin.Context, fn apiHandler, m map[string]any) {
+	obj, err := fn(ctx.Request.Context(), m)
+	respondResult(ctx, obj, err)
 }
 
-func createScopes(items ...string) []string {
-	s := []string{"write", "create"}
-	for _, i := range items {
-		s = append(s, fmt.Sprintf("create:%s", i))
+// respondResult writes out the result of an API function, or the error it failed with
+func respondResult(ctx *gin.Context, obj any, err error) {
+	if deadlineExceeded(ctx) {
+		// the deadline passed while the handler ran, its result is no longer wanted
+		respondError(ctx, http.StatusGatewayTimeout, errRequestTimeout)
+		return
 	}
 
-	return s
+	if err != nil {
+		respondError(ctx, statusForError(err), err)
+		return
+	}
+	respondPayload(ctx, http.StatusOK, obj)
 }
 
-//nolint:unused
-func readScopes(items ...string) []string {
-	s := []string{"read"}
-	for _, i := range items {
-		s = append(s, fmt.Sprintf("read:%s", i))
-	}
+func createScopes(items ...string) []string {
+	return composeScopes("create", []string{"write", "create"}, items)
+}
 
-	return s
+func readScopes(items ...string) []string {
+	return composeScopes("read", []string{"read"}, items)
 }
 
-//nolint:unused
 func updateScopes(items ...string) []string {
-	s := []string{"write", "update"}
-	for _, i := range items {
-		s = append(s, fmt.Sprintf("update:%s", i))
-	}
-
-	return s
+	return composeScopes("update", []string{"write", "update"}, items)
 }
 
 //nolint:unused
 func deleteScopes(items ...string) []string {
-	s := []string{"write", "delete"}
+	return composeScopes("delete", []string{"write", "delete"}, items)
+}
+
+// composeScopes returns the base scopes plus an "<action>:<item>" scope for each
+// item, sorted and without duplicates.
+func composeScopes(action string, base, items []string) []string {
+	s := make([]string, 0, len(base)+len(items))
+	s = append(s, base...)
 	for _, i := range items {
-		s = append(s, fmt.Sprintf("delete:%s", i))
+		s = append(s, fmt.Sprintf("%s:%s", action, i))
 	}
 
-	return s
+	slices.Sort(s)
+	return slices.Compact(s)
 }
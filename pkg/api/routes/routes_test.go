@@ -0,0 +1,294 @@
+package routes
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/metal-toolbox/fleet-rest-skeleton/internal/app"
+	"github.com/metal-toolbox/fleet-rest-skeleton/internal/metrics"
+	"go.hollow.sh/toolbox/ginauth"
+	"go.hollow.sh/toolbox/ginjwt"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+const latencyMetric = "skeleton_api_latency_seconds"
+
+func TestAPICallMetricsAreLabeledByHandler(t *testing.T) {
+	metrics.Reset()
+
+	h, _ := newTestHandler(t, &app.Configuration{})
+
+	tests := []struct {
+		path        string
+		wantStatus  int
+		wantHandler string
+	}{
+		{versionPath, http.StatusOK, "version"},
+		{"/api/nope", http.StatusNotFound, unregisteredHandler},
+	}
+
+	for _, tt := range tests {
+		if w := serve(h, http.MethodGet, tt.path, ""); w.Code != tt.wantStatus {
+			t.Fatalf("%s: expected %d, got %d", tt.path, tt.wantStatus, w.Code)
+		}
+
+		if got := metricValue(t, latencyMetric, "handler", tt.wantHandler); got != 1 {
+			t.Errorf("%s: expected 1 observation labeled %q, got %v", tt.path, tt.wantHandler, got)
+		}
+	}
+}
+
+// newMetricsTestRouter serves GET /api/servers/:id and POST /api/servers through the
+// logging middleware, which records the API call metrics
+func newMetricsTestRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.Use(composeAppLogging(zap.NewNop(), nil))
+	r.GET("/api/servers/:id", func(c *gin.Context) {
+		c.String(http.StatusOK, c.Param("id"))
+	})
+	r.POST("/api/servers", func(c *gin.Context) {
+		c.String(http.StatusCreated, "created")
+	})
+
+	return r
+}
+
+func TestAPICallMetricsAreLabeledByRouteTemplate(t *testing.T) {
+	metrics.Reset()
+
+	r := newMetricsTestRouter()
+
+	for _, path := range []string{"/api/servers/1", "/api/servers/2"} {
+		if w := serve(r, http.MethodGet, path, ""); w.Code != http.StatusOK {
+			t.Fatalf("%s: expected %d, got %d", path, http.StatusOK, w.Code)
+		}
+	}
+
+	if w := serve(r, http.MethodGet, "/api/nope", ""); w.Code != http.StatusNotFound {
+		t.Fatalf("expected %d, got %d", http.StatusNotFound, w.Code)
+	}
+
+	tests := []struct {
+		endpoint string
+		want     float64
+	}{
+		{"/api/servers/:id", 2},
+		{unmatchedRoute, 1},
+		{"/api/servers/1", 0},
+		{"/api/nope", 0},
+	}
+
+	for _, tt := range tests {
+		if got := metricValue(t, latencyMetric, "endpoint", tt.endpoint); got != tt.want {
+			t.Errorf("%s: expected %v observations, got %v", tt.endpoint, tt.want, got)
+		}
+	}
+}
+
+// histogramSum returns the sum of the observations of the named histogram carrying
+// the given label values, given as name and value pairs
+func histogramSum(t *testing.T, name string, labels ...string) float64 {
+	t.Helper()
+
+	families, err := metrics.Registry().Gather()
+	if err != nil {
+		t.Fatalf("gathering metrics: %v", err)
+	}
+
+	var sum float64
+	for _, f := range families {
+		if f.GetName() != name {
+			continue
+		}
+
+	samples:
+		for _, m := range f.GetMetric() {
+			got := make(map[string]string, len(m.GetLabel()))
+			for _, l := range m.GetLabel() {
+				got[l.GetName()] = l.GetValue()
+			}
+
+			for i := 0; i+1 < len(labels); i += 2 {
+				if got[labels[i]] != labels[i+1] {
+					continue samples
+				}
+			}
+
+			sum += m.GetHistogram().GetSampleSum()
+		}
+	}
+
+	return sum
+}
+
+func TestAPICallSizes(t *testing.T) {
+	const (
+		requestMetric  = "skeleton_api_request_bytes"
+		responseMetric = "skeleton_api_response_bytes"
+		endpoint       = "/api/servers"
+	)
+
+	tests := []struct {
+		name          string
+		contentLength int64
+		wantRequests  float64
+	}{
+		{"known size", 11, 1},
+		{"unknown size", -1, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metrics.Reset()
+
+			req := httptest.NewRequest(http.MethodPost, endpoint, strings.NewReader(`{"id": "a"}`))
+			req.ContentLength = tt.contentLength
+
+			w := httptest.NewRecorder()
+			newMetricsTestRouter().ServeHTTP(w, req)
+
+			if w.Code != http.StatusCreated {
+				t.Fatalf("expected %d, got %d", http.StatusCreated, w.Code)
+			}
+
+			if got := metricValue(t, requestMetric, "endpoint", endpoint); got != tt.wantRequests {
+				t.Errorf("expected %v request size observations, got %v", tt.wantRequests, got)
+			}
+
+			if tt.wantRequests > 0 {
+				if got := histogramSum(t, requestMetric, "endpoint", endpoint); got != float64(tt.contentLength) {
+					t.Errorf("expected a request size of %d, got %v", tt.contentLength, got)
+				}
+			}
+
+			if got := metricValue(t, responseMetric, "endpoint", endpoint); got != 1 {
+				t.Errorf("expected 1 response size observation, got %v", got)
+			}
+
+			if got := histogramSum(t, responseMetric, "endpoint", endpoint); got != float64(w.Body.Len()) {
+				t.Errorf("expected a response size of %d, got %v", w.Body.Len(), got)
+			}
+		})
+	}
+}
+
+func TestWriteTimeoutWithStreaming(t *testing.T) {
+	const warning = "streaming is enabled with a finite write timeout, streams will be cut off; " +
+		"set write_timeout to 0 or enable disable_write_timeout_for_streaming"
+
+	tests := []struct {
+		name             string
+		cfg              app.Configuration
+		wantWriteTimeout time.Duration
+		wantWarning      bool
+	}{
+		{
+			name:             "streaming with the default write timeout",
+			cfg:              app.Configuration{StreamingEnabled: true},
+			wantWriteTimeout: app.DefaultWriteTimeout,
+			wantWarning:      true,
+		},
+		{
+			name:             "streaming with the write timeout disabled for it",
+			cfg:              app.Configuration{StreamingEnabled: true, DisableWriteTimeoutForStreaming: true},
+			wantWriteTimeout: 0,
+		},
+		{
+			name:             "disabling it without streaming",
+			cfg:              app.Configuration{DisableWriteTimeoutForStreaming: true},
+			wantWriteTimeout: app.DefaultWriteTimeout,
+		},
+		{
+			name:             "no streaming",
+			cfg:              app.Configuration{},
+			wantWriteTimeout: app.DefaultWriteTimeout,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			theApp, logs := newTestApp(t, &tt.cfg)
+
+			srv := ComposeHTTPServer(theApp)
+			if srv.WriteTimeout != tt.wantWriteTimeout {
+				t.Errorf("expected a write timeout of %s, got %s", tt.wantWriteTimeout, srv.WriteTimeout)
+			}
+
+			if got := logs.FilterMessage(warning).Len() == 1; got != tt.wantWarning {
+				t.Errorf("expected a warning %v, got %v", tt.wantWarning, got)
+			}
+		})
+	}
+}
+
+func TestJWTAuthSourcesAreLogged(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	restoreAuthOnCleanup(t)
+
+	prev := newJWTMiddleware
+	t.Cleanup(func() { newJWTMiddleware = prev })
+	newJWTMiddleware = func(...ginjwt.AuthConfig) (*ginauth.MultiTokenMiddleware, error) {
+		return ginauth.NewMultiTokenMiddleware()
+	}
+
+	t.Setenv("SKELETON_GINJWT_AUTH_JSON",
+		`[{"enabled": true, "issuer": "https://shared", "jwksuri": "https://shared/env/jwks"}]`)
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	contents := `listen_address: 127.0.0.1:0
+ginjwt_auth_precedence: merge
+ginjwt_auth:
+  - enabled: true
+    issuer: https://shared
+    jwksuri: https://shared/file/jwks
+  - enabled: true
+    issuer: https://file-only
+    jwksuri: https://file-only/jwks
+`
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatalf("writing config: %v", err)
+	}
+
+	cfg, err := app.LoadConfiguration(path)
+	if err != nil {
+		t.Fatalf("loading config: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	core, logs := observer.New(zapcore.InfoLevel)
+	ComposeHTTPServer(app.NewApp(ctx, cfg, zap.New(core)))
+
+	want := map[string]string{
+		"https://shared":    app.JWTAuthSourceEnv,
+		"https://file-only": app.JWTAuthSourceFile,
+	}
+
+	got := make(map[string]string)
+	for _, e := range logs.FilterMessage("jwt auth configured").All() {
+		fields := e.ContextMap()
+		got[fields["issuer"].(string)] = fields["source"].(string)
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("expected the sources %v to be logged, got %v", want, got)
+	}
+
+	for issuer, source := range want {
+		if got[issuer] != source {
+			t.Errorf("%s: expected source %q to be logged, got %q", issuer, source, got[issuer])
+		}
+	}
+}
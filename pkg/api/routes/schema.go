@@ -0,0 +1,139 @@
+package routes
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+const (
+	schemaVersionHeader = "X-Schema-Version"
+	// schemaURL identifies schemas compiled from memory in their error locations
+	schemaURL = "payload.schema.json"
+)
+
+var (
+	errUnsupportedSchemaVersion = errors.New("unsupported schema version")
+	errSchemaMismatch           = errors.New("request body does not match the schema")
+)
+
+// schemaDecoder decodes a request body written against one version of a route's
+// schema into the form taken by the route's apiHandler.
+type schemaDecoder func(*gin.Context) (map[string]any, error)
+
+// schemaVersions lists the request schema versions a route accepts
+type schemaVersions struct {
+	// latest is the version assumed when the client doesn't ask for one
+	latest   string
+	decoders map[string]schemaDecoder
+}
+
+// wrapVersionedAPICall is the counterpart of wrapAPICall for routes whose payloads
+// evolve over time. The request body is decoded by the decoder registered for the
+// version in the X-Schema-Version header, or the latest version when the header is
+// absent. Requests for an unsupported version are rejected with a 400.
+func wrapVersionedAPICall(versions schemaVersions, fn apiHandler) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		v := ctx.GetHeader(schemaVersionHeader)
+		if v == "" {
+			v = versions.latest
+		}
+
+		decode, ok := versions.decoders[v]
+		if !ok {
+			respondError(ctx, http.StatusBadRequest, fmt.Errorf("%w %q", errUnsupportedSchemaVersion, v))
+			return
+		}
+
+		m, err := decode(ctx)
+		if err != nil {
+			respondBindError(ctx, err)
+			return
+		}
+
+		respondAPICall(ctx, fn, m)
+	}
+}
+
+// wrapAPICallWithSchema is wrapAPICall for API functions whose payload must conform
+// to a JSON schema. Payloads that don't are rejected with a 400 listing every
+// violation, without calling fn. It panics when the schema doesn't compile.
+func wrapAPICallWithSchema(schema []byte, fn apiHandler) gin.HandlerFunc {
+	s := jsonschema.MustCompileString(schemaURL, string(schema))
+
+	return func(ctx *gin.Context) {
+		m := make(map[string]any)
+		// an empty body is taken as an empty object
+		if err := bindPayload(ctx, &m); err != nil && !errors.Is(err, io.EOF) {
+			respondBindError(ctx, err)
+			return
+		}
+
+		if err := validatePayload(s, m); err != nil {
+			respondSchemaError(ctx, err)
+			return
+		}
+
+		respondAPICall(ctx, fn, m)
+	}
+}
+
+// validatePayload validates the decoded payload against the schema
+func validatePayload(s *jsonschema.Schema, m map[string]any) error {
+	// msgpack decodes numbers into types the validator doesn't take, a JSON round
+	// trip turns them into the numbers it expects
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return err
+	}
+
+	return s.Validate(v)
+}
+
+// respondSchemaError replies to a request whose payload failed validation against
+// its schema, listing every violation found
+func respondSchemaError(c *gin.Context, err error) {
+	var ve *jsonschema.ValidationError
+	if !errors.As(err, &ve) {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	respondError(c, http.StatusBadRequest, err,
+		withMessage(errSchemaMismatch.Error()),
+		withSchemaErrors(schemaViolations(ve)),
+	)
+}
+
+// schemaViolations describes each violation behind a validation error, along with
+// the location in the payload it was found at
+func schemaViolations(ve *jsonschema.ValidationError) []string {
+	if len(ve.Causes) == 0 {
+		loc := ve.InstanceLocation
+		if loc == "" {
+			loc = "/"
+		}
+		return []string{loc + ": " + ve.Message}
+	}
+
+	var violations []string
+	for _, cause := range ve.Causes {
+		violations = append(violations, schemaViolations(cause)...)
+	}
+
+	return violations
+}
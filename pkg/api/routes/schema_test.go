@@ -0,0 +1,128 @@
+package routes
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/metal-toolbox/fleet-rest-skeleton/internal/app"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestValidatedEchoPayloadsAreValidatedAgainstTheSchema(t *testing.T) {
+	h, _ := newTestHandler(t, &app.Configuration{})
+
+	msgpackBody, err := msgpack.Marshal(map[string]any{"name": "a", "count": 3})
+	if err != nil {
+		t.Fatalf("encoding payload: %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		body           string
+		headers        []string
+		wantStatus     int
+		wantViolations []string
+	}{
+		{
+			name:       "valid",
+			body:       `{"name": "a", "extra": 1}`,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "valid msgpack",
+			body:       string(msgpackBody),
+			headers:    []string{"Content-Type", mimeMsgpack},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:           "missing field",
+			body:           `{"extra": 1}`,
+			wantStatus:     http.StatusBadRequest,
+			wantViolations: []string{"/: missing properties: 'name'"},
+		},
+		{
+			name:           "empty field",
+			body:           `{"name": ""}`,
+			wantStatus:     http.StatusBadRequest,
+			wantViolations: []string{"/name: length must be >= 1, but got 0"},
+		},
+		{
+			name:           "field of the wrong type",
+			body:           `{"name": 1}`,
+			wantStatus:     http.StatusBadRequest,
+			wantViolations: []string{"/name: expected string, but got number"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(h, http.MethodPost, "/api/echo/validated", tt.body, tt.headers...)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("expected %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+
+			if w.Code == http.StatusOK {
+				if unchecked := serve(h, http.MethodPost, "/api/echo", tt.body, tt.headers...); unchecked.Body.String() != w.Body.String() {
+					t.Errorf("expected the payload echoed as by /api/echo, got %s", w.Body.String())
+				}
+				return
+			}
+
+			if unchecked := serve(h, http.MethodPost, "/api/echo", tt.body, tt.headers...); unchecked.Code != http.StatusOK {
+				t.Errorf("expected /api/echo not to validate the payload, got %d", unchecked.Code)
+			}
+
+			body := decodeError(t, w)
+			if body.Message != errSchemaMismatch.Error() {
+				t.Errorf("expected %q, got %q", errSchemaMismatch, body.Message)
+			}
+
+			if !slices.Equal(body.SchemaErrors, tt.wantViolations) {
+				t.Errorf("expected violations %q, got %q", tt.wantViolations, body.SchemaErrors)
+			}
+		})
+	}
+}
+
+func TestVersionedEchoNegotiatesTheSchemaVersion(t *testing.T) {
+	h, _ := newTestHandler(t, &app.Configuration{})
+
+	tests := []struct {
+		name       string
+		body       string
+		version    string
+		wantStatus int
+		wantBody   string
+	}{
+		{"latest by default", `{"name": "a"}`, "", http.StatusOK, `{"name":"a"}`},
+		{"latest", `{"name": "a"}`, "2", http.StatusOK, `{"name":"a"}`},
+		{"older version", `{"payload": {"name": "a"}}`, "1", http.StatusOK, `{"name":"a"}`},
+		{"unsupported version", `{"name": "a"}`, "3", http.StatusBadRequest, ""},
+		{"malformed body", `{"name":`, "1", http.StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var headers []string
+			if tt.version != "" {
+				headers = []string{schemaVersionHeader, tt.version}
+			}
+
+			w := serve(h, http.MethodPost, "/api/echo/versioned", tt.body, headers...)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("expected %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+
+			if tt.wantBody != "" && strings.TrimSpace(w.Body.String()) != tt.wantBody {
+				t.Errorf("expected %s, got %s", tt.wantBody, w.Body.String())
+			}
+		})
+	}
+
+	w := serve(h, http.MethodPost, "/api/echo/versioned", `{}`, schemaVersionHeader, "3")
+	if msg := decodeError(t, w).Message; msg != `unsupported schema version "3"` {
+		t.Errorf("expected the unsupported version named, got %q", msg)
+	}
+}
@@ -0,0 +1,177 @@
+package routes
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/metal-toolbox/fleet-rest-skeleton/internal/app"
+)
+
+// startServer serves the API composed for cfg on the listener opened for it until
+// the test is done
+func startServer(t *testing.T, cfg *app.Configuration) net.Listener {
+	t.Helper()
+
+	theApp, _ := newTestApp(t, cfg)
+
+	l, err := app.Listen(context.Background(), theApp.Cfg)
+	if err != nil {
+		t.Fatalf("listening: %v", err)
+	}
+
+	srv := ComposeHTTPServer(theApp)
+	go func() {
+		serve := func() error { return srv.Serve(l) }
+		if cfg.TLS.Enabled() {
+			serve = func() error { return srv.ServeTLS(l, cfg.TLS.CertFile, cfg.TLS.KeyFile) }
+		}
+
+		if err := serve(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			t.Errorf("serving: %v", err)
+		}
+	}()
+	t.Cleanup(func() { _ = srv.Close() })
+
+	return l
+}
+
+func TestServesOverUnixSocket(t *testing.T) {
+	// socket paths are limited to around 100 bytes, too short for t.TempDir
+	dir, err := os.MkdirTemp("", "skeleton")
+	if err != nil {
+		t.Fatalf("creating a directory: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, "api.sock")
+	startServer(t, &app.Configuration{ListenAddress: "unix:" + path})
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", path)
+		},
+	}}
+
+	resp, err := client.Get("http://unix" + versionPath)
+	if err != nil {
+		t.Fatalf("requesting the version: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected 200, got %d", resp.StatusCode)
+	}
+}
+
+// writeCertificate writes a self-signed certificate for 127.0.0.1 and its key to
+// files, returning their paths along with the certificate
+func writeCertificate(t *testing.T) (certFile, keyFile string, cert *x509.Certificate) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "skeleton"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("creating certificate: %v", err)
+	}
+
+	cert, err = x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("parsing certificate: %v", err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshaling key: %v", err)
+	}
+
+	dir := t.TempDir()
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("writing certificate: %v", err)
+	}
+
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatalf("writing key: %v", err)
+	}
+
+	return certFile, keyFile, cert
+}
+
+func TestServesOverTLS(t *testing.T) {
+	certFile, keyFile, cert := writeCertificate(t)
+
+	l := startServer(t, &app.Configuration{
+		ListenAddress: "127.0.0.1:0",
+		TLS:           app.TLSConfig{CertFile: certFile, KeyFile: keyFile},
+	})
+
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+
+	tests := []struct {
+		name       string
+		maxVersion uint16
+		wantErr    bool
+	}{
+		{"tls 1.3", tls.VersionTLS13, false},
+		{"tls 1.2", tls.VersionTLS12, false},
+		{"tls 1.1", tls.VersionTLS11, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &http.Client{Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS10, MaxVersion: tt.maxVersion}, //nolint:gosec // checking old versions are refused
+			}}
+
+			resp, err := client.Get("https://" + l.Addr().String() + versionPath)
+			if tt.wantErr {
+				if err == nil {
+					resp.Body.Close()
+					t.Fatal("expected the handshake to be refused")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("requesting the version: %v", err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != http.StatusOK {
+				t.Errorf("expected 200, got %d", resp.StatusCode)
+			}
+		})
+	}
+}
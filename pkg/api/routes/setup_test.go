@@ -0,0 +1,141 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/metal-toolbox/fleet-rest-skeleton/internal/app"
+	"github.com/metal-toolbox/fleet-rest-skeleton/internal/metrics"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// newTestApp returns an App for cfg, with defaults applied, whose logger records
+// what is logged through it. Its context is canceled once the test is done, stopping
+// any background work.
+func newTestApp(t *testing.T, cfg *app.Configuration, opts ...app.Option) (*app.App, *observer.ObservedLogs) {
+	t.Helper()
+
+	if cfg.ListenAddress == "" {
+		cfg.ListenAddress = "127.0.0.1:0"
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	theApp, err := app.NewAppFromConfig(ctx, cfg, opts...)
+	if err != nil {
+		t.Fatalf("composing app: %v", err)
+	}
+
+	core, logs := observer.New(zapcore.DebugLevel)
+	theApp.Log = zap.New(core)
+
+	return theApp, logs
+}
+
+// newTestHandler composes the API for cfg
+func newTestHandler(t *testing.T, cfg *app.Configuration, opts ...app.Option) (http.Handler, *observer.ObservedLogs) {
+	t.Helper()
+
+	theApp, logs := newTestApp(t, cfg, opts...)
+
+	return ComposeHTTPServer(theApp).Handler, logs
+}
+
+// serve has h handle a request with the given body and headers, given as name and
+// value pairs
+func serve(h http.Handler, method, path, body string, headers ...string) *httptest.ResponseRecorder {
+	var r io.Reader
+	if body != "" {
+		r = strings.NewReader(body)
+	}
+
+	req := httptest.NewRequest(method, path, r)
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	return w
+}
+
+// decodeError decodes the error body of a response
+func decodeError(t *testing.T, w *httptest.ResponseRecorder) errorResponse {
+	t.Helper()
+
+	var body errorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decoding error body %q: %v", w.Body.String(), err)
+	}
+
+	return body
+}
+
+// metricValue sums the values of the samples of the named metric carrying the given
+// label values, given as name and value pairs. Histograms contribute their sample
+// counts.
+func metricValue(t *testing.T, name string, labels ...string) float64 {
+	t.Helper()
+
+	families, err := metrics.Registry().Gather()
+	if err != nil {
+		t.Fatalf("gathering metrics: %v", err)
+	}
+
+	var sum float64
+	for _, f := range families {
+		if f.GetName() != name {
+			continue
+		}
+
+	samples:
+		for _, m := range f.GetMetric() {
+			got := make(map[string]string, len(m.GetLabel()))
+			for _, l := range m.GetLabel() {
+				got[l.GetName()] = l.GetValue()
+			}
+
+			for i := 0; i+1 < len(labels); i += 2 {
+				if got[labels[i]] != labels[i+1] {
+					continue samples
+				}
+			}
+
+			switch {
+			case m.Counter != nil:
+				sum += m.GetCounter().GetValue()
+			case m.Gauge != nil:
+				sum += m.GetGauge().GetValue()
+			case m.Histogram != nil:
+				sum += float64(m.GetHistogram().GetSampleCount())
+			}
+		}
+	}
+
+	return sum
+}
+
+// decodeHealth decodes the body of a health endpoint response
+func decodeHealth(t *testing.T, w *httptest.ResponseRecorder) healthResponse {
+	t.Helper()
+
+	var body healthResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decoding health body %q: %v", w.Body.String(), err)
+	}
+
+	return body
+}
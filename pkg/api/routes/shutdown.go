@@ -0,0 +1,29 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/metal-toolbox/fleet-rest-skeleton/internal/app"
+)
+
+// shutdownRetryAfter is how long, in seconds, clients are asked to wait before
+// retrying once the server is shutting down, long enough for a replacement to start
+const shutdownRetryAfter = 5
+
+var errShuttingDown = errors.New("shutting down, retry later")
+
+// composeShutdownGate turns new requests away with a 503 and a Retry-After header
+// once the App is shutting down, rather than leaving them to have their connections
+// reset. Requests already past the gate are left to finish while the server drains.
+func composeShutdownGate(theApp *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if theApp.ShuttingDown() {
+			c.Header("Retry-After", strconv.Itoa(shutdownRetryAfter))
+			c.Header("Connection", "close")
+			respondError(c, http.StatusServiceUnavailable, errShuttingDown)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package routes
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/metal-toolbox/fleet-rest-skeleton/internal/app"
+)
+
+func TestRequestsAreTurnedAwayOnceShutdownBegins(t *testing.T) {
+	theApp, _ := newTestApp(t, &app.Configuration{}, app.WithSignals(syscall.SIGUSR2))
+	h := ComposeHTTPServer(theApp).Handler
+
+	if w := serve(h, http.MethodGet, "/api/version", ""); w.Code != http.StatusOK {
+		t.Fatalf("expected 200 before shutting down, got %d", w.Code)
+	}
+
+	if err := syscall.Kill(os.Getpid(), syscall.SIGUSR2); err != nil {
+		t.Fatalf("signaling: %v", err)
+	}
+
+	signaled := make(chan os.Signal, 1)
+	go func() { signaled <- theApp.WaitForSignal() }()
+
+	select {
+	case <-signaled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the signal")
+	}
+
+	w := serve(h, http.MethodGet, "/api/version", "")
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 once signaled to terminate, got %d", w.Code)
+	}
+
+	if got := w.Header().Get("Retry-After"); got != strconv.Itoa(shutdownRetryAfter) {
+		t.Errorf("expected Retry-After %d, got %q", shutdownRetryAfter, got)
+	}
+
+	if got := w.Header().Get("Connection"); got != "close" {
+		t.Errorf("expected the connection to be closed, got %q", got)
+	}
+
+	if msg := decodeError(t, w).Message; msg != errShuttingDown.Error() {
+		t.Errorf("expected %q, got %q", errShuttingDown, msg)
+	}
+}
@@ -0,0 +1,114 @@
+package routes
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/metal-toolbox/fleet-rest-skeleton/internal/metrics"
+)
+
+const (
+	// streamRetryAfter is how long, in seconds, clients are asked to wait when all
+	// stream slots are taken
+	streamRetryAfter = 5
+
+	defaultTimeTicks = 5
+	maxTimeTicks     = 60
+)
+
+var (
+	errTooManyStreams = errors.New("too many concurrent streams, retry later")
+	errInvalidCount   = errors.New("count must be between 1 and 60")
+)
+
+// composeStreamLimiter caps the number of streams served at once across all streaming
+// routes, independently of any limit on regular requests. Streams beyond the cap are
+// turned away with a 503 and a Retry-After header. Streams being served are tracked
+// by the active streams gauge.
+func composeStreamLimiter(slots chan struct{}) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		select {
+		case slots <- struct{}{}:
+			endpoint := routeTemplate(c)
+			metrics.StreamStarted(endpoint)
+
+			defer func() {
+				metrics.StreamEnded(endpoint)
+				<-slots
+			}()
+			c.Next()
+		default:
+			c.Header("Retry-After", strconv.Itoa(streamRetryAfter))
+			respondError(c, http.StatusServiceUnavailable, errTooManyStreams)
+		}
+	}
+}
+
+// ttfbWriter records the time to first byte once response data is first flushed to
+// the client.
+type ttfbWriter struct {
+	gin.ResponseWriter
+	endpoint string
+	start    time.Time
+	observed bool
+}
+
+func (w *ttfbWriter) Flush() {
+	w.ResponseWriter.Flush()
+
+	if !w.observed && w.Size() > 0 {
+		w.observed = true
+		metrics.ResponseTTFB(w.endpoint, w.start)
+	}
+}
+
+// composeStreamTTFB observes the time to first byte of streaming responses, measured
+// from when the request was received.
+func composeStreamTTFB() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := c.GetTime(requestStartKey)
+		if start.IsZero() {
+			start = time.Now()
+		}
+
+		c.Writer = &ttfbWriter{
+			ResponseWriter: c.Writer,
+			endpoint:       routeTemplate(c),
+			start:          start,
+		}
+		c.Next()
+	}
+}
+
+// apiStreamTime is an example streaming endpoint, sending the server time as a
+// server-sent event every second, count times.
+func apiStreamTime(c *gin.Context) {
+	count := defaultTimeTicks
+	if q := c.Query("count"); q != "" {
+		n, err := strconv.Atoi(q)
+		if err != nil || n < 1 || n > maxTimeTicks {
+			respondError(c, http.StatusBadRequest, errInvalidCount)
+			return
+		}
+		count = n
+	}
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	sent := 0
+	c.Stream(func(_ io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case t := <-ticker.C:
+			c.SSEvent("time", t.Format(time.RFC3339))
+			sent++
+			return sent < count
+		}
+	})
+}
@@ -0,0 +1,108 @@
+package routes
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestStreamLimiterTurnsAwayStreamsBeyondTheCap(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	r := gin.New()
+	r.GET("/stream", composeStreamLimiter(make(chan struct{}, 1)), func(c *gin.Context) {
+		close(started)
+		<-release
+		c.String(http.StatusOK, "done")
+	})
+
+	first := make(chan int)
+	go func() {
+		first <- serve(r, http.MethodGet, "/stream", "").Code
+	}()
+	<-started
+
+	w := serve(r, http.MethodGet, "/stream", "")
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected a stream beyond the cap to get 503, got %d", w.Code)
+	}
+
+	if got := w.Header().Get("Retry-After"); got != strconv.Itoa(streamRetryAfter) {
+		t.Errorf("expected Retry-After %d, got %q", streamRetryAfter, got)
+	}
+
+	close(release)
+	if code := <-first; code != http.StatusOK {
+		t.Errorf("expected the stream within the cap to get 200, got %d", code)
+	}
+}
+
+func TestStreamTTFBIsObservedOnFirstFlush(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.GET("/ttfb", composeStreamTTFB(), func(c *gin.Context) {
+		c.Writer.Flush()
+		c.String(http.StatusOK, "first")
+		c.Writer.Flush()
+		c.String(http.StatusOK, "second")
+		c.Writer.Flush()
+	})
+
+	const metric = "skeleton_api_response_ttfb_seconds"
+	before := metricValue(t, metric, "endpoint", "/ttfb")
+
+	serve(r, http.MethodGet, "/ttfb", "")
+
+	if got := metricValue(t, metric, "endpoint", "/ttfb") - before; got != 1 {
+		t.Errorf("expected a single observation once data was flushed, got %v", got)
+	}
+}
+
+func TestActiveStreamsGaugeTracksOpenStreams(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	const (
+		metric  = "skeleton_api_active_streams"
+		streams = 2
+	)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	r := gin.New()
+	r.GET("/gauged", composeStreamLimiter(make(chan struct{}, streams)), func(c *gin.Context) {
+		started <- struct{}{}
+		<-release
+		c.String(http.StatusOK, "done")
+	})
+
+	before := metricValue(t, metric, "endpoint", "/gauged")
+
+	done := make(chan struct{})
+	for i := 0; i < streams; i++ {
+		go func() {
+			serve(r, http.MethodGet, "/gauged", "")
+			done <- struct{}{}
+		}()
+		<-started
+	}
+
+	if got := metricValue(t, metric, "endpoint", "/gauged") - before; got != streams {
+		t.Errorf("expected %d active streams while open, got %v", streams, got)
+	}
+
+	close(release)
+	for i := 0; i < streams; i++ {
+		<-done
+	}
+
+	if got := metricValue(t, metric, "endpoint", "/gauged") - before; got != 0 {
+		t.Errorf("expected no active streams once closed, got %v", got)
+	}
+}
@@ -0,0 +1,53 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// requestTimeoutHeader carries the timeout a client wants for its request, as a Go
+// duration string such as 1.5s
+const requestTimeoutHeader = "X-Request-Timeout"
+
+var errRequestTimeout = errors.New("request exceeded its deadline")
+
+// composeRequestTimeout bounds the context of every request by the timeout, so that
+// handlers honoring ctx.Request.Context() observe the cancellation. A handler that
+// overruns the deadline without writing a response gets a 504 written on its behalf.
+//
+// When clientMax is positive, clients may ask for another timeout in the
+// X-Request-Timeout header, which is capped at clientMax. Missing or invalid headers
+// get the server timeout, and a timeout of 0 leaves the request without a deadline.
+func composeRequestTimeout(timeout, clientMax time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		timeout := timeout
+		if clientMax > 0 {
+			if requested, err := time.ParseDuration(c.GetHeader(requestTimeoutHeader)); err == nil && requested > 0 {
+				timeout = min(requested, clientMax)
+			}
+		}
+
+		if timeout <= 0 {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+
+		if deadlineExceeded(c) && !c.Writer.Written() {
+			respondError(c, http.StatusGatewayTimeout, errRequestTimeout)
+		}
+	}
+}
+
+// deadlineExceeded indicates whether the request ran past its deadline
+func deadlineExceeded(c *gin.Context) bool {
+	return errors.Is(c.Request.Context().Err(), context.DeadlineExceeded)
+}
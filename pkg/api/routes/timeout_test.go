@@ -0,0 +1,154 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/metal-toolbox/fleet-rest-skeleton/internal/app"
+)
+
+func TestTimedOutRequestGets504BeforeTheWriteTimeout(t *testing.T) {
+	cfg := &app.Configuration{
+		ListenAddress:  "127.0.0.1:0",
+		WriteTimeout:   time.Second,
+		RequestTimeout: time.Minute,
+	}
+	theApp, _ := newTestApp(t, cfg)
+
+	if cfg.RequestTimeout >= cfg.WriteTimeout {
+		t.Fatalf("expected the request timeout to be shortened below the write timeout, got %v", cfg.RequestTimeout)
+	}
+
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.POST("/slow", composeRequestTimeout(theApp.Cfg.RequestTimeout, 0),
+		wrapAPICall(func(ctx context.Context, _ map[string]any) (map[string]any, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}))
+
+	srv := httptest.NewUnstartedServer(r)
+	srv.Config.WriteTimeout = theApp.Cfg.WriteTimeout
+	srv.Start()
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/slow", "application/json", nil)
+	if err != nil {
+		t.Fatalf("expected a response ahead of the write timeout, got %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusGatewayTimeout {
+		t.Errorf("expected 504, got %d", resp.StatusCode)
+	}
+
+	var body errorResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil || body.Message != errRequestTimeout.Error() {
+		t.Errorf("expected the timeout error body, got %+v, %v", body, err)
+	}
+}
+
+func TestHandlerOverrunningTheDeadlineGets504(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.GET("/overrun", composeRequestTimeout(10*time.Millisecond, 0), func(c *gin.Context) {
+		<-c.Request.Context().Done()
+	})
+
+	if w := serve(r, http.MethodGet, "/overrun", ""); w.Code != http.StatusGatewayTimeout {
+		t.Errorf("expected 504, got %d", w.Code)
+	}
+}
+
+func TestClientRequestTimeout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	const (
+		serverTimeout = 10 * time.Second
+		clientMax     = 30 * time.Second
+	)
+
+	tests := []struct {
+		name   string
+		header string
+		want   time.Duration
+	}{
+		{"absent", "", serverTimeout},
+		{"valid", "2s", 2 * time.Second},
+		{"over the max", "5m", clientMax},
+		{"invalid", "soon", serverTimeout},
+		{"negative", "-1s", serverTimeout},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var remaining time.Duration
+
+			r := gin.New()
+			r.GET("/deadline", composeRequestTimeout(serverTimeout, clientMax), func(c *gin.Context) {
+				deadline, _ := c.Request.Context().Deadline()
+				remaining = time.Until(deadline)
+				c.Status(http.StatusNoContent)
+			})
+
+			var headers []string
+			if tt.header != "" {
+				headers = []string{requestTimeoutHeader, tt.header}
+			}
+			serve(r, http.MethodGet, "/deadline", "", headers...)
+
+			if remaining > tt.want || remaining < tt.want-time.Second {
+				t.Errorf("expected a deadline %v away, got %v", tt.want, remaining)
+			}
+		})
+	}
+}
+
+func TestHandlerSleepingPastTheDeadline(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	const timeout = 20 * time.Millisecond
+
+	tests := []struct {
+		name       string
+		sleep      time.Duration
+		wantStatus int
+	}{
+		{"within the deadline", 0, http.StatusOK},
+		{"past the deadline", 2 * timeout, http.StatusGatewayTimeout},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.GET("/sleep", composeRequestTimeout(timeout, 0), func(c *gin.Context) {
+				// deliberately ignores the request context
+				time.Sleep(tt.sleep)
+
+				if c.Request.Context().Err() == nil {
+					c.Status(http.StatusOK)
+				}
+			})
+
+			w := serve(r, http.MethodGet, "/sleep", "")
+			if w.Code != tt.wantStatus {
+				t.Fatalf("expected %d, got %d", tt.wantStatus, w.Code)
+			}
+
+			if tt.wantStatus == http.StatusOK {
+				return
+			}
+
+			if body := decodeError(t, w); body.Code != tt.wantStatus || body.Message != errRequestTimeout.Error() {
+				t.Errorf("expected the timeout error body, got %+v", body)
+			}
+		})
+	}
+}
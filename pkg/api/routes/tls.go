@@ -0,0 +1,106 @@
+package routes
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/metal-toolbox/fleet-rest-skeleton/internal/app"
+)
+
+var (
+	errNoClientCAs       = errors.New("no CA certificates found")
+	errUnknownClientAuth = errors.New("unknown client auth mode")
+)
+
+// clientScopes maps lower-cased client certificate identities to their scopes
+var clientScopes map[string][]string
+
+func setClientScopes(scopes map[string][]string) {
+	clientScopes = make(map[string][]string, len(scopes))
+	for id, s := range scopes {
+		clientScopes[strings.ToLower(id)] = s
+	}
+}
+
+// composeTLSConfig builds the server TLS configuration, requiring TLS 1.2 or later and
+// verifying client certificates against the configured CAs when client auth is enabled.
+func composeTLSConfig(cfg *app.TLSConfig) (*tls.Config, error) {
+	tlsCfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+
+	switch cfg.ClientAuth {
+	case "", app.ClientAuthNone:
+		return tlsCfg, nil
+	case app.ClientAuthVerifyIfGiven:
+		tlsCfg.ClientAuth = tls.VerifyClientCertIfGiven
+	case app.ClientAuthRequire:
+		tlsCfg.ClientAuth = tls.RequireAndVerifyClientCert
+	default:
+		return nil, fmt.Errorf("%w %q", errUnknownClientAuth, cfg.ClientAuth)
+	}
+
+	pem, err := os.ReadFile(cfg.ClientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading client CA file: %w", err)
+	}
+
+	tlsCfg.ClientCAs = x509.NewCertPool()
+	if !tlsCfg.ClientCAs.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("%w in %s", errNoClientCAs, cfg.ClientCAFile)
+	}
+
+	return tlsCfg, nil
+}
+
+// clientCertIdentities returns the identities of the verified client certificate
+// presented with the request, if any.
+func clientCertIdentities(r *http.Request) []string {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil
+	}
+
+	leaf := r.TLS.VerifiedChains[0][0]
+	ids := append([]string{leaf.Subject.CommonName}, leaf.DNSNames...)
+	for i := range ids {
+		ids[i] = strings.ToLower(ids[i])
+	}
+
+	return ids
+}
+
+// mappedClientCert returns the first of the client certificate identities that is
+// mapped to scopes, along with them, and false when none is
+func mappedClientCert(ids []string) (string, []string, bool) {
+	for _, id := range ids {
+		if held, ok := clientScopes[id]; ok {
+			return id, held, true
+		}
+	}
+
+	return "", nil, false
+}
+
+// authenticateClientCert admits the request when the client certificate identity
+// holds one of the scopes. The identity and its scopes are attached to the context,
+// so that callers denied for lacking a scope are still identified.
+func authenticateClientCert(c *gin.Context, id string, held, scopes []string) {
+	c.Set(authSubjectKey, "cert:"+id)
+	c.Set(authScopesKey, held)
+
+	if !grantsAny(held, scopes) {
+		respondError(c, http.StatusForbidden, errMissingScope)
+	}
+}
+
+// grantsAny indicates whether any of the required scopes is held
+func grantsAny(held, required []string) bool {
+	return slices.ContainsFunc(required, func(s string) bool { return slices.Contains(held, s) })
+}
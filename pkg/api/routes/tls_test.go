@@ -0,0 +1,215 @@
+package routes
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/metal-toolbox/fleet-rest-skeleton/internal/app"
+)
+
+// testCA is a certificate authority held in memory, issuing client certificates
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T) testCA {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("creating certificate: %v", err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("parsing certificate: %v", err)
+	}
+
+	return testCA{cert: cert, key: key}
+}
+
+// issue returns a client certificate for the common name signed by the CA
+func (ca testCA) issue(t *testing.T, commonName string) tls.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatalf("creating certificate: %v", err)
+	}
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// setTestClientScopes maps client certificate identities to scopes for the duration
+// of the test
+func setTestClientScopes(t *testing.T, scopes map[string][]string) {
+	t.Helper()
+
+	prev := clientScopes
+	t.Cleanup(func() { clientScopes = prev })
+	setClientScopes(scopes)
+}
+
+func TestClientCertificateAuth(t *testing.T) {
+	setTestAuth(t, []app.APIKey{{Name: "reader", Key: "reader-key", Scopes: []string{"read"}}}, nil)
+	setTestClientScopes(t, map[string][]string{
+		"Service-A": {"read"},
+		"service-b": {"write"},
+	})
+
+	trusted := newTestCA(t)
+	pool := x509.NewCertPool()
+	pool.AddCert(trusted.cert)
+
+	srv := httptest.NewUnstartedServer(newAuthTestRouter())
+	srv.TLS = &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ClientAuth: tls.VerifyClientCertIfGiven,
+		ClientCAs:  pool,
+	}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	tests := []struct {
+		name        string
+		cert        *tls.Certificate
+		headers     []string
+		wantErr     bool
+		wantStatus  int
+		wantSubject string
+	}{
+		{
+			name:        "accepted client",
+			cert:        ptr(trusted.issue(t, "service-a")),
+			wantStatus:  http.StatusOK,
+			wantSubject: "cert:service-a",
+		},
+		{
+			name:       "client lacking the scope",
+			cert:       ptr(trusted.issue(t, "service-b")),
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:        "unmapped client falling through to an api key",
+			cert:        ptr(trusted.issue(t, "service-c")),
+			headers:     []string{apiKeyHeader, "reader-key"},
+			wantStatus:  http.StatusOK,
+			wantSubject: "api-key:reader",
+		},
+		{
+			name:       "unmapped client without other credentials",
+			cert:       ptr(trusted.issue(t, "service-c")),
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "client without a certificate",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:        "client without a certificate presenting an api key",
+			headers:     []string{apiKeyHeader, "reader-key"},
+			wantStatus:  http.StatusOK,
+			wantSubject: "api-key:reader",
+		},
+		{
+			name:    "client of an unknown CA",
+			cert:    ptr(newTestCA(t).issue(t, "service-a")),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport := srv.Client().Transport.(*http.Transport).Clone()
+			if tt.cert != nil {
+				transport.TLSClientConfig.Certificates = []tls.Certificate{*tt.cert}
+			}
+			client := &http.Client{Transport: transport}
+			defer client.CloseIdleConnections()
+
+			req, err := http.NewRequest(http.MethodGet, srv.URL+"/protected", http.NoBody)
+			if err != nil {
+				t.Fatalf("building request: %v", err)
+			}
+			for i := 0; i+1 < len(tt.headers); i += 2 {
+				req.Header.Set(tt.headers[i], tt.headers[i+1])
+			}
+
+			resp, err := client.Do(req)
+			if tt.wantErr {
+				if err == nil {
+					resp.Body.Close()
+					t.Fatal("expected the handshake to fail")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("requesting: %v", err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("expected %d, got %d", tt.wantStatus, resp.StatusCode)
+			}
+
+			if resp.StatusCode != http.StatusOK {
+				return
+			}
+
+			var body struct {
+				Subject string `json:"subject"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatalf("decoding body: %v", err)
+			}
+
+			if body.Subject != tt.wantSubject {
+				t.Errorf("expected subject %q, got %q", tt.wantSubject, body.Subject)
+			}
+		})
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
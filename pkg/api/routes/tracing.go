@@ -0,0 +1,57 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+const tracerName = "github.com/metal-toolbox/fleet-rest-skeleton/pkg/api/routes"
+
+// composeTracing starts a server span for every request, continuing any trace
+// propagated by the caller, and hands it to handlers through the request context.
+// Spans are named after the matched route template to keep their cardinality bounded.
+func composeTracing() gin.HandlerFunc {
+	tracer := otel.GetTracerProvider().Tracer(tracerName)
+
+	return func(c *gin.Context) {
+		ctx := otel.GetTextMapPropagator().Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+
+		ctx, span := tracer.Start(ctx, c.Request.Method+" "+routeTemplate(c),
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPMethod(c.Request.Method),
+				semconv.HTTPRoute(routeTemplate(c)),
+			),
+		)
+		defer span.End()
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+
+		code := c.Writer.Status()
+		span.SetAttributes(
+			semconv.HTTPStatusCode(code),
+			attribute.String("handler", handlerName(c)),
+		)
+
+		if code >= 500 {
+			span.SetStatus(codes.Error, c.Errors.String())
+		}
+	}
+}
+
+// traceIDField returns a log field holding the ID of the trace the request is part
+// of, or zap.Skip when it isn't traced
+func traceIDField(c *gin.Context) zap.Field {
+	if sc := trace.SpanContextFromContext(c.Request.Context()); sc.HasTraceID() {
+		return zap.String("trace_id", sc.TraceID().String())
+	}
+
+	return zap.Skip()
+}
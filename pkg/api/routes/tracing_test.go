@@ -0,0 +1,60 @@
+package routes
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/metal-toolbox/fleet-rest-skeleton/internal/app"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// recordSpans installs a tracer provider recording ended spans in memory for the
+// duration of the test
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+
+	rec := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)))
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	return rec
+}
+
+func TestRequestsAreTraced(t *testing.T) {
+	rec := recordSpans(t)
+	h, logs := newTestHandler(t, &app.Configuration{})
+
+	for i := 0; i < 2; i++ {
+		if w := serve(h, http.MethodGet, versionPath, ""); w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", w.Code)
+		}
+	}
+
+	spans := rec.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("expected a span per request, got %d", len(spans))
+	}
+
+	span := spans[0]
+	if got := span.Name(); got != "GET "+versionPath {
+		t.Errorf("expected the span to be named after the route, got %q", got)
+	}
+
+	attrs := attribute.NewSet(span.Attributes()...)
+	if v, _ := attrs.Value("handler"); v.AsString() != "version" {
+		t.Errorf("expected the handler name as an attribute, got %q", v.AsString())
+	}
+
+	entries := logs.FilterMessage("api call complete").All()
+	if len(entries) != 2 {
+		t.Fatalf("expected a log entry per request, got %d", len(entries))
+	}
+
+	if got := entries[0].ContextMap()["trace_id"]; got != span.SpanContext().TraceID().String() {
+		t.Errorf("expected the trace ID %s to be logged, got %v", span.SpanContext().TraceID(), got)
+	}
+}
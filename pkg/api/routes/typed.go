@@ -0,0 +1,29 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/gin-gonic/gin"
+)
+
+// typedAPIHandler is an apiHandler taking and returning payloads of its own types
+type typedAPIHandler[Req, Resp any] func(context.Context, Req) (Resp, error)
+
+// wrapStrictAPICall is the counterpart of wrapAPICall for API functions with typed
+// payloads. Request bodies holding fields Req has no place for are rejected with a
+// 400 naming the field, so that client typos don't go unnoticed.
+func wrapStrictAPICall[Req, Resp any](fn typedAPIHandler[Req, Resp]) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req Req
+		// an empty body is taken as the zero Req
+		if err := bindStrictPayload(ctx, &req); err != nil && !errors.Is(err, io.EOF) {
+			respondBindError(ctx, err)
+			return
+		}
+
+		resp, err := fn(ctx.Request.Context(), req)
+		respondResult(ctx, resp, err)
+	}
+}